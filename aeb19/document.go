@@ -0,0 +1,106 @@
+package aeb19
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/apsl/sepakit/redact"
+)
+
+type InitiatingParty struct {
+	ID           string
+	Name         string
+	CreationDate time.Time
+	FileID       string
+	Entity       string
+	Office       string
+}
+
+type Creditor struct {
+	ID        string
+	Name      string
+	AddressD1 string
+	AddressD2 string
+	AddressD3 string
+	Country   string
+	Account   string
+}
+
+type Debtor struct {
+	Entity    string
+	Name      string
+	AddressD1 string
+	AddressD2 string
+	AddressD3 string
+	Country   string
+	IDType    string
+	ID        string
+	IDTXCode  string
+	AccountID string
+	Account   string
+}
+type DebitTransaction struct {
+	ID           string
+	MandateID    string
+	Sequence     string
+	CategoryCode string
+	//Amount is in cents, as the amounts of the totals
+	Amount int64
+	//MandateSignatureDate is the date the debtor signed the mandate
+	MandateSignatureDate time.Time
+	Debtor               Debtor
+	Purpose              string
+	Concept              string
+}
+
+type DatePayment struct {
+	Date               time.Time
+	DebitTransactions  []*DebitTransaction
+	TotalAmount        int64
+	DebitRegisterCount int
+	TotalRegisterCount int
+}
+type CreditorPayments struct {
+	Creditor           Creditor
+	DatePayments       []*DatePayment
+	TotalAmount        int64
+	DebitRegisterCount int
+	TotalRegisterCount int
+}
+
+type Document struct {
+	Variant            Variant
+	InitiatingParty    *InitiatingParty
+	CreditorPayments   []*CreditorPayments
+	TotalAmount        int64
+	DebitRegisterCount int
+	TotalRegisterCount int
+}
+
+//NewDocument returns an *aeb19.Document
+func NewDocument() *Document {
+	return &Document{}
+}
+
+func (doc *Document) String() string {
+	return fmt.Sprintf("Document Presenter: %s Totals: amount=%s, debits=%d, registers=%d", doc.InitiatingParty.Name, formatAmount(doc.TotalAmount), doc.DebitRegisterCount, doc.TotalRegisterCount)
+}
+func (dp *DatePayment) String() string {
+	return fmt.Sprintf("Payment - date: %s, TotalAmount: %s, TotalDebits: %d", dp.Date, formatAmount(dp.TotalAmount), dp.DebitRegisterCount)
+}
+
+//String returns the debtor with its personal data redacted
+func (d *Debtor) String() string {
+	return fmt.Sprintf("%s(%s)", redact.Name(d.Name), redact.ID(d.ID))
+}
+
+//String returns the transaction with the debtor personal data and the
+//concept redacted
+func (t *DebitTransaction) String() string {
+	return fmt.Sprintf("Debit Amount: %s, Mandate signed: %s, Debtor: %s, Concept: %s", formatAmount(t.Amount), t.MandateSignatureDate.Format("2006-01-02"), redact.Name(t.Debtor.Name), redact.Text(t.Concept))
+}
+
+//formatAmount formats cents as a decimal amount, such as 123.45
+func formatAmount(cents int64) string {
+	return fmt.Sprintf("%d.%02d", cents/100, cents%100)
+}
@@ -0,0 +1,37 @@
+package aeb19
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+//FuzzParse checks the parser returns an error rather than panic on any
+//input, and that what it parses can be written back. Run it with
+//
+//	go test -fuzz FuzzParse ./aeb19
+func FuzzParse(f *testing.F) {
+	sample, err := ioutil.ReadFile("../input-aeb1914.txt")
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(sample)
+	f.Add([]byte(strings.Replace(string(sample), "\n", "\r\n", -1)))
+	f.Add(append([]byte("\xef\xbb\xbf"), sample...))
+	f.Add(sample[:700])
+	f.Add([]byte("\n\n01\n03"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		p := NewParser()
+		p.Warn = func(string) {}
+		doc, err := p.Parse(charmap.ISO8859_1.NewDecoder().Reader(bytes.NewReader(data)))
+		if err != nil {
+			return
+		}
+		if err := doc.Write(ioutil.Discard); err != nil {
+			t.Errorf("Parsed document can not be written: %s", err)
+		}
+	})
+}
@@ -1,78 +1,125 @@
-package aeb1914
+package aeb19
 
 import (
 	"bufio"
 	"fmt"
 	"io"
 	"log"
-	"math"
 	"strconv"
 	"strings"
 	"time"
 )
 
+//RegisterLength is the length of every AEB 19 register
+const RegisterLength = 600
+
+//minLengths are the lengths of each register up to its last mandatory
+//field. Lines as long are padded up to RegisterLength, as many programs strip
+//the trailing blanks
+var minLengths = map[string]int{"01": 158, "02": 299, "03": 437, "04": 80, "05": 72, "99": 37}
+
 //Parser represents the main Parser object
 type Parser struct {
 	doc             *Document
 	currentPayment  *DatePayment
 	currentCreditor *CreditorPayments
+	//Warn receives the non-fatal issues found while parsing, such as
+	//invalid dates. They are logged when nil
+	Warn func(msg string)
+}
+
+//warnf reports a non-fatal issue through Warn
+func (p *Parser) warnf(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	if p.Warn == nil {
+		log.Println(msg)
+		return
+	}
+	p.Warn(msg)
 }
 
-//NewParser returns a sepa1914 Parser
+//NewParser returns an AEB 19 Parser
 func NewParser() *Parser {
 	return &Parser{}
 }
 
-//Parse takes a io.Reader with SEPA 19-14 contents in iso-8859 encoding
+//Parse takes a io.Reader with AEB 19.14 or 19.44 contents in
+//iso-8859 encoding. The variant is detected on register 01. Blank lines,
+//trailing CR and a byte order mark are ignored, and errors tell the line
 func (p *Parser) Parse(r io.Reader) (doc *Document, err error) {
 	p.doc = NewDocument()
+	p.currentCreditor = nil
+	p.currentPayment = nil
 	scanner := bufio.NewScanner(r)
-	for scanner.Scan() {
-		line := []rune(scanner.Text())
-		regCode := string(line[:2])
-		switch regCode {
-		case "01":
-			err = p.parseInitiatingParty(line)
-			if err != nil {
-				log.Println(err)
-				return
-			}
-		case "02":
-			err = p.parsePaymentHeader(line)
-			if err != nil {
-				log.Println(err)
-				return
-			}
-		case "03":
-			err = p.parseDebitTransaction(line)
-			if err != nil {
-				log.Println(err)
-				return
-			}
-		case "04":
-			err = p.parsePaymentTotals(line)
-			if err != nil {
-				log.Println(err)
-				return
-			}
-		case "05":
-			err = p.parseCreditorTotals(line)
-			if err != nil {
-				log.Println(err)
-				return
-			}
-		case "99":
-			err = p.parseTotals(line)
-			if err != nil {
-				log.Println(err)
-				return
-			}
+	for n := 1; scanner.Scan(); n++ {
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if n == 1 {
+			text = trimBOM(text)
 		}
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		if err = p.parseRegister([]rune(text)); err != nil {
+			return nil, fmt.Errorf("Line %d: %s", n, err)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	if p.doc.InitiatingParty == nil {
+		return nil, fmt.Errorf("Missing initiating party register (01)")
 	}
 	doc = p.doc
 	return
 }
 
+//trimBOM removes the UTF-8 byte order mark, either decoded or read as
+//ISO-8859-1
+func trimBOM(s string) string {
+	s = strings.TrimPrefix(s, "\ufeff")
+	return strings.TrimPrefix(s, "\u00ef\u00bb\u00bf")
+}
+
+//parseRegister checks the length of a register and parses it
+func (p *Parser) parseRegister(line []rune) error {
+	if len(line) < 2 {
+		return fmt.Errorf("Register %q too short", string(line))
+	}
+	code := string(line[:2])
+	min, ok := minLengths[code]
+	if !ok {
+		return fmt.Errorf("Unknown register %q", code)
+	}
+	if len(line) < min {
+		return fmt.Errorf("Register %s has %d characters, expected at least %d", code, len(line), min)
+	}
+	if len(line) > RegisterLength {
+		return fmt.Errorf("Register %s has %d characters, expected %d. Check the input encoding", code, len(line), RegisterLength)
+	}
+	line = append(line, []rune(strings.Repeat(" ", RegisterLength-len(line)))...)
+	switch code {
+	case "01":
+		return p.parseInitiatingParty(line)
+	case "02":
+		return p.parsePaymentHeader(line)
+	case "03":
+		return p.parseDebitTransaction(line)
+	case "04":
+		return p.parsePaymentTotals(line)
+	case "05":
+		return p.parseCreditorTotals(line)
+	}
+	return p.parseTotals(line)
+}
+
+//checkVariant checks the variant of a register against the one of register 01
+func (p *Parser) checkVariant(line []rune) error {
+	if v := Variant(line[2:7]); v != p.doc.Variant {
+		return fmt.Errorf("Register %s is %s but the file is %s", string(line[:2]), v, p.doc.Variant)
+	}
+	return nil
+}
+
 func (p *Parser) countRegister() {
 	p.doc.TotalRegisterCount++
 	if p.currentPayment != nil {
@@ -93,7 +140,7 @@ func (p *Parser) countDebitRegister() {
 	}
 }
 
-func (p *Parser) addDebitAmount(amount float64) {
+func (p *Parser) addDebitAmount(amount int64) {
 	p.doc.TotalAmount += amount
 	if p.currentPayment != nil {
 		p.currentPayment.TotalAmount += amount
@@ -107,14 +154,19 @@ func (p *Parser) parseInitiatingParty(line []rune) (err error) {
 	i := &InitiatingParty{}
 	dataNum := string(line[7:10])
 	if dataNum != "001" {
-		return fmt.Errorf("Expected 003 data number but found %s", dataNum)
+		return fmt.Errorf("Expected 001 data number but found %s", dataNum)
+	}
+	p.doc.Variant, err = parseVariant(string(line[2:7]))
+	if err != nil {
+		return
 	}
 	i.ID = getString(line[10:45])
 	i.Name = getString(line[45:115])
 	i.FileID = getString(line[123:158])
 	i.CreationDate, err = getDate(line[115:123])
 	if err != nil {
-		log.Printf("Error parsing file creation date: %s\n", err)
+		p.warnf("Error parsing file creation date: %s", err)
+		err = nil
 	}
 	i.Entity = getString(line[158:162])
 	i.Office = getString(line[162:166])
@@ -129,15 +181,21 @@ func (p *Parser) parsePaymentHeader(line []rune) (err error) {
 	if dataNum != "002" {
 		return fmt.Errorf("Expected 002 datanum for Payment but found %s", dataNum)
 	}
+	if err = p.checkVariant(line); err != nil {
+		return
+	}
+	//the next dates of a creditor repeat its header
 	cp := p.currentCreditor
 	if cp == nil {
 		cp = &CreditorPayments{}
+		p.doc.CreditorPayments = append(p.doc.CreditorPayments, cp)
 	}
 	cp.Creditor.ID = getString(line[10:45])
 	cp.Creditor.Name = getString(line[53:123])
+	//a zero date would be written as the collection date
 	date, err := getDate(line[45:53])
 	if err != nil {
-		log.Printf("Error parsing Payment date: %s\n", err)
+		return fmt.Errorf("Error parsing Payment date: %s", err)
 	}
 	dp := &DatePayment{Date: date}
 	cp.DatePayments = append(cp.DatePayments, dp)
@@ -146,7 +204,6 @@ func (p *Parser) parsePaymentHeader(line []rune) (err error) {
 	cp.Creditor.AddressD3 = getString(line[223:263])
 	cp.Creditor.Country = getString(line[263:265])
 	cp.Creditor.Account = getString(line[265:299])
-	p.doc.CreditorPayments = append(p.doc.CreditorPayments, cp)
 	p.currentCreditor = cp
 	p.currentPayment = dp
 	p.countRegister()
@@ -162,23 +219,26 @@ func (p *Parser) parseDebitTransaction(line []rune) (err error) {
 	if dataNum != "003" {
 		return fmt.Errorf("Expected 003 datanum for debit transaction but found %s", dataNum)
 	}
+	if err = p.checkVariant(line); err != nil {
+		return
+	}
 	t.ID = getString(line[10:45])
 	t.MandateID = getString(line[45:80])
 	t.Sequence = getString(line[80:84])
 	t.CategoryCode = getString(line[84:88])
 	t.Amount, err = getMoney(line[88:99])
 	if err != nil {
-		log.Printf("Error parsing Payment Amount: %s\n", err)
+		return fmt.Errorf("Error parsing debit amount: %s", err)
 	}
-	t.Date, err = getDate(line[99:107])
+	t.MandateSignatureDate, err = getDate(line[99:107])
 	if err != nil {
-		log.Printf("Error parsing Payment date: %s\n", err)
+		return fmt.Errorf("Error parsing mandate signature date: %s", err)
 	}
 	t.Debtor.Entity = getString(line[107:118])
 	t.Debtor.Name = getString(line[118:188])
 	t.Debtor.AddressD1 = getString(line[188:238])
-	t.Debtor.AddressD1 = getString(line[238:288])
-	t.Debtor.AddressD1 = getString(line[288:328])
+	t.Debtor.AddressD2 = getString(line[238:288])
+	t.Debtor.AddressD3 = getString(line[288:328])
 	t.Debtor.Country = getString(line[328:330])
 	t.Debtor.IDType = getString(line[330:331])
 	t.Debtor.ID = getString(line[331:367])
@@ -225,10 +285,9 @@ func (p *Parser) parsePaymentTotals(line []rune) (err error) {
 	if date != p.currentPayment.Date {
 		return fmt.Errorf("Received totals line with different date: %s. (Payment.date=%s)", date, p.currentPayment.Date)
 	}
-	if math.Abs(totalAmount-p.currentPayment.TotalAmount) > 0.01 {
-		return fmt.Errorf("Calculated amount = %f diferent from parsed amount = %f", p.currentPayment.TotalAmount, totalAmount)
+	if totalAmount != p.currentPayment.TotalAmount {
+		return fmt.Errorf("Calculated amount = %s diferent from parsed amount = %s", formatAmount(p.currentPayment.TotalAmount), formatAmount(totalAmount))
 	}
-	p.currentPayment.TotalAmount = totalAmount //write parsed amount, as calculated is a float intended for comprovation
 	//test register count
 	if debitRegisterCount != len(p.currentPayment.DebitTransactions) {
 		return fmt.Errorf("Debit transactions on totals line = %d. Parsed debit transactions = %d", debitRegisterCount, len(p.currentPayment.DebitTransactions))
@@ -263,10 +322,9 @@ func (p *Parser) parseCreditorTotals(line []rune) (err error) {
 	if creditorID != p.currentCreditor.Creditor.ID {
 		return fmt.Errorf("Received totals line with different Creditor ID: %s. Payment.Creditor.ID: %s", creditorID, p.currentCreditor.Creditor.ID)
 	}
-	if math.Abs(totalAmount-p.currentCreditor.TotalAmount) > 0.01 {
-		return fmt.Errorf("Calculated amount = %f diferent from parsed amount = %f", p.currentCreditor.TotalAmount, totalAmount)
+	if totalAmount != p.currentCreditor.TotalAmount {
+		return fmt.Errorf("Calculated amount = %s diferent from parsed amount = %s", formatAmount(p.currentCreditor.TotalAmount), formatAmount(totalAmount))
 	}
-	p.currentCreditor.TotalAmount = totalAmount
 	//test register count
 	if debitRegisterCount != p.currentCreditor.DebitRegisterCount {
 		return fmt.Errorf("Debit transactions on totals line = %d. Parsed debit transactions = %d", debitRegisterCount, p.currentCreditor.DebitRegisterCount)
@@ -293,10 +351,9 @@ func (p *Parser) parseTotals(line []rune) (err error) {
 	if err != nil {
 		return
 	}
-	if math.Abs(totalAmount-p.doc.TotalAmount) > 0.01 {
-		return fmt.Errorf("Calculated amount = %f diferent from parsed amount = %f", p.doc.TotalAmount, totalAmount)
+	if totalAmount != p.doc.TotalAmount {
+		return fmt.Errorf("Calculated amount = %s diferent from parsed amount = %s", formatAmount(p.doc.TotalAmount), formatAmount(totalAmount))
 	}
-	p.doc.TotalAmount = totalAmount
 	if debitRegisterCount != p.doc.DebitRegisterCount {
 		return fmt.Errorf("Debit transactions on totals line = %d. Parsed debit transactions = %d", debitRegisterCount, p.doc.DebitRegisterCount)
 	}
@@ -316,13 +373,21 @@ func getDate(rs []rune) (date time.Time, err error) {
 	return
 }
 
-func getMoney(rs []rune) (amount float64, err error) {
-	amount, err = strconv.ParseFloat(getString(rs), 32)
-	amount = amount / 100
-	return
+//getMoney reads an amount in cents, kept in cents so that totals add up
+//exactly
+func getMoney(rs []rune) (cents int64, err error) {
+	n, err := getInt(rs)
+	return int64(n), err
 }
 
+//getInt reads a number of digits only, rejecting the signs Atoi accepts
 func getInt(rs []rune) (num int, err error) {
-	num, err = strconv.Atoi(getString(rs))
+	s := getString(rs)
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return 0, fmt.Errorf("Invalid number %q", s)
+		}
+	}
+	num, err = strconv.Atoi(s)
 	return
 }
@@ -0,0 +1,117 @@
+package aeb19
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+func TestInitiator(t *testing.T) {
+	f, err := os.Open("../input-aeb1914.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	doc, err := NewParser().Parse(charmap.ISO8859_1.NewDecoder().Reader(f))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.InitiatingParty == nil {
+		t.Fatal("Nil Initiator")
+	}
+	if len(doc.CreditorPayments) != 1 || len(doc.CreditorPayments[0].DatePayments) != 1 || len(doc.CreditorPayments[0].DatePayments[0].DebitTransactions) != 1 {
+		t.Errorf("Expected a creditor with a date and a debit, got %d creditors", len(doc.CreditorPayments))
+	}
+}
+
+func TestDebitTransactionString(t *testing.T) {
+	tx := &DebitTransaction{Amount: 1250, Concept: "CUOTA JUAN GARCIA 2013", Debtor: Debtor{Name: "GARCIA LOPEZ, JUAN"}}
+	s := tx.String()
+	if strings.Contains(s, "GARCIA") || strings.Contains(s, "2013") || !strings.Contains(s, "Concept: ***** **** ****** ****") || !strings.HasPrefix(s, "Debit Amount: 12.50,") {
+		t.Errorf("Expected the debtor and the concept redacted, got %s", s)
+	}
+}
+
+//setField replaces the characters of line from start with value
+func setField(line string, start int, value string) string {
+	rs := []rune(line)
+	copy(rs[start:], []rune(value))
+	return string(rs)
+}
+
+func TestParseCreditorDates(t *testing.T) {
+	b, err := ioutil.ReadFile("../input-aeb1914.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimRight(string(b), "\n"), "\n")
+	//a second date of the creditor, with the largest amount of a debit
+	second := []string{
+		setField(lines[1], 45, "20131227"),
+		setField(setField(lines[2], 10, "RECIBO002402"), 88, "99999999999"),
+		setField(setField(lines[3], 37, "20131227"), 45, "00000099999999999"),
+	}
+	input := append(append(lines[:4:4], second...),
+		setField(lines[4], 37, "00000100000012344"+"00000002"+"0000000007"),
+		setField(lines[5], 2, "00000100000012344"+"00000002"+"0000000009"))
+	doc, err := NewParser().Parse(strings.NewReader(strings.Join(input, "\n") + "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.CreditorPayments) != 1 || len(doc.CreditorPayments[0].DatePayments) != 2 {
+		t.Fatalf("Expected a creditor with 2 dates, got %d creditors", len(doc.CreditorPayments))
+	}
+	cp := doc.CreditorPayments[0]
+	if amount := cp.DatePayments[1].DebitTransactions[0].Amount; amount != 99999999999 {
+		t.Errorf("Expected 99999999999 cents, got %d", amount)
+	}
+	if cp.TotalAmount != 100000012344 || doc.TotalAmount != 100000012344 || cp.DebitRegisterCount != 2 {
+		t.Errorf("Wrong totals %s", doc)
+	}
+	//amounts are exact cents: a total one cent off is an error
+	input[len(input)-1] = setField(lines[5], 2, "00000100000012345")
+	if _, err := NewParser().Parse(strings.NewReader(strings.Join(input, "\n") + "\n")); err == nil {
+		t.Error("Expected an error for a total one cent off")
+	}
+}
+
+func TestParseDamaged(t *testing.T) {
+	b, err := ioutil.ReadFile("../input-aeb1914.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := string(b)
+	lines := strings.Split(strings.TrimRight(valid, "\n"), "\n")
+	for name, input := range map[string]string{
+		"crlf":         strings.Replace(valid, "\n", "\r\n", -1),
+		"bom":          "ï»¿" + valid,
+		"blank lines":  "\n" + strings.Join(lines, "\n\n   \n"),
+		"no trailing":  strings.Join(lines, "\n"),
+		"stripped 03":  strings.Replace(valid, lines[2], strings.TrimRight(lines[2], " "), 1),
+		"padded 04/99": strings.Replace(strings.Replace(valid, lines[3], lines[3][:80], 1), lines[5], lines[5][:37], 1),
+	} {
+		if _, err := NewParser().Parse(strings.NewReader(input)); err != nil {
+			t.Errorf("%s: %s", name, err)
+		}
+	}
+	for name, input := range map[string]string{
+		"empty":        "",
+		"short line":   strings.Replace(valid, lines[2], lines[2][:200], 1),
+		"one char":     strings.Replace(valid, lines[2], "0", 1),
+		"long line":    strings.Replace(valid, lines[2], lines[2]+"Ã©", 1),
+		"garbage":      valid[:len(lines[0])+1] + "\xc1\xc5\xc2\x40\xf1\xf9\n" + valid[len(lines[0])+1:],
+		"signed total": strings.Replace(valid, "9900000000000012345", "99-0000000000012345", 1),
+		"payment date": strings.Replace(valid, lines[1], setField(lines[1], 45, "2013XX20"), 1),
+		"mandate date": strings.Replace(valid, lines[2], setField(lines[2], 99, "        "), 1),
+	} {
+		_, err := NewParser().Parse(strings.NewReader(input))
+		if err == nil {
+			t.Errorf("%s: expected error", name)
+		} else if name != "empty" && !strings.HasPrefix(err.Error(), "Line ") {
+			t.Errorf("%s: expected the line on error %q", name, err)
+		}
+	}
+}
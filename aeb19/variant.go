@@ -0,0 +1,48 @@
+package aeb19
+
+import "fmt"
+
+//Variant is the AEB 19 norm and version of a file, as found on positions
+//3-7 of registers 01, 02 and 03
+type Variant string
+
+const (
+	AEB1914 Variant = "19143" // AEB 19.14, SEPA Core direct debits
+	AEB1944 Variant = "19445" // AEB 19.44, SEPA B2B direct debits
+)
+
+//aeb1915 is the code of AEB 19.15 files, SEPA Core direct debits with
+//financing. They are rejected until their parser, their financing fields and
+//the profile elements of financed remittances are implemented from the AEB
+//specification: a file must not be converted with its financing request lost
+const aeb1915 = "19154"
+
+//Variants are the supported variants
+var Variants = []Variant{AEB1914, AEB1944}
+
+func (v Variant) String() string {
+	switch v {
+	case AEB1914:
+		return "AEB 19.14"
+	case AEB1944:
+		return "AEB 19.44"
+	}
+	return "unknown AEB 19 variant " + string(v)
+}
+
+//IsB2B reports whether the file holds B2B scheme direct debits
+func (v Variant) IsB2B() bool {
+	return v == AEB1944
+}
+
+func parseVariant(code string) (Variant, error) {
+	for _, v := range Variants {
+		if code == string(v) {
+			return v, nil
+		}
+	}
+	if code == aeb1915 {
+		return "", fmt.Errorf("AEB 19.15 (financing) files are not supported yet")
+	}
+	return "", fmt.Errorf("Unsupported AEB 19 variant %s on register 01", code)
+}
@@ -0,0 +1,54 @@
+package aeb19
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+//readVariant returns the test file rewritten as variant v
+func readVariant(t *testing.T, v Variant) []string {
+	b, err := ioutil.ReadFile("../input-aeb1914.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimRight(string(b), "\n"), "\n")
+	for i, line := range lines {
+		switch line[:2] {
+		case "01", "02", "03":
+			lines[i] = line[:2] + string(v) + line[7:]
+		}
+	}
+	return lines
+}
+
+func TestVariants(t *testing.T) {
+	for _, v := range Variants {
+		doc, err := NewParser().Parse(strings.NewReader(strings.Join(readVariant(t, v), "\n")))
+		if err != nil {
+			t.Fatalf("%s: %s", v, err)
+		}
+		if doc.Variant != v {
+			t.Errorf("Expected variant %s, got %s", v, doc.Variant)
+		}
+	}
+}
+
+func TestAEB1915Unsupported(t *testing.T) {
+	lines := readVariant(t, aeb1915)
+	if _, err := NewParser().Parse(strings.NewReader(strings.Join(lines, "\n"))); err == nil || !strings.Contains(err.Error(), "19.15") {
+		t.Errorf("Expected an error for an AEB 19.15 file, got %v", err)
+	}
+}
+
+func TestVariantMismatch(t *testing.T) {
+	lines := readVariant(t, AEB1914)
+	lines[2] = lines[2][:2] + string(AEB1944) + lines[2][7:]
+	if _, err := NewParser().Parse(strings.NewReader(strings.Join(lines, "\n"))); err == nil {
+		t.Error("Expected an error for a 19.44 register in a 19.14 file")
+	}
+	lines = readVariant(t, "19999")
+	if _, err := NewParser().Parse(strings.NewReader(strings.Join(lines, "\n"))); err == nil {
+		t.Error("Expected an error for an unknown variant")
+	}
+}
@@ -0,0 +1,155 @@
+package aeb19
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+//register builds a fixed width register, keeping the first field that does
+//not fit in its width as err
+type register struct {
+	rs  []rune
+	err error
+}
+
+func newRegister(head string) *register {
+	return &register{rs: []rune(head)}
+}
+
+//text appends s left aligned on width characters
+func (r *register) text(s string, width int) {
+	rs := []rune(s)
+	if len(rs) > width {
+		//the value is not shown, as it may be a name or an address
+		r.fail("text of %d characters at position %d, longer than %d", len(rs), len(r.rs)+1, width)
+		rs = rs[:width]
+	}
+	r.rs = append(r.rs, rs...)
+	r.rs = append(r.rs, []rune(strings.Repeat(" ", width-len(rs)))...)
+}
+
+//number appends n zero padded on width digits
+func (r *register) number(n int64, width int) {
+	s := fmt.Sprintf("%0*d", width, n)
+	if n < 0 || len(s) > width {
+		r.fail("number %d at position %d, out of %d digits", n, len(r.rs)+1, width)
+		s = strings.Repeat("9", width)
+	}
+	r.text(s, width)
+}
+
+//date appends t as YYYYMMDD, or blanks if t is zero
+func (r *register) date(t time.Time) {
+	if t.IsZero() {
+		r.text("", 8)
+		return
+	}
+	r.text(t.Format("20060102"), 8)
+}
+
+func (r *register) fail(format string, args ...interface{}) {
+	if r.err == nil {
+		r.err = fmt.Errorf("Register %s: "+format, append([]interface{}{string(r.rs[:2])}, args...)...)
+	}
+}
+
+func (r *register) String() string {
+	return string(r.rs) + strings.Repeat(" ", RegisterLength-len(r.rs))
+}
+
+//Write writes the document as AEB 19 text in ISO-8859-1, a register per
+//line. The totals and register counts are written as found on the document.
+//A text or number longer than its field is an error, as cutting it would
+//change the debit
+func (doc *Document) Write(w io.Writer) error {
+	ip := doc.InitiatingParty
+	if ip == nil {
+		return fmt.Errorf("Document without initiating party")
+	}
+	bw := bufio.NewWriter(charmap.ISO8859_1.NewEncoder().Writer(w))
+	var err error
+	put := func(r *register) {
+		if err == nil {
+			err = r.err
+		}
+		if err == nil {
+			_, err = bw.WriteString(r.String() + "\n")
+		}
+	}
+	v := string(doc.Variant)
+
+	r := newRegister("01" + v + "001")
+	r.text(ip.ID, 35)
+	r.text(ip.Name, 70)
+	r.date(ip.CreationDate)
+	r.text(ip.FileID, 35)
+	r.text(ip.Entity, 4)
+	r.text(ip.Office, 4)
+	put(r)
+	for _, cp := range doc.CreditorPayments {
+		c := cp.Creditor
+		for _, dp := range cp.DatePayments {
+			r = newRegister("02" + v + "002")
+			r.text(c.ID, 35)
+			r.date(dp.Date)
+			r.text(c.Name, 70)
+			r.text(c.AddressD1, 50)
+			r.text(c.AddressD2, 50)
+			r.text(c.AddressD3, 40)
+			r.text(c.Country, 2)
+			r.text(c.Account, 34)
+			put(r)
+			for _, t := range dp.DebitTransactions {
+				d := t.Debtor
+				r = newRegister("03" + v + "003")
+				r.text(t.ID, 35)
+				r.text(t.MandateID, 35)
+				r.text(t.Sequence, 4)
+				r.text(t.CategoryCode, 4)
+				r.number(t.Amount, 11)
+				r.date(t.MandateSignatureDate)
+				r.text(d.Entity, 11)
+				r.text(d.Name, 70)
+				r.text(d.AddressD1, 50)
+				r.text(d.AddressD2, 50)
+				r.text(d.AddressD3, 40)
+				r.text(d.Country, 2)
+				r.text(d.IDType, 1)
+				r.text(d.ID, 36)
+				r.text(d.IDTXCode, 35)
+				r.text(d.AccountID, 1)
+				r.text(d.Account, 34)
+				r.text(t.Purpose, 4)
+				r.text(t.Concept, 140)
+				put(r)
+			}
+			r = newRegister("04")
+			r.text(c.ID, 35)
+			r.date(dp.Date)
+			r.number(dp.TotalAmount, 17)
+			r.number(int64(dp.DebitRegisterCount), 8)
+			r.number(int64(dp.TotalRegisterCount), 10)
+			put(r)
+		}
+		r = newRegister("05")
+		r.text(c.ID, 35)
+		r.number(cp.TotalAmount, 17)
+		r.number(int64(cp.DebitRegisterCount), 8)
+		r.number(int64(cp.TotalRegisterCount), 10)
+		put(r)
+	}
+	r = newRegister("99")
+	r.number(doc.TotalAmount, 17)
+	r.number(int64(doc.DebitRegisterCount), 8)
+	r.number(int64(doc.TotalRegisterCount), 10)
+	put(r)
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
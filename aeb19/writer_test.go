@@ -0,0 +1,96 @@
+package aeb19
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+func TestWriteRoundTrip(t *testing.T) {
+	want, err := ioutil.ReadFile("../input-aeb1914.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := NewParser().Parse(charmap.ISO8859_1.NewDecoder().Reader(bytes.NewReader(want)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := doc.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != strings.TrimRight(string(want), "\n")+"\n" {
+		t.Errorf("Written document differs from input:\n%s", got)
+	}
+}
+
+func TestWriteDates(t *testing.T) {
+	date := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	doc := &Document{
+		Variant:         AEB1914,
+		InitiatingParty: &InitiatingParty{ID: "ES26000B12345674", Name: "CLUB NÁUTICO, S.L.", CreationDate: date},
+		TotalAmount:     1234567890,
+		// 01, 02, 03, 04, 02, 03, 04, 05, 99
+		TotalRegisterCount: 9,
+		DebitRegisterCount: 2,
+	}
+	cp := &CreditorPayments{Creditor: Creditor{ID: "ES26000B12345674", Name: "CLUB NÁUTICO, S.L.", Account: "ES9121000418450200051332"}, TotalAmount: 1234567890, DebitRegisterCount: 2, TotalRegisterCount: 7}
+	for i, amount := range []int64{1234567801, 89} {
+		dp := &DatePayment{Date: date.AddDate(0, i, 0), TotalAmount: amount, DebitRegisterCount: 1, TotalRegisterCount: 3}
+		dp.DebitTransactions = []*DebitTransaction{{ID: "R1", MandateID: "M1", Sequence: "RCUR", Amount: amount, MandateSignatureDate: date, Debtor: Debtor{Name: "JOSÉ MUÑOZ", AccountID: "A", Account: "ES7620770024003102575766"}}}
+		cp.DatePayments = append(cp.DatePayments, dp)
+	}
+	doc.CreditorPayments = []*CreditorPayments{cp}
+	var buf bytes.Buffer
+	if err := doc.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := NewParser().Parse(charmap.ISO8859_1.NewDecoder().Reader(&buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed.CreditorPayments) != 1 || len(parsed.CreditorPayments[0].DatePayments) != 2 {
+		t.Fatalf("Expected a creditor with 2 dates, got %d creditors", len(parsed.CreditorPayments))
+	}
+	if parsed.TotalAmount != doc.TotalAmount || parsed.TotalRegisterCount != 9 {
+		t.Errorf("Wrong totals %s", parsed)
+	}
+	if name := parsed.CreditorPayments[0].DatePayments[1].DebitTransactions[0].Debtor.Name; name != "JOSÉ MUÑOZ" {
+		t.Errorf("Wrong debtor name %s", name)
+	}
+}
+
+func TestWriteOverflow(t *testing.T) {
+	input, err := ioutil.ReadFile("../input-aeb1914.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	debit := func(doc *Document) *DebitTransaction {
+		return doc.CreditorPayments[0].DatePayments[0].DebitTransactions[0]
+	}
+	for name, test := range map[string]struct {
+		change   func(doc *Document)
+		register string
+	}{
+		"amount":         {func(doc *Document) { debit(doc).Amount = 1e11 }, "Register 03"},
+		"negative":       {func(doc *Document) { debit(doc).Amount = -1 }, "Register 03"},
+		"debtor name":    {func(doc *Document) { debit(doc).Debtor.Name = strings.Repeat("A", 71) }, "Register 03"},
+		"total":          {func(doc *Document) { doc.TotalAmount = 1e17 }, "Register 99"},
+		"register count": {func(doc *Document) { doc.CreditorPayments[0].DatePayments[0].TotalRegisterCount = 1e10 }, "Register 04"},
+		"creditor id":    {func(doc *Document) { doc.CreditorPayments[0].Creditor.ID = strings.Repeat("A", 36) }, "Register 02"},
+	} {
+		doc, err := NewParser().Parse(charmap.ISO8859_1.NewDecoder().Reader(bytes.NewReader(input)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		test.change(doc)
+		var buf bytes.Buffer
+		if err := doc.Write(&buf); err == nil || !strings.HasPrefix(err.Error(), test.register) {
+			t.Errorf("%s: expected a %s error, got %v", name, test.register, err)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+//Package aeb1914 is kept for compatibility. The parser now lives in package
+//aeb19, which also reads the AEB 19.44 variant.
+package aeb1914
+
+import "github.com/apsl/sepakit/aeb19"
+
+//Aliases of the aeb19 types
+type (
+	Document         = aeb19.Document
+	Parser           = aeb19.Parser
+	InitiatingParty  = aeb19.InitiatingParty
+	Creditor         = aeb19.Creditor
+	Debtor           = aeb19.Debtor
+	DebitTransaction = aeb19.DebitTransaction
+	DatePayment      = aeb19.DatePayment
+	CreditorPayments = aeb19.CreditorPayments
+)
+
+//NewParser returns an aeb19 Parser
+func NewParser() *Parser {
+	return aeb19.NewParser()
+}
+
+//NewDocument returns an *aeb19.Document
+func NewDocument() *Document {
+	return aeb19.NewDocument()
+}
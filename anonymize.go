@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/apsl/sepakit/anonymize"
+)
+
+//runAnonymize implements "sepakit anonymize": writes an AEB 19, CSB 19 or
+//pain.008 file with fake personal data, for bug reports
+func runAnonymize(args []string) error {
+	fs := flag.NewFlagSet("anonymize", flag.ExitOnError)
+	seed := fs.Int64("seed", 0, "seed of the fake data, for the same output on every run (random if 0)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Replaces names, addresses, accounts, identifiers and concepts of an AEB 19, CSB 19 or SEPA XML file with fake but valid ones\nUsage: %s anonymize [-seed N] [INFILE] [OUTFILE]\nDefaults to stdin and stdout (-)\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	paths := parseArgs(fs, args)
+	if len(paths) > 2 {
+		fs.Usage()
+		return fmt.Errorf("anonymize needs at most two files")
+	}
+	inpath, outpath := "-", "-"
+	if len(paths) > 0 {
+		inpath = paths[0]
+	}
+	if len(paths) > 1 {
+		outpath = paths[1]
+	}
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+
+	fin, err := openInput(inpath)
+	if err != nil {
+		return err
+	}
+	defer fin.Close()
+	var buf bytes.Buffer
+	if _, err := anonymize.New(*seed).Anonymize(fin, &buf); err != nil {
+		return err
+	}
+	if outpath == "-" {
+		_, err = buf.WriteTo(os.Stdout)
+		return err
+	}
+	return ioutil.WriteFile(outpath, buf.Bytes(), 0644)
+}
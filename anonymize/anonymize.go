@@ -0,0 +1,432 @@
+//Package anonymize replaces the personal data of AEB 19, CSB 19 and pain.008
+//files with fake but valid data, so that they can be shared in bug reports.
+//Names, addresses, accounts, identifiers, references and concepts are
+//replaced, while amounts, dates, codes and the layout of the file are kept.
+//The same value is always replaced with the same fake one, so that the
+//registers still refer to each other, and accounts keep their bank entity.
+package anonymize
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"strings"
+
+	"github.com/apsl/sepakit/ccc"
+	"github.com/apsl/sepakit/sepadebit"
+	"golang.org/x/text/encoding/charmap"
+)
+
+//Anonymizer generates the fake values. The same seed gives the same fakes
+//for the same inputs
+type Anonymizer struct {
+	rnd    *rand.Rand
+	fakes  map[string]string
+	counts map[string]int
+}
+
+//New returns an Anonymizer
+func New(seed int64) *Anonymizer {
+	return &Anonymizer{
+		rnd:    rand.New(rand.NewSource(seed)),
+		fakes:  map[string]string{},
+		counts: map[string]int{},
+	}
+}
+
+//fake returns the fake value of kind for value, generating it the first time
+func (a *Anonymizer) fake(kind, value string, generate func() string) string {
+	if value == "" {
+		return ""
+	}
+	key := kind + "|" + value
+	if f, ok := a.fakes[key]; ok {
+		return f
+	}
+	f := generate()
+	a.fakes[key] = f
+	return f
+}
+
+//Text returns a numbered text for the free texts of a kind, such as
+//"DEUDOR 0001" for the first debtor name of kind "DEUDOR"
+func (a *Anonymizer) Text(kind, value string) string {
+	return a.fake(kind, value, func() string {
+		a.counts[kind]++
+		return fmt.Sprintf("%s %04d", kind, a.counts[kind])
+	})
+}
+
+//Reference returns a numbered reference without spaces, such as
+//"RECIBO000001", of at most n characters
+func (a *Anonymizer) Reference(kind, value string, n int) string {
+	return a.fake(kind, value, func() string {
+		a.counts[kind]++
+		ref := fmt.Sprintf("%s%06d", kind, a.counts[kind])
+		if len(ref) > n {
+			ref = ref[len(ref)-n:]
+		}
+		return ref
+	})
+}
+
+//digits returns n random digits
+func (a *Anonymizer) digits(n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte('0' + a.rnd.Intn(10))
+	}
+	return string(b)
+}
+
+//like returns a random text with the digits and letters of s at the same
+//positions
+func (a *Anonymizer) like(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		switch {
+		case c >= '0' && c <= '9':
+			b[i] = byte('0' + a.rnd.Intn(10))
+		case c >= 'A' && c <= 'Z':
+			b[i] = byte('A' + a.rnd.Intn(26))
+		case c >= 'a' && c <= 'z':
+			b[i] = byte('a' + a.rnd.Intn(26))
+		}
+	}
+	return string(b)
+}
+
+//PostCode returns a fake post code with the digits and letters of code at
+//the same positions
+func (a *Anonymizer) PostCode(code string) string {
+	return a.fake("CP", code, func() string { return a.like(code) })
+}
+
+//CCC returns a fake CCC of the same entity, with valid check digits
+func (a *Anonymizer) CCC(account string) string {
+	return a.fake("CCC", account, func() string {
+		entity := a.digits(4)
+		if len(account) >= 4 {
+			entity = account[:4]
+		}
+		return ccc.Build(entity, a.digits(4), a.digits(10))
+	})
+}
+
+//IBAN returns a fake IBAN of the same country, with valid check digits.
+//Spanish IBANs keep their bank entity
+func (a *Anonymizer) IBAN(iban string) string {
+	iban = strings.Replace(iban, " ", "", -1)
+	return a.fake("IBAN", iban, func() string {
+		if len(iban) == 24 && strings.HasPrefix(iban, "ES") {
+			return ccc.NewIBAN("ES", a.CCC(iban[4:]))
+		}
+		if len(iban) < 5 {
+			return a.like(iban)
+		}
+		return ccc.NewIBAN(iban[:2], a.like(iban[4:]))
+	})
+}
+
+//NIF returns a fake Spanish NIF of the same kind (DNI, NIE or CIF), with a
+//valid control character. Other identifiers are replaced with random
+//characters
+func (a *Anonymizer) NIF(nif string) string {
+	nif = strings.ToUpper(nif)
+	return a.fake("NIF", nif, func() string {
+		if len(nif) != 9 {
+			return a.like(nif)
+		}
+		switch first := nif[0]; {
+		case first >= '0' && first <= '9':
+			return ccc.DNI(a.digits(8))
+		case first == 'X' || first == 'Y' || first == 'Z':
+			return ccc.DNI(string(first) + a.digits(7))
+		case first >= 'A' && first <= 'W':
+			return ccc.CIF(first, a.digits(7))
+		}
+		return a.like(nif)
+	})
+}
+
+//CreditorID returns a fake SEPA creditor identifier (AT-02) with the same
+//country and business code, a fake national identifier and valid check
+//digits. Other identifiers are faked as NIFs
+func (a *Anonymizer) CreditorID(id string) string {
+	return a.fake("CreditorID", id, func() string {
+		if len(id) < 8 || !isLetters(id[:2]) || !isDigits(id[2:4]) {
+			return a.NIF(id)
+		}
+		national := a.NIF(id[7:])
+		return ccc.NewIBAN(id[:2], national)[:4] + id[4:7] + national
+	})
+}
+
+func isLetters(s string) bool {
+	for _, c := range s {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+//Anonymize reads an AEB 19, CSB 19 or pain.008 file from r and writes it
+//anonymized to w in the same format, which is returned
+func (a *Anonymizer) Anonymize(r io.Reader, w io.Writer) (string, error) {
+	br := bufio.NewReader(r)
+	peek, _ := br.Peek(512)
+	trimmed := bytes.TrimLeft(peek, " \t\r\n\xef\xbb\xbf")
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("<")):
+		return "pain.008", a.Pain008(br, w)
+	case bytes.HasPrefix(trimmed, []byte("5180")):
+		return "csb19", a.CSB19(br, w)
+	case bytes.HasPrefix(trimmed, []byte("01")):
+		return "aeb19", a.AEB19(br, w)
+	}
+	return "", fmt.Errorf("Unknown input format, expected AEB 19, CSB 19 or pain.008")
+}
+
+//Pain008 anonymizes a pain.008 document
+func (a *Anonymizer) Pain008(r io.Reader, w io.Writer) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	doc, err := sepadebit.ReadDocument(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	a.Document(doc)
+	enc := sepadebit.UTF8
+	if header := strings.ToLower(string(data[:bytes.IndexByte(append(data, '\n'), '\n')])); strings.Contains(header, "iso-8859-1") {
+		enc = sepadebit.Latin1
+	}
+	return doc.Write(w, enc)
+}
+
+//Document anonymizes the parties, accounts, identifiers and remittance
+//information of doc
+func (a *Anonymizer) Document(doc *sepadebit.Document) {
+	doc.InitiatingParty.Name = a.Text("PRESENTADOR", doc.InitiatingParty.Name)
+	doc.InitiatingParty.ID = a.CreditorID(doc.InitiatingParty.ID)
+	for _, p := range doc.Payments {
+		if c := p.Creditor; c != nil {
+			c.Name = a.Text("ACREEDOR", c.Name)
+			c.IBAN = a.IBAN(c.IBAN)
+			c.ID = a.CreditorID(c.ID)
+			a.party(c.UltimateCreditor)
+			a.address(c.PostalAddress)
+		}
+		for i := range p.Transactions {
+			t := &p.Transactions[i]
+			t.ID = a.Reference("RECIBO", t.ID, 35)
+			t.Debtor.Name = a.Text("DEUDOR", t.Debtor.Name)
+			t.Debtor.IBAN = a.IBAN(t.Debtor.IBAN)
+			t.Mandate.ID = a.Reference("MANDATO", t.Mandate.ID, 35)
+			t.Mandate.ElectronicSignature = a.Reference("FIRMA", t.Mandate.ElectronicSignature, 1025)
+			if am := t.Mandate.Amendment; am != nil {
+				am.OriginalMandateID = a.Reference("MANDATO", am.OriginalMandateID, 35)
+				if am.OriginalDebtorAccount != nil {
+					am.OriginalDebtorAccount.IBAN = a.IBAN(am.OriginalDebtorAccount.IBAN)
+				}
+				if cs := am.OriginalCreditorScheme; cs != nil {
+					cs.Name = a.Text("ACREEDOR", cs.Name)
+					if cs.ID != nil {
+						cs.ID.ID = a.CreditorID(cs.ID.ID)
+					}
+				}
+			}
+			a.party(t.UltimateCreditor)
+			a.party(t.UltimateDebtor)
+			for j, line := range t.RemittanceInfo {
+				t.RemittanceInfo[j] = a.Text("CONCEPTO", line)
+			}
+		}
+	}
+}
+
+//address anonymizes a postal address as the address fields of the text
+//formats: streets and address lines are DIRECCION texts, towns POBLACION
+//texts and post codes keep their format. The country is kept
+func (a *Anonymizer) address(addr *sepadebit.PostalAddress) {
+	if addr == nil {
+		return
+	}
+	addr.StreetName = a.Text("DIRECCION", addr.StreetName)
+	addr.BuildingNumber = a.Reference("", addr.BuildingNumber, 16)
+	addr.PostCode = a.PostCode(addr.PostCode)
+	addr.TownName = a.Text("POBLACION", addr.TownName)
+	addr.CountrySubDivision = a.Text("PROVINCIA", addr.CountrySubDivision)
+	for i, line := range addr.AddressLines {
+		addr.AddressLines[i] = a.Text("DIRECCION", line)
+	}
+}
+
+//party anonymizes an ultimate creditor or debtor
+func (a *Anonymizer) party(p *sepadebit.Party) {
+	if p == nil {
+		return
+	}
+	p.Name = a.Text("TITULAR", p.Name)
+	for _, id := range []*sepadebit.OtherID{p.OrgID, p.PrvtID} {
+		if id != nil {
+			id.ID = a.CreditorID(id.ID)
+		}
+	}
+}
+
+//field is a fixed width field of a text register, in characters
+type field struct {
+	start, end int
+	fake       func(a *Anonymizer, value string) string
+}
+
+func text(kind string) func(a *Anonymizer, value string) string {
+	return func(a *Anonymizer, value string) string { return a.Text(kind, value) }
+}
+
+func reference(kind string, n int) func(a *Anonymizer, value string) string {
+	return func(a *Anonymizer, value string) string { return a.Reference(kind, value, n) }
+}
+
+func (a *Anonymizer) fakeIBAN(value string) string       { return a.IBAN(value) }
+func (a *Anonymizer) fakeCCC(value string) string        { return a.CCC(value) }
+func (a *Anonymizer) fakePostCode(value string) string   { return a.PostCode(value) }
+func (a *Anonymizer) fakeNIF(value string) string        { return a.NIF(value) }
+func (a *Anonymizer) fakeCreditorID(value string) string { return a.CreditorID(value) }
+
+//aeb19Fields are the personal fields of the AEB 19.14 and 19.44
+//registers, by register code
+var aeb19Fields = map[string][]field{
+	"01": {{10, 45, (*Anonymizer).fakeCreditorID}, {45, 115, text("PRESENTADOR")}},
+	"02": {
+		{10, 45, (*Anonymizer).fakeCreditorID}, {53, 123, text("ACREEDOR")},
+		{123, 173, text("DIRECCION")}, {173, 223, text("DIRECCION")}, {223, 263, text("DIRECCION")},
+		{265, 299, (*Anonymizer).fakeIBAN},
+	},
+	"03": {
+		{10, 45, reference("RECIBO", 35)}, {45, 80, reference("MANDATO", 35)},
+		{118, 188, text("DEUDOR")},
+		{188, 238, text("DIRECCION")}, {238, 288, text("DIRECCION")}, {288, 328, text("DIRECCION")},
+		{331, 367, (*Anonymizer).fakeNIF}, {367, 402, reference("ID", 35)},
+		{403, 437, (*Anonymizer).fakeIBAN},
+		{441, 581, text("CONCEPTO")},
+	},
+	"04": {{2, 37, (*Anonymizer).fakeCreditorID}},
+	"05": {{2, 37, (*Anonymizer).fakeCreditorID}},
+	"99": nil,
+}
+
+//csb19Fields are the personal fields of the CSB 19 registers, by register
+//code. Every register starts with the NIF of the presenter or orderer
+var csb19Fields = map[string][]field{
+	"5180": {{28, 68, text("PRESENTADOR")}},
+	"5380": {{28, 68, text("ORDENANTE")}, {68, 88, (*Anonymizer).fakeCCC}},
+	"5680": {
+		{16, 28, reference("REF", 12)}, {28, 68, text("DEUDOR")}, {68, 88, (*Anonymizer).fakeCCC},
+		{104, 114, reference("INT", 10)}, {114, 154, text("CONCEPTO")},
+	},
+	"5681": csb19Concepts,
+	"5682": csb19Concepts,
+	"5683": csb19Concepts,
+	"5684": csb19Concepts,
+	"5685": csb19Concepts,
+	//debtor name, address, town and post code
+	"5686": {{16, 28, reference("REF", 12)}, {28, 68, text("DEUDOR")}, {68, 108, text("DIRECCION")}, {108, 143, text("POBLACION")}, {143, 148, (*Anonymizer).fakePostCode}},
+	"5880": nil,
+	"5980": nil,
+}
+
+var csb19Concepts = []field{{16, 28, reference("REF", 12)}, {28, 68, text("CONCEPTO")}, {68, 108, text("CONCEPTO")}, {108, 148, text("CONCEPTO")}}
+
+//AEB19 anonymizes an ISO-8859-1 AEB 19 file
+func (a *Anonymizer) AEB19(r io.Reader, w io.Writer) error {
+	return a.registers(r, w, 2, aeb19Fields)
+}
+
+//CSB19 anonymizes an ISO-8859-1 CSB 19 file
+func (a *Anonymizer) CSB19(r io.Reader, w io.Writer) error {
+	nif := field{4, 13, (*Anonymizer).fakeNIF}
+	fields := map[string][]field{}
+	for code, fs := range csb19Fields {
+		fields[code] = append([]field{nif}, fs...)
+	}
+	return a.registers(r, w, 4, fields)
+}
+
+//registers anonymizes the fields of the registers of a text file, keeping
+//their lengths and line endings
+func (a *Anonymizer) registers(r io.Reader, w io.Writer, codeLength int, fields map[string][]field) error {
+	br := bufio.NewReader(charmap.ISO8859_1.NewDecoder().Reader(r))
+	ew := charmap.ISO8859_1.NewEncoder().Writer(w)
+	for n := 1; ; n++ {
+		line, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if line == "" {
+			return nil
+		}
+		body := strings.TrimRight(line, "\r\n")
+		ending := line[len(body):]
+		if rs := []rune(body); len(rs) >= codeLength {
+			fs, ok := fields[string(rs[:codeLength])]
+			if !ok {
+				return fmt.Errorf("Line %d: unknown register %s", n, string(rs[:codeLength]))
+			}
+			body = a.register(rs, fs)
+		}
+		if _, err := io.WriteString(ew, body+ending); err != nil {
+			return err
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+//register replaces the fields of a register with their fakes, truncated or
+//padded to the field widths
+func (a *Anonymizer) register(rs []rune, fields []field) string {
+	length := len(rs)
+	for _, f := range fields {
+		if f.start >= len(rs) {
+			continue
+		}
+		for len(rs) < f.end {
+			rs = append(rs, ' ')
+		}
+		value := strings.TrimSpace(string(rs[f.start:f.end]))
+		fake := []rune(f.fake(a, value))
+		width := f.end - f.start
+		if len(fake) > width {
+			fake = fake[:width]
+		}
+		for i := 0; i < width; i++ {
+			if i < len(fake) {
+				rs[f.start+i] = fake[i]
+			} else {
+				rs[f.start+i] = ' '
+			}
+		}
+	}
+	//the trailing spaces the original did not have
+	for len(rs) > length && rs[len(rs)-1] == ' ' {
+		rs = rs[:len(rs)-1]
+	}
+	return string(rs)
+}
@@ -0,0 +1,139 @@
+package anonymize
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/apsl/sepakit/aeb19"
+	"github.com/apsl/sepakit/ccc"
+	"github.com/apsl/sepakit/csb19"
+	"github.com/apsl/sepakit/sepadebit"
+)
+
+func anonymize(t *testing.T, path string, seed int64) []byte {
+	input, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if _, err := New(seed).Anonymize(bytes.NewReader(input), &out); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Count(out.Bytes(), []byte("\n")) != bytes.Count(input, []byte("\n")) {
+		t.Errorf("%s: the number of lines changed", path)
+	}
+	return out.Bytes()
+}
+
+func TestAEB19(t *testing.T) {
+	out := anonymize(t, "../input-aeb1914.txt", 1)
+	if !bytes.Equal(out, anonymize(t, "../input-aeb1914.txt", 1)) {
+		t.Error("Expected the same output with the same seed")
+	}
+	for _, clear := range []string{"DEUDOR, S.L.", "ES0321001234561234567890", "885c81c2d215a71b195847b9d86cf2c1", "E77846772"} {
+		if bytes.Contains(out, []byte(clear)) {
+			t.Errorf("%s left in clear", clear)
+		}
+	}
+	doc, err := aeb19.NewParser().Parse(bytes.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cp := doc.CreditorPayments[0]
+	tx := cp.DatePayments[0].DebitTransactions[0]
+	if err := ccc.ValidateIBAN(tx.Debtor.Account); err != nil {
+		t.Error(err)
+	}
+	if err := ccc.ValidateIBAN(cp.Creditor.Account); err != nil {
+		t.Error(err)
+	}
+	if tx.Debtor.Name != "DEUDOR 0001" || tx.Amount != 12345 || doc.TotalAmount != 12345 || !strings.HasPrefix(tx.Debtor.Account, "ES") || tx.Debtor.Account[4:8] != "2100" {
+		t.Errorf("Unexpected transaction %+v", tx)
+	}
+}
+
+func TestCSB19(t *testing.T) {
+	out := anonymize(t, "../input-csb19.txt", 1)
+	doc, err := csb19.NewParser().Parse(bytes.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Presenter.NIF == "B07123456" || doc.OrdererPayments[0].Orderer.NIF != doc.Presenter.NIF || doc.TotalAmount != 1183.95 {
+		t.Errorf("Unexpected document %s", doc)
+	}
+	for _, d := range doc.OrdererPayments[0].Debits {
+		if err := ccc.Validate(d.Account); err != nil {
+			t.Error(err)
+		}
+	}
+	if _, err := ccc.CreditorID(doc.Presenter.NIF, ""); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNIF(t *testing.T) {
+	a := New(1)
+	for _, nif := range []string{"12345678Z", "X1234567L", "B07123456", "P0700000C"} {
+		fake := a.NIF(nif)
+		if len(fake) != 9 || fake[0] == '1' && nif[0] != '1' || fake == nif || a.NIF(nif) != fake {
+			t.Errorf("%s: unexpected fake %s", nif, fake)
+		}
+	}
+	dni := a.NIF("12345678Z")
+	if dni != ccc.DNI(dni[:8]) {
+		t.Errorf("Wrong DNI letter on %s", dni)
+	}
+}
+
+func TestDocument(t *testing.T) {
+	doc := sepadebit.NewDocument()
+	doc.SetInitiatingParty("EMPRESA, S.L.", "ES08000E77846772")
+	p := &sepadebit.Payment{Creditor: &sepadebit.Creditor{Name: "EMPRESA, S.L.", IBAN: "ES9121000418450200051332", ID: "ES08000E77846772"}}
+	p.Transactions = append(p.Transactions, sepadebit.Transaction{
+		ID:             "FACT-1",
+		Debtor:         sepadebit.Debtor{Name: "GARCIA PEREZ, JUAN", IBAN: "DE89370400440532013000"},
+		Mandate:        sepadebit.MandateInfo{ID: "M-12345678Z"},
+		RemittanceInfo: []string{"CUOTA JUAN GARCIA"},
+	})
+	doc.AddPayment(p)
+	New(1).Document(doc)
+	tx := p.Transactions[0]
+	if doc.InitiatingParty.ID != p.Creditor.ID || tx.Debtor.Name != "DEUDOR 0001" || tx.Mandate.ID != "MANDATO000001" || tx.RemittanceInfo[0] != "CONCEPTO 0001" {
+		t.Errorf("Unexpected anonymized document %+v %+v", p.Creditor, tx)
+	}
+	for _, iban := range []string{p.Creditor.IBAN, tx.Debtor.IBAN} {
+		if err := ccc.ValidateIBAN(iban); err != nil {
+			t.Error(err)
+		}
+	}
+	if !strings.HasPrefix(tx.Debtor.IBAN, "DE") || tx.Debtor.IBAN == "DE89370400440532013000" {
+		t.Errorf("Unexpected fake IBAN %s", tx.Debtor.IBAN)
+	}
+}
+
+func TestAddress(t *testing.T) {
+	a := New(1)
+	//the CSB 19 debtor address register: address, town and post code
+	csb := "5686B07123456000" + "000000000001" + fmt.Sprintf("%-40s%-40s%-35s%-5s", "GARCIA PEREZ, JUAN", "C/ MAYOR 1", "PALMA", "07001")
+	out := a.register([]rune(csb), csb19Fields["5686"])
+	addr := &sepadebit.PostalAddress{StreetName: "C/ MAYOR", BuildingNumber: "1", PostCode: "07001", TownName: "PALMA", CountrySubDivision: "ILLES BALEARS", Country: "ES", AddressLines: []string{"C/ MAYOR 1"}}
+	a.address(addr)
+	for _, clear := range []string{"MAYOR", "PALMA", "07001", "BALEARS"} {
+		if strings.Contains(out, clear) || strings.Contains(fmt.Sprintf("%+v", *addr), clear) {
+			t.Errorf("%s left in clear: %s %+v", clear, out, *addr)
+		}
+	}
+	//the same town and post code get the same fakes in both formats
+	if town := strings.TrimSpace(out[108:143]); town != "POBLACION 0001" || addr.TownName != town {
+		t.Errorf("Expected the same fake town, got %q and %q", town, addr.TownName)
+	}
+	if code := out[143:148]; len(addr.PostCode) != 5 || addr.PostCode != code || !isDigits(code) {
+		t.Errorf("Expected the same fake post code, got %q and %q", code, addr.PostCode)
+	}
+	if addr.Country != "ES" || addr.AddressLines[0] != strings.TrimSpace(out[68:108]) {
+		t.Errorf("Unexpected address %+v", *addr)
+	}
+}
@@ -0,0 +1,191 @@
+//Package batch converts many input files concurrently with a bounded pool of
+//workers. A failing file does not stop the others, and every file gets a
+//Result in the consolidated Summary.
+package batch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/apsl/sepakit/convert"
+	"github.com/apsl/sepakit/sepadebit"
+)
+
+//Config configures a batch conversion
+type Config struct {
+	//Jobs is the number of concurrent conversions, the number of CPUs if 0
+	Jobs int
+	//OutDir receives the output files, named as the inputs with the
+	//extension of the output format
+	OutDir string
+	//Converter converts every file, a default Converter if nil
+	Converter *convert.Converter
+	//Audit writes the report of every converted file next to its output,
+	//see convert.AuditPath, sealed with AuditKey
+	Audit    bool
+	AuditKey []byte
+}
+
+//Result is the outcome of converting a file
+type Result struct {
+	Input        string `json:"input"`
+	Output       string `json:"output,omitempty"`
+	Payments     int    `json:"payments"`
+	Transactions int    `json:"transactions"`
+	Amount       string `json:"amount,omitempty"`
+	//Transformations counts the texts altered to fit the character sets or
+	//the lengths
+	Transformations int      `json:"transformations,omitempty"`
+	Warnings        []string `json:"warnings,omitempty"`
+	Error           string   `json:"error,omitempty"`
+}
+
+//Summary consolidates the results of a batch, in input order
+type Summary struct {
+	Files        int       `json:"files"`
+	Converted    int       `json:"converted"`
+	Failed       int       `json:"failed"`
+	Transactions int       `json:"transactions"`
+	Amount       string    `json:"amount"`
+	Results      []*Result `json:"results"`
+}
+
+//Run converts inputs, at most cfg.Jobs at a time. When ctx is done the
+//conversions in progress stop and the pending files fail with the context
+//error
+func Run(ctx context.Context, inputs []string, cfg Config) *Summary {
+	jobs := cfg.Jobs
+	if jobs <= 0 {
+		jobs = runtime.NumCPU()
+	}
+	if cfg.Converter == nil {
+		cfg.Converter, _ = convert.NewConverter()
+	}
+	results := make([]*Result, len(inputs))
+	outputs := make([]string, len(inputs))
+	seen := map[string]string{}
+	for n, input := range inputs {
+		outputs[n] = outputPath(input, cfg)
+		if other, ok := seen[outputs[n]]; ok {
+			results[n] = &Result{Input: input, Error: fmt.Sprintf("Output %s is also the output of %s", outputs[n], other)}
+		}
+		seen[outputs[n]] = input
+	}
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := range next {
+				results[n] = convertFile(ctx, inputs[n], outputs[n], cfg)
+			}
+		}()
+	}
+	for n := range inputs {
+		if results[n] != nil {
+			continue
+		}
+		if ctx.Err() != nil {
+			results[n] = &Result{Input: inputs[n], Error: ctx.Err().Error()}
+			continue
+		}
+		select {
+		case next <- n:
+		case <-ctx.Done():
+			results[n] = &Result{Input: inputs[n], Error: ctx.Err().Error()}
+		}
+	}
+	close(next)
+	wg.Wait()
+	return summarize(results)
+}
+
+//outputPath returns the output file of an input
+func outputPath(input string, cfg Config) string {
+	ext := "." + cfg.Converter.OutputFormat()
+	base := filepath.Base(input)
+	return filepath.Join(cfg.OutDir, strings.TrimSuffix(base, filepath.Ext(base))+ext)
+}
+
+//convertFile converts an input to output
+func convertFile(ctx context.Context, input, output string, cfg Config) (r *Result) {
+	r = &Result{Input: input}
+	f, err := os.Open(input)
+	if err != nil {
+		r.Error = err.Error()
+		return
+	}
+	defer f.Close()
+	//written before the conversion is recorded in the ledger
+	out, err := convert.CreateFile(output)
+	if err != nil {
+		r.Error = err.Error()
+		return
+	}
+	defer out.Close()
+	report, err := cfg.Converter.Convert(ctx, f, out)
+	r.Warnings = report.Warnings
+	r.Transformations = len(report.Transformations)
+	if err != nil {
+		r.Error = err.Error()
+		return
+	}
+	r.Payments = report.Payments
+	r.Transactions = report.Transactions
+	r.Amount = report.CtrlSum
+	r.Output = output
+	//the file is converted and recorded in the ledger by now, so a missing
+	//audit report must not count it as failed, or it would be converted again
+	if cfg.Audit {
+		if err := convert.WriteAudit(convert.AuditPath(output), report, cfg.AuditKey); err != nil {
+			r.Warnings = append(r.Warnings, fmt.Sprintf("Audit report not written: %s", err))
+		}
+	}
+	return
+}
+
+func summarize(results []*Result) *Summary {
+	s := &Summary{Files: len(results), Results: results}
+	var cents int64
+	for _, r := range results {
+		if r.Error != "" {
+			s.Failed++
+			continue
+		}
+		s.Converted++
+		s.Transactions += r.Transactions
+		if r.Amount == "" {
+			continue
+		}
+		amount, err := sepadebit.ParseAmount(r.Amount)
+		if err != nil {
+			r.Warnings = append(r.Warnings, fmt.Sprintf("Invalid control sum %s", r.Amount))
+		}
+		cents += amount
+	}
+	s.Amount = sepadebit.FormatAmount(cents)
+	return s
+}
+
+//WriteTable writes the summary as a text table, a line per file and a total
+func (s *Summary) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "FILE\tPAYMENTS\tDEBITS\tAMOUNT\tWARNINGS\tSTATUS")
+	for _, r := range s.Results {
+		status := "ok"
+		if r.Error != "" {
+			status = r.Error
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%d\t%s\n", r.Input, r.Payments, r.Transactions, r.Amount, len(r.Warnings)+r.Transformations, status)
+	}
+	fmt.Fprintf(tw, "%d files, %d failed\t\t%d\t%s\n", s.Files, s.Failed, s.Transactions, s.Amount)
+	return tw.Flush()
+}
@@ -0,0 +1,84 @@
+package batch
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	broken := filepath.Join(dir, "broken.txt")
+	if err := ioutil.WriteFile(broken, []byte("0180000000000000000000\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "out")
+	if err := os.Mkdir(out, 0755); err != nil {
+		t.Fatal(err)
+	}
+	inputs := []string{"../input-aeb1914.txt", broken, "../input-csb19.txt", filepath.Join(dir, "missing.txt")}
+	s := Run(context.Background(), inputs, Config{Jobs: 2, OutDir: out})
+	if s.Files != 4 || s.Converted != 2 || s.Failed != 2 || s.Transactions != 4 {
+		t.Fatalf("Unexpected summary %+v", s)
+	}
+	if s.Results[0].Input != inputs[0] || s.Results[0].Output != filepath.Join(out, "input-aeb1914.xml") {
+		t.Errorf("Unexpected result %+v", s.Results[0])
+	}
+	if s.Results[1].Error == "" || s.Results[3].Error == "" {
+		t.Errorf("Expected errors for the broken and missing files, got %+v %+v", s.Results[1], s.Results[3])
+	}
+	if _, err := os.Stat(filepath.Join(out, "input-csb19.xml")); err != nil {
+		t.Error(err)
+	}
+
+	var buf bytes.Buffer
+	if err := s.WriteTable(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "4 files, 2 failed") || !strings.Contains(buf.String(), s.Amount) {
+		t.Errorf("Unexpected table:\n%s", buf.String())
+	}
+}
+
+func TestRunCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s := Run(ctx, []string{"../input-aeb1914.txt", "../input-csb19.txt"}, Config{OutDir: t.TempDir()})
+	if s.Failed != 2 {
+		t.Fatalf("Expected canceled conversions, got %+v", s)
+	}
+	for _, r := range s.Results {
+		if r.Error != context.Canceled.Error() {
+			t.Errorf("Unexpected result %+v", r)
+		}
+	}
+}
+
+func TestRunDuplicateOutputs(t *testing.T) {
+	dir := t.TempDir()
+	other := filepath.Join(dir, "input-aeb1914.json")
+	if err := ioutil.WriteFile(other, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s := Run(context.Background(), []string{"../input-aeb1914.txt", other}, Config{OutDir: dir})
+	if s.Results[1].Error == "" || !strings.Contains(s.Results[1].Error, "also the output") {
+		t.Errorf("Expected a duplicate output error, got %+v", s.Results[1])
+	}
+}
+
+func TestRunAuditFailure(t *testing.T) {
+	dir := t.TempDir()
+	//a folder in the way of the audit report
+	if err := os.Mkdir(filepath.Join(dir, "input-aeb1914.audit.json"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	s := Run(context.Background(), []string{"../input-aeb1914.txt"}, Config{OutDir: dir, Audit: true})
+	r := s.Results[0]
+	if s.Converted != 1 || r.Error != "" || len(r.Warnings) != 1 || !strings.HasPrefix(r.Warnings[0], "Audit report not written") {
+		t.Errorf("Expected a converted file with an audit warning, got %+v", r)
+	}
+}
@@ -0,0 +1,244 @@
+//Package ccc converts Spanish CCC (Código Cuenta Cliente) bank accounts to
+//IBAN, derives their BIC from the entity code and builds SEPA creditor
+//identifiers from Spanish NIFs
+package ccc
+
+import (
+	"bufio"
+	_ "embed" // entity list
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/apsl/sepakit/redact"
+)
+
+//Length is the length of a CCC: entity (4), office (4), check digits (2)
+//and account number (10)
+const Length = 20
+
+//go:embed entities.txt
+var entitiesList string
+
+//Entities are the known BICs by entity code, from entities.txt. See
+//LoadEntities to update them
+var Entities = mustParseEntities(entitiesList)
+
+var (
+	entityCode = regexp.MustCompile(`^[0-9]{4}$`)
+	bicCode    = regexp.MustCompile(`^[A-Z]{6}[A-Z0-9]{2}([A-Z0-9]{3})?$`)
+)
+
+//parseEntities reads "code BIC" lines, ignoring # comments and blank lines
+func parseEntities(r io.Reader) (map[string]string, error) {
+	entities := map[string]string{}
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 || !entityCode.MatchString(fields[0]) || !bicCode.MatchString(fields[1]) {
+			return nil, fmt.Errorf("Line %d: expected an entity code and its BIC, got %q", n, line)
+		}
+		entities[fields[0]] = fields[1]
+	}
+	return entities, scanner.Err()
+}
+
+func mustParseEntities(list string) map[string]string {
+	entities, err := parseEntities(strings.NewReader(list))
+	if err != nil {
+		panic(err)
+	}
+	return entities
+}
+
+//LoadEntities reads an entity list in the format of entities.txt, "code BIC"
+//lines with # comments, and adds its entities to Entities, replacing the
+//BICs of the known ones. It must be called before the conversions, as
+//Entities is not locked
+func LoadEntities(r io.Reader) error {
+	entities, err := parseEntities(r)
+	if err != nil {
+		return err
+	}
+	if len(entities) == 0 {
+		return fmt.Errorf("No entities found")
+	}
+	for code, bic := range entities {
+		Entities[code] = bic
+	}
+	return nil
+}
+
+//normalize removes spaces and dashes
+func normalize(s string) string {
+	return strings.NewReplacer(" ", "", "-", "").Replace(s)
+}
+
+//Validate checks the length and check digits of a CCC. Errors show the CCC
+//masked
+func Validate(ccc string) error {
+	ccc = normalize(ccc)
+	if len(ccc) != Length {
+		return fmt.Errorf("CCC %s must have %d digits", redact.IBAN(ccc), Length)
+	}
+	for _, r := range ccc {
+		if r < '0' || r > '9' {
+			return fmt.Errorf("CCC %s must have only digits", redact.IBAN(ccc))
+		}
+	}
+	if dc := checkDigit("00"+ccc[:8]) + checkDigit(ccc[10:]); dc != ccc[8:10] {
+		return fmt.Errorf("Wrong check digits on CCC %s", redact.IBAN(ccc))
+	}
+	return nil
+}
+
+//checkDigit returns the CCC check digit of 10 digits
+func checkDigit(digits string) string {
+	weights := []int{1, 2, 4, 8, 5, 10, 9, 7, 3, 6}
+	sum := 0
+	for i, r := range digits {
+		sum += int(r-'0') * weights[i]
+	}
+	dc := 11 - sum%11
+	switch dc {
+	case 11:
+		dc = 0
+	case 10:
+		dc = 1
+	}
+	return fmt.Sprint(dc)
+}
+
+//Build returns the CCC of an entity, office and account number, computing
+//its check digits
+func Build(entity, office, number string) string {
+	return entity + office + checkDigit("00"+entity+office) + checkDigit(number) + number
+}
+
+//IBAN returns the Spanish IBAN of a valid CCC
+func IBAN(ccc string) (string, error) {
+	ccc = normalize(ccc)
+	if err := Validate(ccc); err != nil {
+		return "", err
+	}
+	return NewIBAN("ES", ccc), nil
+}
+
+//NewIBAN returns the IBAN of a national account number (BBAN) of a country,
+//computing its check digits
+func NewIBAN(country, bban string) string {
+	return fmt.Sprintf("%s%02d%s", country, 98-mod97(bban+country+"00"), bban)
+}
+
+//ValidateIBAN checks the format and check digits of an IBAN of any country
+func ValidateIBAN(iban string) error {
+	iban = strings.ToUpper(normalize(iban))
+	if len(iban) < 15 || len(iban) > 34 {
+		return fmt.Errorf("IBAN %s must have 15 to 34 characters", redact.IBAN(iban))
+	}
+	for i, r := range iban {
+		letter := r >= 'A' && r <= 'Z'
+		digit := r >= '0' && r <= '9'
+		if i < 2 && !letter || i >= 2 && i < 4 && !digit || !letter && !digit {
+			return fmt.Errorf("Invalid IBAN %s", redact.IBAN(iban))
+		}
+	}
+	if mod97(iban[4:]+iban[:4]) != 1 {
+		return fmt.Errorf("Wrong check digits on IBAN %s", redact.IBAN(iban))
+	}
+	return nil
+}
+
+//BIC returns the BIC of the entity of a CCC or Spanish IBAN
+func BIC(account string) (string, error) {
+	account = normalize(account)
+	if strings.HasPrefix(account, "ES") && len(account) == Length+4 {
+		account = account[4:]
+	}
+	if len(account) < 4 {
+		return "", fmt.Errorf("Account %s too short", redact.IBAN(account))
+	}
+	bic, ok := Entities[account[:4]]
+	if !ok {
+		return "", fmt.Errorf("Unknown BIC for entity %s", account[:4])
+	}
+	return bic, nil
+}
+
+//CreditorID returns the SEPA creditor identifier (AT-02) of a Spanish NIF:
+//ES, check digits, the creditor business code (the AEB suffix, 000 if empty)
+//and the NIF
+func CreditorID(nif, suffix string) (string, error) {
+	nif = strings.ToUpper(normalize(nif))
+	if nif == "" || len(nif) > 28 {
+		return "", fmt.Errorf("Invalid NIF %s", redact.ID(nif))
+	}
+	for _, r := range nif {
+		if !(r >= '0' && r <= '9' || r >= 'A' && r <= 'Z') {
+			return "", fmt.Errorf("Invalid NIF %s", redact.ID(nif))
+		}
+	}
+	if suffix = strings.TrimSpace(suffix); suffix == "" {
+		suffix = "000"
+	}
+	return fmt.Sprintf("ES%02d%s%s", 98-mod97(nif+"ES00"), suffix, nif), nil
+}
+
+const dniLetters = "TRWAGMYFPDXBNJZSQVHLCKE"
+
+//DNI returns the NIF of a person with the 8 digits of its DNI number, or of
+//a foreigner with the X, Y or Z prefix and 7 digits of its NIE, adding its
+//control letter
+func DNI(number string) string {
+	n := 0
+	for i, r := range number {
+		switch {
+		case i == 0 && r >= 'X' && r <= 'Z':
+			n = int(r - 'X')
+		case r >= '0' && r <= '9':
+			n = n*10 + int(r-'0')
+		}
+	}
+	return number + string(dniLetters[n%23])
+}
+
+//CIF returns the NIF of an organisation with its letter and 7 digits,
+//adding its control digit, or letter for the letters K, N, P, Q, R, S and W
+func CIF(letter byte, digits string) string {
+	sum := 0
+	for i, r := range digits {
+		d := int(r - '0')
+		if i%2 == 0 {
+			d *= 2
+			d = d/10 + d%10
+		}
+		sum += d
+	}
+	control := (10 - sum%10) % 10
+	if strings.IndexByte("KNPQRSW", letter) >= 0 {
+		return string(letter) + digits + string("JABCDEFGHI"[control])
+	}
+	return string(letter) + digits + string(rune('0'+control))
+}
+
+//mod97 converts letters to numbers (A=10 ... Z=35) and returns the number
+//mod 97, computed digit by digit
+func mod97(s string) int {
+	n := 0
+	for _, r := range s {
+		if r >= 'A' && r <= 'Z' {
+			n = (n*100 + int(r-'A'+10)) % 97
+		} else {
+			n = (n*10 + int(r-'0')) % 97
+		}
+	}
+	return n
+}
@@ -0,0 +1,91 @@
+package ccc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIBAN(t *testing.T) {
+	iban, err := IBAN("2100 0418 45 0200051332")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if iban != "ES9121000418450200051332" {
+		t.Errorf("Expected ES9121000418450200051332, got %s", iban)
+	}
+	if _, err := IBAN("21000418460200051332"); err == nil {
+		t.Error("Expected wrong check digits error")
+	}
+	if ccc := Build("2100", "0418", "0200051332"); ccc != "21000418450200051332" {
+		t.Errorf("Expected 21000418450200051332, got %s", ccc)
+	}
+	if iban := NewIBAN("DE", "370400440532013000"); iban != "DE89370400440532013000" {
+		t.Errorf("Expected DE89370400440532013000, got %s", iban)
+	}
+}
+
+func TestLoadEntities(t *testing.T) {
+	defer func(bic string) { Entities["2100"] = bic }(Entities["2100"])
+	if err := LoadEntities(strings.NewReader("# updated\n9999 TESTESMMXXX # test\n\n2100 CAIXESBB\n")); err != nil {
+		t.Fatal(err)
+	}
+	defer delete(Entities, "9999")
+	if Entities["9999"] != "TESTESMMXXX" || Entities["2100"] != "CAIXESBB" || Entities["0049"] != "BSCHESMMXXX" {
+		t.Errorf("Unexpected entities %v", Entities)
+	}
+	for _, list := range []string{"", "# none\n", "0049\n", "49 BSCHESMMXXX\n", "0049 BSCH\n"} {
+		if err := LoadEntities(strings.NewReader(list)); err == nil {
+			t.Errorf("Expected error for %q", list)
+		}
+	}
+}
+
+func TestBIC(t *testing.T) {
+	for _, account := range []string{"21000418450200051332", "ES9121000418450200051332"} {
+		if bic, err := BIC(account); err != nil || bic != "CAIXESBBXXX" {
+			t.Errorf("%s: expected CAIXESBBXXX, got %s %v", account, bic, err)
+		}
+	}
+	if _, err := BIC("99990418450200051332"); err == nil {
+		t.Error("Expected unknown entity error")
+	}
+}
+
+func TestNIF(t *testing.T) {
+	for _, test := range [][2]string{
+		{DNI("12345678"), "12345678Z"},
+		{DNI("X1234567"), "X1234567L"},
+		{CIF('A', "2801586"), "A28015865"},
+		{CIF('Q', "2826000"), "Q2826000H"},
+	} {
+		if test[0] != test[1] {
+			t.Errorf("Expected %s, got %s", test[1], test[0])
+		}
+	}
+}
+
+func TestCreditorID(t *testing.T) {
+	id, err := CreditorID("B07891234", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(id) != 16 || id[:2] != "ES" || id[4:7] != "000" || id[7:] != "B07891234" {
+		t.Errorf("Unexpected creditor identifier %s", id)
+	}
+	if mod97(id[7:]+id[:4]) != 1 {
+		t.Errorf("Wrong check digits on %s", id)
+	}
+}
+
+func TestValidateIBAN(t *testing.T) {
+	for _, iban := range []string{"ES91 2100 0418 4502 0005 1332", "DE89370400440532013000", "GB29NWBK60161331926819"} {
+		if err := ValidateIBAN(iban); err != nil {
+			t.Errorf("%s: %s", iban, err)
+		}
+	}
+	for _, iban := range []string{"ES9121000418450200051333", "ES91", "9121000418450200051332ES", "ES91-2100-0418-4502-0005-133!"} {
+		if err := ValidateIBAN(iban); err == nil {
+			t.Errorf("%s: expected an error", iban)
+		}
+	}
+}
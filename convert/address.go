@@ -0,0 +1,78 @@
+package convert
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/apsl/sepakit/sepadebit"
+)
+
+//AddressMode selects how the AEB address lines are written
+type AddressMode int
+
+const (
+	AddressUnstructured AddressMode = iota // an AdrLine for every non empty line
+	AddressStructured                      // StrtNm, PstCd, TwnNm... using SpanishAddress
+)
+
+//ParseAddressMode returns the AddressMode for "lines" or "structured"
+func ParseAddressMode(name string) (AddressMode, error) {
+	switch name {
+	case "", "lines":
+		return AddressUnstructured, nil
+	case "structured":
+		return AddressStructured, nil
+	}
+	return AddressUnstructured, fmt.Errorf("Unknown address mode %s", name)
+}
+
+var (
+	postCodeLine = regexp.MustCompile(`^(\d{5})\s+(.+)$`)
+	streetNumber = regexp.MustCompile(`^(.+?),\s*(\d+[A-Z]?)$`)
+)
+
+//SpanishAddress builds a structured address from AEB address lines, as
+//usually written in Spain: "CALLE MAYOR, 12" / "12345 CIUDAD" / "PROVINCIA".
+//The line starting with a 5 digit post code gives PstCd and TwnNm, the first
+//line before it the street and building number and the line after it the
+//province. Lines that do not follow this layout are returned unstructured
+func SpanishAddress(country string, lines ...string) *sepadebit.PostalAddress {
+	var nonEmpty []string
+	for _, line := range lines {
+		if line != "" {
+			nonEmpty = append(nonEmpty, line)
+		}
+	}
+	for i, line := range nonEmpty {
+		m := postCodeLine.FindStringSubmatch(line)
+		if m == nil || i > 1 || len(nonEmpty)-i > 2 {
+			continue
+		}
+		a := &sepadebit.PostalAddress{Country: country, PostCode: m[1], TownName: m[2]}
+		if i == 1 {
+			a.StreetName = nonEmpty[0]
+			if m := streetNumber.FindStringSubmatch(nonEmpty[0]); m != nil {
+				a.StreetName, a.BuildingNumber = m[1], m[2]
+			}
+		}
+		if i+1 < len(nonEmpty) {
+			a.CountrySubDivision = nonEmpty[i+1]
+		}
+		return a
+	}
+	return sepadebit.NewUnstructuredAddress(country, nonEmpty...)
+}
+
+//creditorAddress returns the creditor address in the given mode, or nil when empty
+func creditorAddress(mode AddressMode, country string, lines ...string) *sepadebit.PostalAddress {
+	var a *sepadebit.PostalAddress
+	if mode == AddressStructured {
+		a = SpanishAddress(country, lines...)
+	} else {
+		a = sepadebit.NewUnstructuredAddress(country, lines...)
+	}
+	if a.IsEmpty() {
+		return nil
+	}
+	return a
+}
@@ -0,0 +1,159 @@
+package convert
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+
+	"github.com/apsl/sepakit/ccc"
+	"github.com/apsl/sepakit/sepadebit"
+)
+
+//Version is the sepakit version written in the reports. Release builds set
+//it with -ldflags "-X github.com/apsl/sepakit/convert.Version=v1.2.0",
+//otherwise the module version is used
+var Version = ""
+
+//toolVersion returns Version, the module version or "devel"
+func toolVersion() string {
+	if Version != "" {
+		return Version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return "devel"
+}
+
+//Validation is the result of a check of the converted document
+type Validation struct {
+	Check  string `json:"check"`
+	Passed bool   `json:"passed"`
+	Error  string `json:"error,omitempty"`
+}
+
+//validate adds the result of a check to the report and returns err
+func (r *Report) validate(check string, err error) error {
+	v := Validation{Check: check, Passed: err == nil}
+	if err != nil {
+		v.Error = err.Error()
+	}
+	r.Validations = append(r.Validations, v)
+	return err
+}
+
+//checkIBANs checks the check digits of every IBAN of doc
+func checkIBANs(doc *sepadebit.Document) error {
+	for _, p := range doc.Payments {
+		if p.Creditor != nil {
+			if err := ccc.ValidateIBAN(p.Creditor.IBAN); err != nil {
+				return fmt.Errorf("Payment %s: %s", p.ID, err)
+			}
+		}
+		for _, t := range p.Transactions {
+			if err := ccc.ValidateIBAN(t.Debtor.IBAN); err != nil {
+				return fmt.Errorf("Transaction %s: %s", t.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+//checkTotals checks the number of transactions and control sums of doc and
+//its payments
+func checkTotals(doc *sepadebit.Document) error {
+	var n int
+	var sum int64
+	for _, p := range doc.Payments {
+		var psum int64
+		for _, t := range p.Transactions {
+			cents, err := sepadebit.ParseAmount(t.Amount.Amount)
+			if err != nil {
+				return fmt.Errorf("Transaction %s: %s", t.ID, err)
+			}
+			psum += cents
+		}
+		if p.TransacNb != len(p.Transactions) || p.CtrlSum != sepadebit.FormatAmount(psum) {
+			return fmt.Errorf("Payment %s totals %d %s, expected %d %s", p.ID, p.TransacNb, p.CtrlSum, len(p.Transactions), sepadebit.FormatAmount(psum))
+		}
+		n += len(p.Transactions)
+		sum += psum
+	}
+	if doc.TransacNb != n || doc.CtrlSum != sepadebit.FormatAmount(sum) {
+		return fmt.Errorf("Document totals %d %s, expected %d %s", doc.TransacNb, doc.CtrlSum, n, sepadebit.FormatAmount(sum))
+	}
+	return nil
+}
+
+//Seal sets the Hash of the report, the hex SHA-256 of its JSON without Hash
+//and Signature, and its Signature, the HMAC-SHA256 of the same JSON with
+//key, when key is not empty. The JSON of a report is the same for the same
+//input, settings and clock, so a sealed report can be reproduced
+func (r *Report) Seal(key []byte) error {
+	data, err := r.content()
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+	r.Hash = hex.EncodeToString(sum[:])
+	r.Signature = ""
+	if len(key) > 0 {
+		r.Signature = sign(data, key)
+	}
+	return nil
+}
+
+//Verify checks the Hash of a sealed report, and its Signature when key is
+//not empty
+func (r *Report) Verify(key []byte) error {
+	data, err := r.content()
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+	if r.Hash != hex.EncodeToString(sum[:]) {
+		return fmt.Errorf("Report hash does not match its content")
+	}
+	if len(key) > 0 && !hmac.Equal([]byte(r.Signature), []byte(sign(data, key))) {
+		return fmt.Errorf("Report signature does not match its content")
+	}
+	return nil
+}
+
+//content returns the JSON of the report without its seal
+func (r *Report) content() ([]byte, error) {
+	unsealed := *r
+	unsealed.Hash = ""
+	unsealed.Signature = ""
+	return json.Marshal(unsealed)
+}
+
+func sign(data, key []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+//AuditPath returns the report file of an output file: its name with the
+//.audit.json extension
+func AuditPath(output string) string {
+	return strings.TrimSuffix(output, filepath.Ext(output)) + ".audit.json"
+}
+
+//WriteAudit seals the report with key and writes it as indented JSON to path
+func WriteAudit(path string, r Report, key []byte) error {
+	if err := r.Seal(key); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
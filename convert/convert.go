@@ -3,27 +3,28 @@ package convert
 import (
 	"fmt"
 	"io"
-	"unicode"
 
-	"github.com/apsl/sepakit/aeb1914"
+	"github.com/apsl/sepakit/aeb19"
 	"github.com/apsl/sepakit/sepadebit"
 	"golang.org/x/text/encoding/charmap"
-	"golang.org/x/text/transform"
-	"golang.org/x/text/unicode/norm"
 )
 
 //Latin1DebitTxtToXML creates XML SEPA Document from TXT Document
-//Transforms input and output to ISO-8859-1
+//Transforms input and output to ISO-8859-1. See Converter for other
+//encodings and settings
 func Latin1DebitTxtToXML(in io.Reader, out io.Writer) error {
 	r := charmap.ISO8859_1.NewDecoder().Reader(in)
-	parser := aeb1914.NewParser()
+	parser := aeb19.NewParser()
 
 	doctxt, err := parser.Parse(r)
 	if err != nil {
 		return err
 	}
 
-	docxml := DebitTxtToXML(doctxt)
+	docxml, err := DebitTxtToXMLWithOptions(doctxt, Options{})
+	if err != nil {
+		return err
+	}
 
 	err = docxml.WriteLatin1(out)
 	if err != nil {
@@ -35,86 +36,144 @@ func Latin1DebitTxtToXML(in io.Reader, out io.Writer) error {
 //Latin1DebitTxtToXMLDoc creates XML SEPA Document from TXT Document
 //Transforms input to ISO-8859-1
 func Latin1DebitTxtToXMLDoc(in io.Reader) (*sepadebit.Document, error) {
-	r := charmap.ISO8859_1.NewDecoder().Reader(in)
-	parser := aeb1914.NewParser()
+	return Latin1DebitTxtToXMLDocWithOptions(in, Options{})
+}
+
+//Latin1DebitTxtToXMLDocWithOptions creates XML SEPA Document from TXT Document
+//using opts. Transforms input to ISO-8859-1
+func Latin1DebitTxtToXMLDocWithOptions(in io.Reader, opts Options) (*sepadebit.Document, error) {
+	return debitTxtToXMLDoc(charmap.ISO8859_1.NewDecoder().Reader(in), opts)
+}
+
+//debitTxtToXMLDoc creates XML SEPA Document from decoded TXT Document
+func debitTxtToXMLDoc(r io.Reader, opts Options) (*sepadebit.Document, error) {
+	parser := aeb19.NewParser()
+	parser.Warn = opts.Warn
 
 	doctxt, err := parser.Parse(r)
 	if err != nil {
 		return nil, err
 	}
 
-	docxml := DebitTxtToXML(doctxt)
-	return docxml, nil
+	return DebitTxtToXMLWithOptions(doctxt, opts)
+}
+
+//DebitTxtToXML creates XML SEPA Document from TXT Document. It returns nil
+//when a debit has an invalid sequence type, see DebitTxtToXMLWithOptions
+func DebitTxtToXML(doctxt *aeb19.Document) *sepadebit.Document {
+	docxml, err := DebitTxtToXMLWithOptions(doctxt, Options{})
+	if err != nil {
+		return nil
+	}
+	return docxml
 }
 
-//DebitTxtToXML creates XML SEPA Document from TXT Document
-func DebitTxtToXML(doctxt *aeb1914.Document) *sepadebit.Document {
+//DebitTxtToXMLWithOptions creates XML SEPA Document from TXT Document, taking
+//the creation time, MsgId/PmtInfId identifiers and transliteration from opts.
+//AEB 19.44 files produce B2B payments. Debits are grouped in payments by
+//sequence type and category purpose. It fails on an invalid sequence type and
+//when an RF reference cannot be derived
+func DebitTxtToXMLWithOptions(doctxt *aeb19.Document, opts Options) (*sepadebit.Document, error) {
 
-	docxml := sepadebit.NewDocument()
+	profile := opts.Profile
+	if profile == nil {
+		profile = DefaultProfile
+	}
+	docxml := sepadebit.NewDocumentWithOptions(opts.Options)
 	docxml.SetInitiatingParty(doctxt.InitiatingParty.Name, doctxt.InitiatingParty.ID)
 
 	// conversion fist version. Should be moved to sepadebit package
 	for _, cp := range doctxt.CreditorPayments {
+		opts.derived("Creditor "+cp.Creditor.ID, cp.Creditor.Account, profile.BIC, "profile")
 		for _, dp := range cp.DatePayments {
 			c := sepadebit.Creditor{
 				ID:   cp.Creditor.ID,
 				Name: cp.Creditor.Name,
 				IBAN: cp.Creditor.Account,
-				PostalAddress: sepadebit.PostalAddress{
-					Country: cp.Creditor.Country,
-					Address: [2]string{cp.Creditor.AddressD1, cp.Creditor.AddressD2},
-				},
-				SchemeName:   "SEPA",
-				BIC:          "CAIXESBBXXX", // hardcoded, we need a BIC library
-				ChargeBearer: "SLEV",
-			}
-			p := sepadebit.Payment{
-				Creditor:                &c,
-				CtrlSum:                 fmt.Sprintf("%.2f", cp.TotalAmount),
-				TransacNb:               cp.DebitRegisterCount,
-				RequestedCollectionDate: dp.Date.Format("2006-01-02"),
-				ID:                      fmt.Sprintf("rem%s1", dp.Date.Format("20060102")),
-				Method:                  "DD",
-				ServiceLevel:            "SEPA",
-				LocalInstrument:         "CORE",
-				SequenceType:            "RCUR",
+				PostalAddress: creditorAddress(opts.Address, cp.Creditor.Country,
+					cp.Creditor.AddressD1, cp.Creditor.AddressD2, cp.Creditor.AddressD3),
+				SchemeName:       "SEPA",
+				BIC:              profile.BIC,
+				ChargeBearer:     profile.ChargeBearer,
+				UltimateCreditor: opts.Parties.ultimateCreditor(cp.Creditor.ID),
 			}
+			// transactions with different sequence types or category purposes go
+			// to separate payments
+			type key struct{ sequence, category string }
+			payments := map[key]*sepadebit.Payment{}
 			for _, dt := range dp.DebitTransactions {
+				if !isSequenceType(dt.Sequence) {
+					return nil, fmt.Errorf("Transaction %s: invalid sequence type %q", dt.ID, dt.Sequence)
+				}
+				category := dt.CategoryCode
+				if category != "" && !sepadebit.IsCategoryPurposeCode(category) {
+					opts.warnf("Transaction %s: unknown category purpose code %s", dt.ID, category)
+					category = ""
+				}
+				k := key{dt.Sequence, category}
+				p, ok := payments[k]
+				if !ok {
+					creditor := c
+					p = &sepadebit.Payment{
+						Creditor:                &creditor,
+						RequestedCollectionDate: dp.Date.Format("2006-01-02"),
+						ID:                      docxml.NewID("rem" + dp.Date.Format("20060102")),
+						Method:                  "DD",
+						ServiceLevel:            "SEPA",
+						LocalInstrument:         localInstrument(doctxt.Variant),
+						SequenceType:            dt.Sequence,
+					}
+					p.SetCategoryPurpose(category)
+					payments[k] = p
+					docxml.AddPayment(p)
+				}
 				t := sepadebit.Transaction{
-					ID:        dt.ID,
-					MandateID: dt.MandateID,
-					Date:      sepadebit.Date(dt.Date),
+					ID: dt.ID,
+					Mandate: sepadebit.MandateInfo{
+						ID:            dt.MandateID,
+						SignatureDate: sepadebit.Date(dt.MandateSignatureDate),
+					},
 					Debtor: sepadebit.Debtor{
 						IBAN: dt.Debtor.Account,
 						BIC:  dt.Debtor.Entity,
-						Name: stripSepa(dt.Debtor.Name),
+						Name: dt.Debtor.Name,
 					},
 					Amount: sepadebit.TAmount{
-						Amount:   fmt.Sprintf("%.2f", dt.Amount),
+						Amount:   sepadebit.FormatAmount(dt.Amount),
 						Currency: "EUR",
 					},
-					RemittanceInfo: stripSepa(dt.Concept),
+				}
+				t.UltimateDebtor = opts.Parties.ultimateDebtor(cp.Creditor.ID, dt.MandateID, dt.Debtor.ID)
+				if dt.Concept != "" {
+					t.RemittanceInfo = []string{dt.Concept}
+				}
+				if err := t.SetPurpose(dt.Purpose); err != nil {
+					opts.warnf("Transaction %s: %s", dt.ID, err)
+				}
+				rf, err := rfReference(dt.ID, dt.Concept, opts.RFReference)
+				if err == nil && rf != "" {
+					err = t.SetCreditorReference(rf)
+				}
+				if err != nil {
+					return nil, fmt.Errorf("Transaction %s: %s", dt.ID, err)
 				}
 				p.Transactions = append(p.Transactions, t)
 			}
-			docxml.Payments = append(docxml.Payments, &p)
-			docxml.CtrlSum = fmt.Sprintf("%.2f", doctxt.TotalAmount)
-			docxml.TransacNb = doctxt.DebitRegisterCount
 		}
 
 	}
-	return docxml
+	if err := docxml.UpdateTotals(); err != nil {
+		opts.warnf("%s", err)
+	}
+	docxml.Transliterate(docxml.Options().Transliterator)
+	return docxml, nil
 }
 
-//stripSepa implements a filter to remove not alowed chars
-func stripSepa(str string) string {
-	isMn := func(r rune) bool {
-		return unicode.Is(unicode.Mn, r) // Mn: nonspacing marks
-	}
-	isNotSepa := func(r rune) bool {
-		return r > 126
+//isSequenceType reports whether s is a SEPA sequence type
+func isSequenceType(s string) bool {
+	switch s {
+	case "FRST", "RCUR", "FNAL", "OOFF":
+		return true
 	}
-	t := transform.Chain(norm.NFD, transform.RemoveFunc(isMn), transform.RemoveFunc(isNotSepa), norm.NFC)
-	str, _, _ = transform.String(t, str)
-	return str
+	return false
 }
@@ -0,0 +1,295 @@
+package convert
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apsl/sepakit/aeb19"
+	"github.com/apsl/sepakit/csb19"
+	"github.com/apsl/sepakit/sepadebit"
+	"golang.org/x/text/encoding/charmap"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestGolden(t *testing.T) {
+	f, err := os.Open("../input-aeb1914.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	doctxt, err := aeb19.NewParser().Parse(charmap.ISO8859_1.NewDecoder().Reader(f))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := Options{Options: sepadebit.Options{
+		Clock:       sepadebit.FixedClock(time.Date(2013, 12, 17, 17, 29, 52, 0, time.UTC)),
+		IDGenerator: sepadebit.NewSeededIDGenerator(42),
+	}}
+	docxml, err := DebitTxtToXMLWithOptions(doctxt, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := docxml.WriteLatin1(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	golden := "testdata/input-aeb1914.golden.xml"
+	if *update {
+		if err := ioutil.WriteFile(golden, out.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Bytes(), expected) {
+		t.Errorf("Output differs from %s:\n%s", golden, out.String())
+	}
+}
+
+func TestCategoryPurposeGrouping(t *testing.T) {
+	f, err := os.Open("testdata/category-purpose.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	doc, err := Latin1DebitTxtToXMLDoc(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Payments) != 2 {
+		t.Fatalf("Expected 2 payments, one per category purpose, got %d", len(doc.Payments))
+	}
+	trad, supp := doc.Payments[0], doc.Payments[1]
+	if trad.CategoryPurpose == nil || trad.CategoryPurpose.Code != "TRAD" || trad.TransacNb != 2 || trad.CtrlSum != "246.90" {
+		t.Errorf("Unexpected TRAD payment %+v", trad)
+	}
+	if supp.CategoryPurpose == nil || supp.CategoryPurpose.Code != "SUPP" || supp.TransacNb != 1 {
+		t.Errorf("Unexpected SUPP payment %+v", supp)
+	}
+	if p := trad.Transactions[0].Purpose; p == nil || p.Code != "GDDS" {
+		t.Errorf("Expected GDDS purpose, got %v", p)
+	}
+	if p := trad.Transactions[1].Purpose; p != nil {
+		t.Errorf("Expected unknown purpose XXXX to be dropped, got %v", p)
+	}
+}
+
+func TestSequenceTypeGrouping(t *testing.T) {
+	b, err := ioutil.ReadFile("testdata/category-purpose.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	withSequence := func(sequence string) string {
+		lines := strings.Split(string(b), "\n")
+		for i, line := range lines {
+			if strings.HasPrefix(line, "03") {
+				lines[i] = line[:80] + sequence + line[84:]
+				break
+			}
+		}
+		return strings.Join(lines, "\n")
+	}
+	doc, err := Latin1DebitTxtToXMLDoc(strings.NewReader(withSequence("FRST")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Payments) != 3 {
+		t.Fatalf("Expected 3 payments, by sequence type and category purpose, got %d", len(doc.Payments))
+	}
+	for i, expected := range []struct{ sequence, category string }{{"FRST", "TRAD"}, {"RCUR", "SUPP"}, {"RCUR", "TRAD"}} {
+		p := doc.Payments[i]
+		if p.SequenceType != expected.sequence || p.CategoryPurpose == nil || p.CategoryPurpose.Code != expected.category {
+			t.Errorf("Payment %d: expected %s %s, got %s %v", i, expected.sequence, expected.category, p.SequenceType, p.CategoryPurpose)
+		}
+	}
+	if _, err := Latin1DebitTxtToXMLDoc(strings.NewReader(withSequence("XXXX"))); err == nil {
+		t.Error("Expected error for an invalid sequence type")
+	}
+}
+
+func TestSpanishAddress(t *testing.T) {
+	a := SpanishAddress("ES", "CALLE MAYOR, 12", "07001 PALMA", "ILLES BALEARS")
+	if a.StreetName != "CALLE MAYOR" || a.BuildingNumber != "12" || a.PostCode != "07001" || a.TownName != "PALMA" || a.CountrySubDivision != "ILLES BALEARS" || len(a.AddressLines) != 0 {
+		t.Errorf("Unexpected structured address %+v", a)
+	}
+	a = SpanishAddress("ES", "07001 PALMA", "", "")
+	if a.PostCode != "07001" || a.TownName != "PALMA" || a.StreetName != "" {
+		t.Errorf("Unexpected structured address %+v", a)
+	}
+	a = SpanishAddress("ES", "POLIGONO SON CASTELLO", "NAVE 3", "")
+	if len(a.AddressLines) != 2 || a.PostCode != "" {
+		t.Errorf("Expected unstructured address, got %+v", a)
+	}
+}
+
+func TestVariantConversion(t *testing.T) {
+	b, err := ioutil.ReadFile("../input-aeb1914.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []aeb19.Variant{aeb19.AEB1914, aeb19.AEB1944} {
+		lines := strings.Split(string(b), "\n")
+		for i, line := range lines {
+			if strings.HasPrefix(line, "01") || strings.HasPrefix(line, "02") || strings.HasPrefix(line, "03") {
+				lines[i] = line[:2] + string(v) + line[7:]
+			}
+		}
+		doctxt, err := aeb19.NewParser().Parse(charmap.ISO8859_1.NewDecoder().Reader(strings.NewReader(strings.Join(lines, "\n"))))
+		if err != nil {
+			t.Fatal(err)
+		}
+		doc := DebitTxtToXML(doctxt)
+		p := doc.Payments[0]
+		if v.IsB2B() != (p.LocalInstrument == "B2B") {
+			t.Errorf("%s: unexpected local instrument %s", v, p.LocalInstrument)
+		}
+	}
+}
+
+func TestCSB19(t *testing.T) {
+	f, err := os.Open("../input-csb19.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	doc, err := ReadDoc(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.InitiatingParty.ID != "ES18000B07123456" {
+		t.Errorf("Unexpected initiating party ID %s", doc.InitiatingParty.ID)
+	}
+	p := doc.Payments[0]
+	if p.Creditor.IBAN != "ES9121000418450200051332" || p.Creditor.BIC != "CAIXESBBXXX" || p.CtrlSum != "1183.95" {
+		t.Errorf("Unexpected payment %+v", p)
+	}
+	tx := p.Transactions[0]
+	if tx.Mandate.ID != "B07123456000-000000000001" || tx.BIC != "BSCHESMMXXX" {
+		t.Errorf("Unexpected transaction %+v", tx)
+	}
+	if tx.ID != "B07123456-20131220-000000000001" || tx.RemittanceInfo[0] != "FACT 2013/001 CUOTA DICIEMBRE SERVICIO DE MANTENIMIENTO" {
+		t.Errorf("Unexpected transaction %+v", tx)
+	}
+	//2038 was Bankia, absorbed by CaixaBank
+	if tx := p.Transactions[2]; tx.BIC != "" {
+		t.Errorf("Expected no BIC for an absorbed entity, got %s", tx.BIC)
+	}
+	out, err := doc.WriteBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(out, []byte("<Othr>\n              <Id>NOTPROVIDED</Id>")) || bytes.Contains(out, []byte("<BIC></BIC>")) {
+		t.Errorf("Expected a NOTPROVIDED debtor agent:\n%s", out)
+	}
+	if got := MandateRule("{reference}/{internal}").mandateID(csb19.Orderer{}, &csb19.Debit{Reference: "R1", InternalReference: "I1"}); got != "R1/I1" {
+		t.Errorf("Unexpected mandate ID %s", got)
+	}
+}
+
+func TestJSONProfile(t *testing.T) {
+	in := `{"initiatingParty": {"name": "E", "id": "ES18000B07123456"}, "creditors": [{"creditor": {"id": "ES18000B07123456", "name": "E", "iban": "ES9121000418450200051332"},
+		"payments": [{"collectionDate": "2024-01-10", "sequenceType": "RCUR", "transactions": [{"id": "T1", "amount": 1000,
+		"mandate": {"id": "M1", "signatureDate": "2012-03-15"}, "debtor": {"name": "D", "iban": "ES7620770024003102575766"}}]}]}]}`
+	profile := &Profile{Name: "test", BIC: "TESTESMMXXX", ChargeBearer: "SLEV"}
+	var derived []DerivedBIC
+	doc, err := JSONToXMLDocWithOptions(strings.NewReader(in), Options{Profile: profile, Derived: func(d DerivedBIC) { derived = append(derived, d) }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := doc.Payments[0].Creditor; c.BIC != "TESTESMMXXX" || c.ChargeBearer != "SLEV" {
+		t.Errorf("Expected the profile BIC and charge bearer, got %+v", c)
+	}
+	if len(derived) != 1 || derived[0].Source != "profile" {
+		t.Errorf("Unexpected derived BICs %+v", derived)
+	}
+}
+
+func TestReadContext(t *testing.T) {
+	input, err := ioutil.ReadFile("testdata/category-purpose.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var warnings []string
+	opts := Options{Warn: func(msg string) { warnings = append(warnings, msg) }}
+	doc, err := ReadContext(context.Background(), bytes.NewReader(input), "aeb19", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.TransacNb != 3 || len(warnings) != 1 || !strings.Contains(warnings[0], "XXXX") {
+		t.Errorf("Unexpected %d transactions and warnings %q", doc.TransacNb, warnings)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ReadContext(ctx, bytes.NewReader(input), "", Options{}); err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if _, err := ReadContext(context.Background(), bytes.NewReader(input), "csv", Options{}); err == nil {
+		t.Error("Expected an error for csv without mapping")
+	}
+}
+
+//withDebit returns the example AEB 19.14 file with the ID and concept of its
+//debit replaced
+func withDebit(t *testing.T, id, concept string) string {
+	b, err := ioutil.ReadFile("../input-aeb1914.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(string(b), "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, "03") {
+			lines[i] = line[:10] + fmt.Sprintf("%-35s", id) + line[45:441] + fmt.Sprintf("%-140s", concept) + line[581:]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestRFReference(t *testing.T) {
+	tests := []struct {
+		source       RFSource
+		id, concept  string
+		ref, ustrd   string
+		expectsError bool
+	}{
+		{RFFromConcept, "RECIBO002401", "RF18 5390 0754 7034", "RF18539007547034", "", false},
+		{RFFromConcept, "RECIBO002401", "CONCEPTO DEL ADEUDO FRA.1234", "", "CONCEPTO DEL ADEUDO FRA.1234", false},
+		{RFFromConcept, "RECIBO002401", "RF19 5390 0754 7034", "", "RF19 5390 0754 7034", false},
+		{RFFromID, "RECIBO002401", "CONCEPTO", "RF32RECIBO002401", "", false},
+		{RFFromID, "RECIBO-002401", "CONCEPTO", "", "", true},
+		{RFFromID, "RECIBO0000000000002401", "CONCEPTO", "", "", true},
+		{RFNone, "RECIBO002401", "RF18539007547034", "", "RF18539007547034", false},
+	}
+	for _, test := range tests {
+		doc, err := Latin1DebitTxtToXMLDocWithOptions(strings.NewReader(withDebit(t, test.id, test.concept)), Options{RFReference: test.source})
+		if test.expectsError {
+			if err == nil {
+				t.Errorf("Expected error for ID %s", test.id)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		tx := doc.Payments[0].Transactions[0]
+		var ref string
+		if tx.CreditorReference != nil {
+			ref = tx.CreditorReference.Ref
+		}
+		ustrd := strings.Join(tx.RemittanceInfo, " ")
+		if ref != test.ref || ustrd != test.ustrd {
+			t.Errorf("%q %q: expected reference %q and Ustrd %q, got %q and %q", test.id, test.concept, test.ref, test.ustrd, ref, ustrd)
+		}
+	}
+}
@@ -0,0 +1,439 @@
+package convert
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"strings"
+
+	"github.com/apsl/sepakit/ledger"
+	"github.com/apsl/sepakit/redact"
+	"github.com/apsl/sepakit/remittance"
+	"github.com/apsl/sepakit/sepadebit"
+	"github.com/apsl/sepakit/translit"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+)
+
+//PainVersion is the pain.008 version written by the Converter
+const PainVersion = "pain.008.001.02"
+
+//InputEncodings are the text encodings of AEB 19 and CSB 19 inputs by name
+var InputEncodings = map[string]encoding.Encoding{
+	"iso-8859-1":   charmap.ISO8859_1,
+	"latin1":       charmap.ISO8859_1,
+	"windows-1252": charmap.Windows1252,
+	"cp1252":       charmap.Windows1252,
+	"cp850":        charmap.CodePage850,
+	"ibm850":       charmap.CodePage850,
+	"utf-8":        encoding.Nop,
+	"utf8":         encoding.Nop,
+}
+
+//ParseInputEncoding returns the input encoding for a name of InputEncodings
+func ParseInputEncoding(name string) (encoding.Encoding, error) {
+	if enc, ok := InputEncodings[strings.ToLower(name)]; ok {
+		return enc, nil
+	}
+	return nil, fmt.Errorf("Unknown input encoding %s", name)
+}
+
+//Validator checks a converted document before it is written. An error stops
+//the conversion
+type Validator func(doc *sepadebit.Document) error
+
+//Converter converts AEB 19, CSB 19, CSV, XLSX and JSON remittances to
+//pain.008 with its settings, given as Options to NewConverter. A Converter
+//may be used by several goroutines at once
+type Converter struct {
+	format         string
+	inputEncoding  encoding.Encoding
+	outputEncoding sepadebit.Encoding
+	outputFormat   string
+	opts           Options
+	sets           []*translit.Set
+	lengths        sepadebit.LengthPolicy
+	validators     []Validator
+	hooks          Hooks
+	logger         *log.Logger
+	ledger         *ledger.Ledger
+	force          bool
+}
+
+//Option is a setting of the Converter
+type Option func(c *Converter) error
+
+//NewConverter returns a Converter reading ISO-8859-1 text, detecting the
+//input format and writing ISO-8859-1 pain.008 with DefaultProfile, then
+//applies opts
+func NewConverter(opts ...Option) (*Converter, error) {
+	c := &Converter{
+		inputEncoding: charmap.ISO8859_1,
+		outputFormat:  "xml",
+	}
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+//WithOptions sets the RF references, parties, address mode, mandate rule
+//and CSV mapping of opts. Its other fields are set by other Option functions
+func WithOptions(opts Options) Option {
+	return func(c *Converter) error {
+		c.opts.RFReference = opts.RFReference
+		c.opts.Parties = opts.Parties
+		c.opts.Address = opts.Address
+		c.opts.MandateRule = opts.MandateRule
+		c.opts.Mapping = opts.Mapping
+		return nil
+	}
+}
+
+//WithInputFormat sets the input format, one of Formats. It is detected when
+//empty
+func WithInputFormat(format string) Option {
+	return func(c *Converter) error {
+		if format != "" && !contains(Formats, format) {
+			return fmt.Errorf("Unknown input format %s", format)
+		}
+		c.format = format
+		return nil
+	}
+}
+
+//WithInputEncoding sets the encoding of AEB 19 and CSB 19 text
+func WithInputEncoding(enc encoding.Encoding) Option {
+	return func(c *Converter) error {
+		c.inputEncoding = enc
+		return nil
+	}
+}
+
+//WithOutputEncoding sets the encoding of the XML document
+func WithOutputEncoding(enc sepadebit.Encoding) Option {
+	return func(c *Converter) error {
+		c.outputEncoding = enc
+		return nil
+	}
+}
+
+//WithOutputFormat sets the output format: xml (pain.008) or json (remittance)
+func WithOutputFormat(format string) Option {
+	return func(c *Converter) error {
+		if format != "xml" && format != "json" {
+			return fmt.Errorf("Unknown output format %s", format)
+		}
+		c.outputFormat = format
+		return nil
+	}
+}
+
+//WithProfile sets the bank profile
+func WithProfile(p *Profile) Option {
+	return func(c *Converter) error {
+		c.opts.Profile = p
+		return nil
+	}
+}
+
+//WithPainVersion checks the pain.008 version required by the bank. Only
+//PainVersion is written
+func WithPainVersion(version string) Option {
+	return func(c *Converter) error {
+		if version != PainVersion {
+			return fmt.Errorf("Unsupported pain version %s, only %s is written", version, PainVersion)
+		}
+		return nil
+	}
+}
+
+//WithCharsets allows the extended character sets besides EPC basic Latin
+func WithCharsets(sets ...*translit.Set) Option {
+	return func(c *Converter) error {
+		c.sets = sets
+		return nil
+	}
+}
+
+//WithLengths sets the policy for texts longer than allowed, which fails by
+//default
+func WithLengths(policy sepadebit.LengthPolicy) Option {
+	return func(c *Converter) error {
+		c.lengths = policy
+		return nil
+	}
+}
+
+//WithValidators adds validators, run in order after the hooks
+func WithValidators(v ...Validator) Option {
+	return func(c *Converter) error {
+		c.validators = append(c.validators, v...)
+		return nil
+	}
+}
+
+//WithTransactionHooks adds transaction hooks, called in order
+func WithTransactionHooks(h ...TransactionHook) Option {
+	return func(c *Converter) error {
+		c.hooks.Transaction = append(c.hooks.Transaction, h...)
+		return nil
+	}
+}
+
+//WithPaymentHooks adds payment hooks, called in order
+func WithPaymentHooks(h ...PaymentHook) Option {
+	return func(c *Converter) error {
+		c.hooks.Payment = append(c.hooks.Payment, h...)
+		return nil
+	}
+}
+
+//WithWriteHooks adds write hooks, called in order
+func WithWriteHooks(h ...WriteHook) Option {
+	return func(c *Converter) error {
+		c.hooks.Write = append(c.hooks.Write, h...)
+		return nil
+	}
+}
+
+//WithLogger logs the warnings of every conversion, which are otherwise only
+//in its Report
+func WithLogger(l *log.Logger) Option {
+	return func(c *Converter) error {
+		c.logger = l
+		return nil
+	}
+}
+
+//WithLedger records every conversion in l, refusing those whose output,
+//input or transactions were already recorded, unless force is set. Forced
+//duplicates are reported as warnings
+func WithLedger(l *ledger.Ledger, force bool) Option {
+	return func(c *Converter) error {
+		c.ledger = l
+		c.force = force
+		return nil
+	}
+}
+
+//WithClock sets the clock of the creation date times and identifiers
+func WithClock(clock sepadebit.Clock) Option {
+	return func(c *Converter) error {
+		c.opts.Clock = clock
+		return nil
+	}
+}
+
+//WithIDGenerator sets the generator of MsgId and PmtInfId identifiers
+func WithIDGenerator(g sepadebit.IDGenerator) Option {
+	return func(c *Converter) error {
+		c.opts.IDGenerator = g
+		return nil
+	}
+}
+
+//OutputFormat returns the output format, xml or json
+func (c *Converter) OutputFormat() string {
+	return c.outputFormat
+}
+
+//Report describes a conversion, for the logs and the audit: the input, the
+//output, the settings, the altered texts, the derived BICs and the checks.
+//Its MessageID links it to the ledger entry of the conversion
+type Report struct {
+	Tool    string `json:"tool"`
+	Version string `json:"version"`
+	//Created is the creation date time of the document
+	Created string `json:"created,omitempty"`
+	Format  string `json:"format"`
+	//InputHash and OutputHash are the hex SHA-256 hashes of the input and
+	//of the output
+	InputHash    string `json:"inputHash,omitempty"`
+	OutputFormat string `json:"outputFormat"`
+	OutputHash   string `json:"outputHash,omitempty"`
+	Profile      string `json:"profile"`
+	MessageID    string `json:"messageId,omitempty"`
+	Payments     int    `json:"payments"`
+	Transactions int    `json:"transactions"`
+	CtrlSum      string `json:"ctrlSum,omitempty"`
+	//Warnings are the non-fatal issues, such as dropped purpose codes
+	Warnings []string `json:"warnings,omitempty"`
+	//Transformations is the log of the texts altered to fit the character
+	//sets or the lengths. Names are redacted to their initials
+	Transformations []Transformation `json:"transformations,omitempty"`
+	DerivedBICs     []DerivedBIC     `json:"derivedBics,omitempty"`
+	//Validations are the checks of the document: "lengths", "amendments",
+	//"ibans", "totals" and the validators. A failed check stops the
+	//conversion
+	Validations []Validation `json:"validations,omitempty"`
+	//Ledger is "recorded" when the conversion was recorded in the ledger
+	Ledger string `json:"ledger,omitempty"`
+	//Hash and Signature are set by Seal
+	Hash      string `json:"hash,omitempty"`
+	Signature string `json:"signature,omitempty"`
+}
+
+//Transformation is a text altered by the conversion
+type Transformation struct {
+	Field    string `json:"field"`
+	Original string `json:"original"`
+	Result   string `json:"result"`
+	//Cause is "charset" or "length"
+	Cause string `json:"cause"`
+}
+
+//transformation returns the Transformation of a field, with the names
+//redacted
+func transformation(field, original, result, cause string) Transformation {
+	return Transformation{Field: field, Original: redact.Field(field, original), Result: redact.Field(field, result), Cause: cause}
+}
+
+//Convert reads a remittance from in and writes its conversion to out,
+//committing it when out is a Committer. Nothing is written when the
+//conversion fails, and the report describes the conversion up to the failure
+func (c *Converter) Convert(ctx context.Context, in io.Reader, out io.Writer) (Report, error) {
+	inputHash := sha256.New()
+	in = io.TeeReader(in, inputHash)
+	doc, report, err := c.Document(ctx, in)
+	if err != nil {
+		return report, err
+	}
+
+	var buf bytes.Buffer
+	if c.outputFormat == "json" {
+		rem, err := remittance.FromSEPA(doc)
+		if err != nil {
+			return report, err
+		}
+		if err := rem.Write(&buf); err != nil {
+			return report, err
+		}
+	} else if err := doc.Write(&buf, c.outputEncoding); err != nil {
+		return report, err
+	}
+	//the rest of the input, for its hash
+	if _, err := io.Copy(ioutil.Discard, in); err != nil {
+		return report, err
+	}
+	report.InputHash = hex.EncodeToString(inputHash.Sum(nil))
+	report.OutputHash = ledger.Hash(buf.Bytes())
+	//the ledger is held until the output is recorded, so that concurrent
+	//conversions of the same debits are refused
+	var reservation *ledger.Reservation
+	if c.ledger != nil {
+		entry := ledger.NewEntry(doc, buf.Bytes())
+		entry.InputHash = report.InputHash
+		if c.opts.Clock != nil {
+			entry.Time = c.opts.Clock.Now()
+		}
+		var dups []ledger.Duplicate
+		if reservation, dups, err = c.ledger.Reserve(entry, c.force); err != nil {
+			return report, err
+		}
+		defer reservation.Release()
+		for _, d := range dups {
+			c.warn(&report, d.String())
+		}
+	}
+	if _, err = buf.WriteTo(out); err != nil {
+		return report, err
+	}
+	if cm, ok := out.(Committer); ok {
+		if err := cm.Commit(); err != nil {
+			return report, err
+		}
+	}
+	if reservation != nil {
+		if err := reservation.Record(); err != nil {
+			return report, err
+		}
+		report.Ledger = "recorded"
+	}
+	return report, nil
+}
+
+//Document reads a remittance from in and converts it as Convert, without
+//writing it nor recording it in the ledger
+func (c *Converter) Document(ctx context.Context, in io.Reader) (*sepadebit.Document, Report, error) {
+	report := Report{Tool: "sepakit", Version: toolVersion(), OutputFormat: c.outputFormat, Profile: DefaultProfile.Name}
+	if c.opts.Profile != nil {
+		report.Profile = c.opts.Profile.Name
+	}
+	opts := c.opts
+	opts.Transliterator = translit.New(c.sets...)
+	opts.Warn = func(msg string) {
+		c.warn(&report, msg)
+	}
+	opts.Derived = func(d DerivedBIC) {
+		d.IBAN = redact.IBAN(d.IBAN)
+		report.DerivedBICs = append(report.DerivedBICs, d)
+	}
+	doc, format, err := read(ctx, in, c.format, c.inputEncoding, opts)
+	report.Format = format
+	if err != nil {
+		return nil, report, err
+	}
+	if err := ApplyHooks(doc, c.hooks); err != nil {
+		return nil, report, err
+	}
+	//again for the texts set by the hooks
+	doc.Transliterate(opts.Transliterator)
+	for _, a := range opts.Transliterator.Alterations() {
+		report.Transformations = append(report.Transformations, transformation(a.Field, a.Original, a.Result, "charset"))
+	}
+	warnings, err := doc.EnforceLengths(c.lengths)
+	for _, w := range warnings {
+		report.Transformations = append(report.Transformations, transformation(w.Field, w.Original, w.Result, "length"))
+	}
+	if err := report.validate("lengths", err); err != nil {
+		return nil, report, err
+	}
+	if err := report.validate("amendments", doc.CheckAmendments()); err != nil {
+		return nil, report, err
+	}
+	report.Created = doc.CreationDateTime
+	report.MessageID = doc.MsgID
+	report.Payments = len(doc.Payments)
+	report.Transactions = doc.TransacNb
+	report.CtrlSum = doc.CtrlSum
+	if err := report.validate("ibans", checkIBANs(doc)); err != nil {
+		return nil, report, err
+	}
+	if err := report.validate("totals", checkTotals(doc)); err != nil {
+		return nil, report, err
+	}
+	for i, v := range c.validators {
+		if err := report.validate(fmt.Sprintf("validator %d", i+1), v(doc)); err != nil {
+			return nil, report, err
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, report, err
+	}
+	return doc, report, nil
+}
+
+//warn adds a warning to report and logs it
+func (c *Converter) warn(report *Report, msg string) {
+	report.Warnings = append(report.Warnings, msg)
+	if c.logger != nil {
+		c.logger.Println(msg)
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
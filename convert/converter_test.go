@@ -0,0 +1,290 @@
+package convert
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apsl/sepakit/ledger"
+	"github.com/apsl/sepakit/sepadebit"
+	"github.com/apsl/sepakit/translit"
+	"golang.org/x/text/encoding/charmap"
+)
+
+func testConverter(t *testing.T, opts ...Option) *Converter {
+	opts = append([]Option{
+		WithClock(sepadebit.FixedClock(time.Date(2013, 12, 17, 17, 29, 52, 0, time.UTC))),
+		WithIDGenerator(sepadebit.NewSeededIDGenerator(42)),
+	}, opts...)
+	c, err := NewConverter(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestConverterGolden(t *testing.T) {
+	input, err := ioutil.ReadFile("../input-aeb1914.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	report, err := testConverter(t).Convert(context.Background(), bytes.NewReader(input), &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := ioutil.ReadFile("testdata/input-aeb1914.golden.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Bytes(), expected) {
+		t.Errorf("Output differs from the golden file:\n%s", out.String())
+	}
+	if report.Format != "aeb19" || report.Payments != 1 || report.Transactions != 1 || report.CtrlSum != "123.45" || report.MessageID == "" {
+		t.Errorf("Unexpected report %+v", report)
+	}
+}
+
+type renameHook struct{}
+
+func (renameHook) BeforeWrite(doc *sepadebit.Document) error {
+	for _, p := range doc.Payments {
+		p.Creditor.Name = "ÆON " + strings.Repeat("X", 80)
+	}
+	return nil
+}
+
+//ibanHook breaks the check digits of the first debtor IBAN
+type ibanHook struct{}
+
+func (ibanHook) BeforeWrite(doc *sepadebit.Document) error {
+	doc.Payments[0].Transactions[0].Debtor.IBAN = "ES0020770024003102575766"
+	return nil
+}
+
+func TestConverterSettings(t *testing.T) {
+	input, err := ioutil.ReadFile("../input-aeb1914.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	//the same file as written by a DOS program, with a Spanish debtor name
+	text := strings.Replace(string(mustDecode(t, input)), "NOMBRE DEL DEUDOR, S.L.", "MUÑOZ IBÁÑEZ, S.L.     ", 1)
+	cp850, err := charmap.CodePage850.NewEncoder().String(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	validated := false
+	c := testConverter(t,
+		WithInputFormat("aeb19"),
+		WithInputEncoding(charmap.CodePage850),
+		WithOutputEncoding(sepadebit.UTF8),
+		WithCharsets(translit.Spanish),
+		WithLengths(sepadebit.LengthPolicy{Mode: sepadebit.LengthTruncate, Limits: map[string]int{"Nm": 70}}),
+		WithWriteHooks(renameHook{}),
+		WithValidators(func(doc *sepadebit.Document) error {
+			validated = true
+			return nil
+		}),
+	)
+	var out bytes.Buffer
+	report, err := c.Convert(context.Background(), strings.NewReader(cp850), &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !validated || !strings.Contains(out.String(), `encoding="utf-8"`) || !strings.Contains(out.String(), "<Nm>MUÑOZ IBÁÑEZ, S.L.</Nm>") || !strings.Contains(out.String(), "<Nm>AEON XXX") {
+		t.Errorf("Unexpected output:\n%s", out.String())
+	}
+	var causes []string
+	for _, tr := range report.Transformations {
+		causes = append(causes, tr.Cause)
+	}
+	if strings.Join(causes, ",") != "charset,length" {
+		t.Errorf("Expected the truncated name in the transformations, got %+v", report.Transformations)
+	}
+
+	failing := testConverter(t, WithValidators(func(doc *sepadebit.Document) error {
+		return errors.New("Rejected")
+	}))
+	out.Reset()
+	if _, err := failing.Convert(context.Background(), bytes.NewReader(input), &out); err == nil || out.Len() != 0 {
+		t.Errorf("Expected a validation error and no output, got %v and %d bytes", err, out.Len())
+	}
+	out.Reset()
+	report, err = testConverter(t, WithWriteHooks(ibanHook{})).Convert(context.Background(), bytes.NewReader(input), &out)
+	if err == nil || out.Len() != 0 {
+		t.Errorf("Expected an IBAN error and no output, got %v and %d bytes", err, out.Len())
+	}
+	if v := report.Validations[len(report.Validations)-1]; v.Check != "ibans" || v.Passed {
+		t.Errorf("Expected the failed IBAN check in the report, got %+v", v)
+	}
+}
+
+func TestConverterOptionErrors(t *testing.T) {
+	for _, opt := range []Option{WithPainVersion("pain.008.001.08"), WithInputFormat("mt940"), WithOutputFormat("pdf")} {
+		if _, err := NewConverter(opt); err == nil {
+			t.Error("Expected an option error")
+		}
+	}
+	if _, err := NewConverter(WithPainVersion(PainVersion)); err != nil {
+		t.Error(err)
+	}
+	if _, err := ParseInputEncoding("CP850"); err != nil {
+		t.Error(err)
+	}
+}
+
+func mustDecode(t *testing.T, latin1 []byte) []byte {
+	s, err := charmap.ISO8859_1.NewDecoder().Bytes(latin1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestConverterLedger(t *testing.T) {
+	input, err := ioutil.ReadFile("../input-aeb1914.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	book, err := ledger.Open(filepath.Join(t.TempDir(), "ledger.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if _, err := testConverter(t, WithLedger(book, false)).Convert(context.Background(), bytes.NewReader(input), &out); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	_, err = testConverter(t, WithLedger(book, false)).Convert(context.Background(), bytes.NewReader(input), &out)
+	var dup ledger.DuplicateError
+	if !errors.As(err, &dup) || out.Len() != 0 {
+		t.Fatalf("Expected a duplicate error and no output, got %v", err)
+	}
+	report, err := testConverter(t, WithLedger(book, true)).Convert(context.Background(), bytes.NewReader(input), &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Len() == 0 || len(report.Warnings) == 0 {
+		t.Errorf("Expected a forced conversion with warnings, got %+v", report)
+	}
+	entries, err := book.Query(ledger.Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].InputHash != ledger.Hash(input) {
+		t.Errorf("Expected 2 entries with the input hash, got %+v", entries)
+	}
+}
+
+func TestConverterLedgerConcurrent(t *testing.T) {
+	input, err := ioutil.ReadFile("../input-aeb1914.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	book, err := ledger.Open(filepath.Join(t.TempDir(), "ledger.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := testConverter(t, WithLedger(book, false))
+	errs := make(chan error, 8)
+	for i := 0; i < cap(errs); i++ {
+		go func() {
+			_, err := c.Convert(context.Background(), bytes.NewReader(input), ioutil.Discard)
+			errs <- err
+		}()
+	}
+	converted := 0
+	for i := 0; i < cap(errs); i++ {
+		var dup ledger.DuplicateError
+		if err := <-errs; err == nil {
+			converted++
+		} else if !errors.As(err, &dup) {
+			t.Error(err)
+		}
+	}
+	if entries, _ := book.Query(ledger.Query{}); converted != 1 || len(entries) != 1 {
+		t.Errorf("Expected a single conversion recorded, got %d conversions and %d entries", converted, len(entries))
+	}
+}
+
+func TestConverterFile(t *testing.T) {
+	input, err := ioutil.ReadFile("../input-aeb1914.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "out.xml")
+	if err := ioutil.WriteFile(path, []byte("previous"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	convertFile := func(in []byte) error {
+		f, err := CreateFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		_, err = testConverter(t).Convert(context.Background(), bytes.NewReader(in), f)
+		return err
+	}
+	if err := convertFile([]byte("{")); err == nil {
+		t.Fatal("Expected an error")
+	}
+	if b, err := ioutil.ReadFile(path); err != nil || string(b) != "previous" {
+		t.Errorf("Failed conversion replaced the file: %q", b)
+	}
+	if err := convertFile(input); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := ioutil.ReadFile(path); err != nil || !bytes.HasPrefix(b, []byte("<?xml")) {
+		t.Errorf("File not replaced: %q", b)
+	}
+	if files, _ := ioutil.ReadDir(filepath.Dir(path)); len(files) != 1 {
+		t.Errorf("Temporary files left: %d files", len(files))
+	}
+}
+
+func TestConverterAudit(t *testing.T) {
+	input, err := ioutil.ReadFile("../input-aeb1914.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reports []Report
+	for i := 0; i < 2; i++ {
+		var out bytes.Buffer
+		report, err := testConverter(t).Convert(context.Background(), bytes.NewReader(input), &out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.InputHash != ledger.Hash(input) || report.OutputHash != ledger.Hash(out.Bytes()) {
+			t.Errorf("Wrong hashes in %+v", report)
+		}
+		if err := report.Seal([]byte("key")); err != nil {
+			t.Fatal(err)
+		}
+		reports = append(reports, report)
+	}
+	if reports[0].Hash != reports[1].Hash || reports[0].Signature == "" {
+		t.Errorf("Expected the same sealed report, got %+v and %+v", reports[0], reports[1])
+	}
+	report := reports[0]
+	if report.Profile != "caixabank" || report.Created != "2013-12-17T17:29:52" || len(report.Validations) != 4 {
+		t.Errorf("Unexpected report %+v", report)
+	}
+	if len(report.DerivedBICs) != 1 || report.DerivedBICs[0].BIC != "CAIXESBBXXX" || report.DerivedBICs[0].Source != "profile" {
+		t.Errorf("Expected the profile creditor BIC, got %+v", report.DerivedBICs)
+	}
+	if err := report.Verify([]byte("key")); err != nil {
+		t.Error(err)
+	}
+	if err := report.Verify([]byte("other")); err == nil {
+		t.Error("Expected a signature error with another key")
+	}
+	report.CtrlSum = "1.00"
+	if err := report.Verify(nil); err == nil {
+		t.Error("Expected a hash error on an altered report")
+	}
+}
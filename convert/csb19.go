@@ -0,0 +1,153 @@
+package convert
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/apsl/sepakit/ccc"
+	"github.com/apsl/sepakit/csb19"
+	"github.com/apsl/sepakit/redact"
+	"github.com/apsl/sepakit/sepadebit"
+	"golang.org/x/text/encoding/charmap"
+)
+
+//LegacyMandateDate is the signature date of the mandates migrated from
+//pre-SEPA debits, as agreed by the Spanish banks
+var LegacyMandateDate = time.Date(2009, 10, 31, 0, 0, 0, 0, time.UTC)
+
+//DefaultMandateRule builds CSB 19 mandate IDs from the orderer NIF and the
+//debit reference
+const DefaultMandateRule = "{nif}{suffix}-{reference}"
+
+//MandateRule builds the mandate IDs of CSB 19 debits, which have none. The
+//placeholders {nif}, {suffix}, {reference} and {internal} are replaced by the
+//orderer NIF and suffix and the debit reference and internal reference
+type MandateRule string
+
+//mandateID applies the rule to a debit
+func (r MandateRule) mandateID(o csb19.Orderer, d *csb19.Debit) string {
+	if r == "" {
+		r = DefaultMandateRule
+	}
+	return strings.NewReplacer(
+		"{nif}", o.NIF,
+		"{suffix}", o.Suffix,
+		"{reference}", d.Reference,
+		"{internal}", d.InternalReference,
+	).Replace(string(r))
+}
+
+//Latin1CSB19TxtToXMLDocWithOptions creates XML SEPA Document from a CSB 19
+//TXT Document using opts. Transforms input to ISO-8859-1
+func Latin1CSB19TxtToXMLDocWithOptions(in io.Reader, opts Options) (*sepadebit.Document, error) {
+	return csb19TxtToXMLDoc(charmap.ISO8859_1.NewDecoder().Reader(in), opts)
+}
+
+//csb19TxtToXMLDoc creates XML SEPA Document from a decoded CSB 19 TXT Document
+func csb19TxtToXMLDoc(r io.Reader, opts Options) (*sepadebit.Document, error) {
+	parser := csb19.NewParser()
+	parser.Warn = opts.Warn
+	doctxt, err := parser.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	return CSB19TxtToXMLWithOptions(doctxt, opts)
+}
+
+//CSB19TxtToXMLWithOptions creates XML SEPA Document from a CSB 19 Document.
+//CCC accounts are converted to IBAN and their BICs derived from the entity,
+//the creditor identifiers are built from the orderer NIFs and the mandate
+//IDs from opts.MandateRule. EndToEndIds are NIF-DATE-REFERENCE
+func CSB19TxtToXMLWithOptions(doctxt *csb19.Document, opts Options) (*sepadebit.Document, error) {
+	profile := opts.Profile
+	if profile == nil {
+		profile = DefaultProfile
+	}
+	docxml := sepadebit.NewDocumentWithOptions(opts.Options)
+	presenterID, err := ccc.CreditorID(doctxt.Presenter.NIF, doctxt.Presenter.Suffix)
+	if err != nil {
+		return nil, err
+	}
+	docxml.SetInitiatingParty(doctxt.Presenter.Name, presenterID)
+
+	for _, op := range doctxt.OrdererPayments {
+		o := op.Orderer
+		creditorID, err := ccc.CreditorID(o.NIF, o.Suffix)
+		if err != nil {
+			return nil, err
+		}
+		iban, err := ccc.IBAN(o.Account)
+		if err != nil {
+			return nil, fmt.Errorf("Orderer %s: %s", redact.ID(o.NIF), err)
+		}
+		bic, err := ccc.BIC(iban)
+		if err != nil {
+			opts.warnf("Orderer %s: %s, using %s", redact.ID(o.NIF), err, profile.BIC)
+			opts.derived("Creditor "+creditorID, iban, profile.BIC, "profile")
+			bic = profile.BIC
+		} else {
+			opts.derived("Creditor "+creditorID, iban, bic, "entity")
+		}
+		date := o.ChargeDate.Format("20060102")
+		p := &sepadebit.Payment{
+			Creditor: &sepadebit.Creditor{
+				ID:               creditorID,
+				Name:             o.Name,
+				IBAN:             iban,
+				SchemeName:       "SEPA",
+				BIC:              bic,
+				ChargeBearer:     profile.ChargeBearer,
+				UltimateCreditor: opts.Parties.ultimateCreditor(creditorID),
+			},
+			RequestedCollectionDate: o.ChargeDate.Format("2006-01-02"),
+			ID:                      docxml.NewID("rem" + date),
+			Method:                  "DD",
+			ServiceLevel:            "SEPA",
+			LocalInstrument:         "CORE",
+			SequenceType:            "RCUR",
+		}
+		docxml.AddPayment(p)
+		for _, d := range op.Debits {
+			iban, err := ccc.IBAN(d.Account)
+			if err != nil {
+				return nil, fmt.Errorf("Debit %s: %s", d.Reference, err)
+			}
+			id := o.NIF + "-" + date + "-" + d.Reference
+			//debtors of unknown entities get a NOTPROVIDED agent
+			bic, err := ccc.BIC(iban)
+			if err != nil {
+				opts.warnf("Debit %s: %s, writing the debtor agent as %s", d.Reference, err, sepadebit.NotProvided)
+			}
+			opts.derived("Transaction "+id, iban, bic, "entity")
+			mandateID := opts.MandateRule.mandateID(o, d)
+			t := sepadebit.Transaction{
+				ID: id,
+				Mandate: sepadebit.MandateInfo{
+					ID:            mandateID,
+					SignatureDate: sepadebit.Date(LegacyMandateDate),
+				},
+				Debtor: sepadebit.Debtor{
+					IBAN: iban,
+					BIC:  bic,
+					Name: d.Name,
+				},
+				Amount: sepadebit.TAmount{
+					Amount:   fmt.Sprintf("%.2f", d.Amount),
+					Currency: "EUR",
+				},
+			}
+			t.UltimateDebtor = opts.Parties.ultimateDebtor(creditorID, mandateID, d.Reference)
+			if len(d.Concepts) > 0 {
+				t.RemittanceInfo = []string{strings.Join(d.Concepts, " ")}
+			}
+			p.Transactions = append(p.Transactions, t)
+		}
+	}
+	if err := docxml.UpdateTotals(); err != nil {
+		return nil, err
+	}
+	docxml.Transliterate(docxml.Options().Transliterator)
+	return docxml, nil
+}
@@ -0,0 +1,64 @@
+package convert
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+//Committer is an output completed once the conversion is written, such as a
+//File. Convert commits its output before recording it in the ledger
+type Committer interface {
+	Commit() error
+}
+
+//File is an output file written to a temporary file in its folder, renamed
+//to its path by Commit, so that readers of the folder never see a partial
+//file and an existing file is only replaced by a complete conversion
+type File struct {
+	path string
+	tmp  *os.File
+	done bool
+}
+
+//CreateFile returns the File of path
+func CreateFile(path string) (*File, error) {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return nil, err
+	}
+	return &File{path: path, tmp: tmp}, nil
+}
+
+func (f *File) Write(p []byte) (int, error) {
+	return f.tmp.Write(p)
+}
+
+//Commit renames the temporary file to the path of f
+func (f *File) Commit() error {
+	if f.done {
+		return nil
+	}
+	f.done = true
+	err := f.tmp.Close()
+	if err == nil {
+		err = os.Chmod(f.tmp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(f.tmp.Name(), f.path)
+	}
+	if err != nil {
+		os.Remove(f.tmp.Name())
+	}
+	return err
+}
+
+//Close removes the temporary file of a File not committed
+func (f *File) Close() error {
+	if f.done {
+		return nil
+	}
+	f.done = true
+	f.tmp.Close()
+	return os.Remove(f.tmp.Name())
+}
@@ -0,0 +1,108 @@
+package convert
+
+import (
+	"github.com/apsl/sepakit/sepadebit"
+)
+
+//Hooks are the extension points of a conversion, by kind. A value
+//implementing several kinds is added to each of them
+type Hooks struct {
+	Transaction []TransactionHook
+	Payment     []PaymentHook
+	Write       []WriteHook
+}
+
+//TransactionHook is called with every transaction of the converted document
+//and its payment
+type TransactionHook interface {
+	//BeforeTransaction returns the transactions replacing t: t itself,
+	//possibly modified, none to skip it, or several to split it. An error
+	//stops the conversion
+	BeforeTransaction(p *sepadebit.Payment, t sepadebit.Transaction) ([]sepadebit.Transaction, error)
+}
+
+//PaymentHook is called with every payment of the converted document, once
+//its transactions went through the TransactionHooks
+type PaymentHook interface {
+	//AfterPayment returns the payments replacing p: p itself, possibly
+	//modified, none to skip it, or several to split it. New payments need
+	//their own identifier, see sepadebit.Document.NewID. An error stops the
+	//conversion
+	AfterPayment(p *sepadebit.Payment) ([]*sepadebit.Payment, error)
+}
+
+//WriteHook is called with the converted document before it is validated
+//and written. An error stops the conversion
+type WriteHook interface {
+	BeforeWrite(doc *sepadebit.Document) error
+}
+
+//ApplyHooks applies hooks to doc, in order: the TransactionHooks to every
+//transaction, the PaymentHooks to every payment and the WriteHooks to the
+//document. Payments left without transactions are removed, and the totals
+//are updated
+func ApplyHooks(doc *sepadebit.Document, hooks Hooks) error {
+	var payments []*sepadebit.Payment
+	for _, p := range doc.Payments {
+		var txs []sepadebit.Transaction
+		for _, t := range p.Transactions {
+			out, err := beforeTransaction(hooks.Transaction, p, t)
+			if err != nil {
+				return err
+			}
+			txs = append(txs, out...)
+		}
+		p.Transactions = txs
+		if len(p.Transactions) == 0 {
+			continue
+		}
+		if err := p.UpdateTotals(); err != nil {
+			return err
+		}
+		ps := []*sepadebit.Payment{p}
+		for _, ph := range hooks.Payment {
+			var next []*sepadebit.Payment
+			for _, p := range ps {
+				out, err := ph.AfterPayment(p)
+				if err != nil {
+					return err
+				}
+				next = append(next, out...)
+			}
+			ps = next
+		}
+		for _, p := range ps {
+			if len(p.Transactions) > 0 {
+				payments = append(payments, p)
+			}
+		}
+	}
+	doc.Payments = payments
+	if err := doc.UpdateTotals(); err != nil {
+		return err
+	}
+	for _, wh := range hooks.Write {
+		if err := wh.BeforeWrite(doc); err != nil {
+			return err
+		}
+	}
+	return doc.UpdateTotals()
+}
+
+//beforeTransaction passes t through the TransactionHooks, the transactions
+//returned by a hook going to the next one
+func beforeTransaction(hooks []TransactionHook, p *sepadebit.Payment, t sepadebit.Transaction) ([]sepadebit.Transaction, error) {
+	txs := []sepadebit.Transaction{t}
+	for _, th := range hooks {
+		var next []sepadebit.Transaction
+		for _, t := range txs {
+			out, err := th.BeforeTransaction(p, t)
+			if err != nil {
+				return nil, err
+			}
+			next = append(next, out...)
+		}
+		txs = next
+	}
+	return txs, nil
+}
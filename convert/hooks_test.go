@@ -0,0 +1,146 @@
+package convert
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/apsl/sepakit/sepadebit"
+)
+
+func readCSB19(t *testing.T) *sepadebit.Document {
+	f, err := os.Open("../input-csb19.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	doc, err := ReadDoc(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+//splitHook splits the debits over 500.00 in two halves, and moves them to a
+//payment of their own
+type splitHook struct {
+	doc *sepadebit.Document
+}
+
+func (h splitHook) BeforeTransaction(p *sepadebit.Payment, t sepadebit.Transaction) ([]sepadebit.Transaction, error) {
+	cents, err := sepadebit.ParseAmount(t.Amount.Amount)
+	if err != nil || cents <= 50000 {
+		return []sepadebit.Transaction{t}, err
+	}
+	first, second := t, t
+	first.ID, second.ID = t.ID+"-1", t.ID+"-2"
+	first.Amount.Amount = sepadebit.FormatAmount(cents / 2)
+	second.Amount.Amount = sepadebit.FormatAmount(cents - cents/2)
+	return []sepadebit.Transaction{first, second}, nil
+}
+
+func (h splitHook) AfterPayment(p *sepadebit.Payment) ([]*sepadebit.Payment, error) {
+	split := *p
+	split.ID = h.doc.NewID("split")
+	split.Transactions = nil
+	var rest []sepadebit.Transaction
+	for _, t := range p.Transactions {
+		if strings.HasSuffix(t.ID, "-1") || strings.HasSuffix(t.ID, "-2") {
+			split.Transactions = append(split.Transactions, t)
+		} else {
+			rest = append(rest, t)
+		}
+	}
+	p.Transactions = rest
+	return []*sepadebit.Payment{p, &split}, nil
+}
+
+func TestApplyHooks(t *testing.T) {
+	doc := readCSB19(t)
+	if err := ApplyHooks(doc, Hooks{Transaction: []TransactionHook{splitHook{doc}}, Payment: []PaymentHook{splitHook{doc}}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Payments) != 2 || doc.TransacNb != 4 || doc.CtrlSum != "1183.95" {
+		t.Fatalf("Unexpected document: %d payments, %d transactions, %s", len(doc.Payments), doc.TransacNb, doc.CtrlSum)
+	}
+	if p := doc.Payments[1]; p.TransacNb != 2 || p.CtrlSum != "1000.00" || p.Transactions[0].Amount.Amount != "500.00" {
+		t.Errorf("Unexpected split payment %+v", p)
+	}
+	if p := doc.Payments[0]; p.TransacNb != 2 || p.CtrlSum != "183.95" {
+		t.Errorf("Unexpected payment %+v", p)
+	}
+}
+
+const testRules = `{"rules": [
+  {"name": "no small debits", "when": {"amount": "60\\.50"}, "skip": true},
+  {"when": {"creditorId": "ES18000B07123456"}, "set": {"mandateId": "M-{id}", "concept": "{concept} ({debtorName})"}},
+  {"replace": [{"field": "concept", "pattern": "^FACT (\\d+)/", "with": "FACTURA $1-"}]}
+]}`
+
+func TestRules(t *testing.T) {
+	rules, err := LoadRules(strings.NewReader(testRules))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := readCSB19(t)
+	if err := ApplyHooks(doc, Hooks{Transaction: []TransactionHook{rules}}); err != nil {
+		t.Fatal(err)
+	}
+	if doc.TransacNb != 2 || doc.CtrlSum != "1123.45" {
+		t.Fatalf("Expected the 60.50 debit skipped, got %d transactions and %s", doc.TransacNb, doc.CtrlSum)
+	}
+	tx := doc.Payments[0].Transactions[1]
+	if tx.Mandate.ID != "M-B07123456-20131220-000000000003" {
+		t.Errorf("Unexpected mandate ID %s", tx.Mandate.ID)
+	}
+	if tx.RemittanceInfo[0] != "FACTURA 2013-003 (FERRER ROSSELLO, PERE)" {
+		t.Errorf("Unexpected concept %q", tx.RemittanceInfo[0])
+	}
+
+	for _, bad := range []string{
+		`{"rules": [{"when": {"colour": "red"}, "skip": true}]}`,
+		`{"rules": [{"set": {"creditorId": "X"}}]}`,
+		`{"rules": [{"set": {"concept": "{nothing}"}}]}`,
+		`{"rules": [{"when": {"amount": "("}, "skip": true}]}`,
+		`{"rules": [{"when": {"amount": "0"}}]}`,
+		`{"rulez": []}`,
+	} {
+		if _, err := LoadRules(strings.NewReader(bad)); err == nil {
+			t.Errorf("Expected an error for %s", bad)
+		}
+	}
+	for _, amount := range []string{"abc", "NaN", "1e3", "-5.00", "0.00", "10", "10.5", "10.505"} {
+		rules, err = LoadRules(strings.NewReader(`{"rules": [{"set": {"amount": "` + amount + `"}}]}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ApplyHooks(readCSB19(t), Hooks{Transaction: []TransactionHook{rules}}); err == nil || !strings.Contains(err.Error(), "Rule 1") {
+			t.Errorf("Expected a rule error for amount %s, got %v", amount, err)
+		}
+	}
+}
+
+func TestRulesCreditorReference(t *testing.T) {
+	doc := readCSB19(t)
+	tx := &doc.Payments[0].Transactions[0]
+	if err := tx.SetCreditorReference("RF18539007547034"); err != nil {
+		t.Fatal(err)
+	}
+	rules, err := LoadRules(strings.NewReader(`{"rules": [{"set": {"concept": "{concept} ({debtorName})"}}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ApplyHooks(doc, Hooks{Transaction: []TransactionHook{rules}}); err == nil || !strings.Contains(err.Error(), "RF18539007547034") {
+		t.Errorf("Expected an RF reference error, got %v", err)
+	}
+	rules, err = LoadRules(strings.NewReader(`{"rules": [{"set": {"concept": ""}}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ApplyHooks(doc, Hooks{Transaction: []TransactionHook{rules}}); err != nil {
+		t.Fatal(err)
+	}
+	if tx := doc.Payments[0].Transactions[0]; tx.CreditorReference == nil || tx.RemittanceInfo != nil {
+		t.Errorf("Expected the RF reference alone, got %+v", tx)
+	}
+}
@@ -0,0 +1,39 @@
+package convert
+
+import (
+	"io"
+
+	"github.com/apsl/sepakit/remittance"
+	"github.com/apsl/sepakit/sepadebit"
+)
+
+//JSONToXMLDocWithOptions creates XML SEPA Document from a JSON remittance
+//(see package remittance), taking identifiers and transliteration from opts.
+//Creditors without BIC or charge bearer get the ones of the profile, as in
+//AEB and spreadsheet conversions
+func JSONToXMLDocWithOptions(in io.Reader, opts Options) (*sepadebit.Document, error) {
+	r, err := remittance.Read(in)
+	if err != nil {
+		return nil, err
+	}
+	profile := opts.Profile
+	if profile == nil {
+		profile = DefaultProfile
+	}
+	for _, g := range r.Creditors {
+		c := &g.Creditor
+		if c.BIC == "" {
+			c.BIC = profile.BIC
+			opts.derived("Creditor "+c.ID, c.IBAN, c.BIC, "profile")
+		}
+		if c.ChargeBearer == "" {
+			c.ChargeBearer = profile.ChargeBearer
+		}
+	}
+	docxml, err := r.ToSEPA(opts.Options)
+	if err != nil {
+		return nil, err
+	}
+	docxml.Transliterate(docxml.Options().Transliterator)
+	return docxml, nil
+}
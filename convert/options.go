@@ -0,0 +1,99 @@
+package convert
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/apsl/sepakit/rfref"
+	"github.com/apsl/sepakit/sepadebit"
+	"github.com/apsl/sepakit/tabular"
+)
+
+//Options configures the conversion from AEB 19 to SEPA XML
+type Options struct {
+	sepadebit.Options
+	//RFReference derives structured RF creditor references for the transactions
+	RFReference RFSource
+	//Parties sets ultimate creditors and debtors by creditor ID
+	Parties PartiesMap
+	//Address selects unstructured or structured creditor addresses
+	Address AddressMode
+	//Profile sets the bank specific values. Defaults to DefaultProfile
+	Profile *Profile
+	//MandateRule builds the mandate IDs of CSB 19 debits. Defaults to
+	//DefaultMandateRule
+	MandateRule MandateRule
+	//Mapping is the column mapping of CSV and XLSX inputs read by ReadContext
+	Mapping *tabular.Mapping
+	//Warn receives the non-fatal issues of the conversion, such as unknown
+	//purpose codes. They are logged when nil
+	Warn func(msg string)
+	//Derived receives the BICs not given by the input, such as the creditor
+	//agent of the profile. The Converter reports them with the IBAN masked
+	Derived func(d DerivedBIC)
+}
+
+//DerivedBIC is a BIC set by the conversion
+type DerivedBIC struct {
+	//Party is the creditor or the transaction
+	Party string `json:"party"`
+	IBAN  string `json:"iban"`
+	BIC   string `json:"bic"`
+	//Source is "profile" or "entity", for the bank entity of a CCC
+	Source string `json:"source"`
+}
+
+//warnf reports a non-fatal issue through Warn
+func (o Options) warnf(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	if o.Warn == nil {
+		log.Println(msg)
+		return
+	}
+	o.Warn(msg)
+}
+
+//derived reports a BIC through Derived
+func (o Options) derived(party, iban, bic, source string) {
+	if o.Derived != nil && bic != "" {
+		o.Derived(DerivedBIC{Party: party, IBAN: iban, BIC: bic, Source: source})
+	}
+}
+
+//RFSource is the AEB field RF creditor references are derived from
+type RFSource int
+
+const (
+	RFNone        RFSource = iota // unstructured remittance information only
+	RFFromConcept                 // the concept, when it is already an RF reference
+	RFFromID                      // the transaction ID (EndToEndId)
+)
+
+//ParseRFSource returns the RFSource for "none", "concept" or "id"
+func ParseRFSource(name string) (RFSource, error) {
+	switch name {
+	case "", "none":
+		return RFNone, nil
+	case "concept":
+		return RFFromConcept, nil
+	case "id":
+		return RFFromID, nil
+	}
+	return RFNone, fmt.Errorf("Unknown RF reference source %s", name)
+}
+
+//rfReference returns the RF creditor reference for the transaction id and
+//concept, or "" when there is none. Concepts are used only when they already
+//are valid RF references, otherwise they stay unstructured. References are
+//generated from whole IDs: an ID that is not a valid reference is an error
+func rfReference(id, concept string, source RFSource) (string, error) {
+	switch source {
+	case RFFromConcept:
+		if rfref.IsValid(concept) {
+			return concept, nil
+		}
+	case RFFromID:
+		return rfref.Generate(id)
+	}
+	return "", nil
+}
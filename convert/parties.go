@@ -0,0 +1,59 @@
+package convert
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/apsl/sepakit/sepadebit"
+)
+
+//CreditorParties are the ultimate parties of the transactions of a creditor
+type CreditorParties struct {
+	UltimateCreditor *sepadebit.Party `json:"ultimateCreditor,omitempty"`
+	//UltimateDebtors by mandate ID or debtor ID (NIF)
+	UltimateDebtors map[string]*sepadebit.Party `json:"ultimateDebtors,omitempty"`
+}
+
+//PartiesMap holds the ultimate parties by creditor ID. As a JSON file:
+//
+//	{
+//	  "ES08000E77846772": {
+//	    "ultimateCreditor": {"name": "FILIAL, S.L.", "orgId": {"id": "B12345678"}},
+//	    "ultimateDebtors": {"12345678Z": {"name": "HIJO DEL DEUDOR"}}
+//	  }
+//	}
+type PartiesMap map[string]*CreditorParties
+
+//LoadPartiesMap reads a JSON PartiesMap
+func LoadPartiesMap(r io.Reader) (PartiesMap, error) {
+	m := PartiesMap{}
+	if err := json.NewDecoder(r).Decode(&m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+//ultimateCreditor returns a copy of the ultimate creditor of creditorID, if any
+func (m PartiesMap) ultimateCreditor(creditorID string) *sepadebit.Party {
+	if cp := m[creditorID]; cp != nil && cp.UltimateCreditor != nil {
+		p := *cp.UltimateCreditor
+		return &p
+	}
+	return nil
+}
+
+//ultimateDebtor returns a copy of the ultimate debtor for a mandate or debtor
+//of creditorID, if any
+func (m PartiesMap) ultimateDebtor(creditorID string, keys ...string) *sepadebit.Party {
+	cp := m[creditorID]
+	if cp == nil {
+		return nil
+	}
+	for _, k := range keys {
+		if party := cp.UltimateDebtors[k]; party != nil && k != "" {
+			p := *party
+			return &p
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package convert
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+const partiesJSON = `{
+  "ES08000E77846772": {
+    "ultimateCreditor": {"name": "FILIAL, S.L.", "orgId": {"id": "B12345678"}},
+    "ultimateDebtors": {
+      "885c81c2d215a71b195847b9d86cf2c1": {"name": "HIJO DEL DEUDOR", "prvtId": {"id": "12345678Z"}},
+      "00000000T": {"name": "OTRO DEUDOR"}
+    }
+  }
+}`
+
+func TestLoadPartiesMap(t *testing.T) {
+	m, err := LoadPartiesMap(strings.NewReader(partiesJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	uc := m.ultimateCreditor("ES08000E77846772")
+	if uc == nil || uc.Name != "FILIAL, S.L." || uc.OrgID == nil || uc.OrgID.ID != "B12345678" {
+		t.Errorf("Unexpected ultimate creditor %+v", uc)
+	}
+	uc.Name = "CHANGED"
+	if m.ultimateCreditor("ES08000E77846772").Name != "FILIAL, S.L." {
+		t.Error("Ultimate creditor returned by reference")
+	}
+	if ud := m.ultimateDebtor("ES08000E77846772", "UNKNOWN", "00000000T"); ud == nil || ud.Name != "OTRO DEUDOR" {
+		t.Errorf("Expected the ultimate debtor by NIF, got %+v", ud)
+	}
+	if ud := m.ultimateDebtor("ES08000E77846772", "", ""); ud != nil {
+		t.Errorf("Expected no ultimate debtor for empty keys, got %+v", ud)
+	}
+	if m.ultimateCreditor("ES00000X0000000X") != nil || m.ultimateDebtor("ES00000X0000000X", "00000000T") != nil {
+		t.Error("Expected no parties for an unknown creditor")
+	}
+	if _, err := LoadPartiesMap(strings.NewReader(`{"ES08000E77846772": []}`)); err == nil {
+		t.Error("Expected error for a malformed parties file")
+	}
+}
+
+func TestUltimateParties(t *testing.T) {
+	m, err := LoadPartiesMap(strings.NewReader(partiesJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open("../input-aeb1914.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	doc, err := Latin1DebitTxtToXMLDocWithOptions(f, Options{Parties: m})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := doc.Payments[0]
+	if uc := p.Creditor.UltimateCreditor; uc == nil || uc.Name != "FILIAL, S.L." {
+		t.Errorf("Expected the ultimate creditor on the payment, got %+v", uc)
+	}
+	if ud := p.Transactions[0].UltimateDebtor; ud == nil || ud.Name != "HIJO DEL DEUDOR" || ud.PrvtID == nil || ud.PrvtID.ID != "12345678Z" {
+		t.Errorf("Expected the ultimate debtor by mandate ID, got %+v", ud)
+	}
+	out, err := doc.WriteBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		"<UltmtCdtr><Nm>FILIAL, S.L.</Nm><Id><OrgId><Othr><Id>B12345678</Id></Othr></OrgId></Id></UltmtCdtr>",
+		"<UltmtDbtr><Nm>HIJO DEL DEUDOR</Nm><Id><PrvtId><Othr><Id>12345678Z</Id></Othr></PrvtId></Id></UltmtDbtr>",
+	} {
+		if !strings.Contains(strings.Join(strings.Fields(string(out)), ""), strings.Replace(s, " ", "", -1)) {
+			t.Errorf("Expected %s in the XML", s)
+		}
+	}
+}
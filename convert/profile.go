@@ -0,0 +1,55 @@
+package convert
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/apsl/sepakit/aeb19"
+)
+
+//Profile holds the bank specific values of the conversion, which AEB 19
+//files do not carry
+type Profile struct {
+	Name string
+	//BIC of the creditor agent
+	BIC          string
+	ChargeBearer string
+}
+
+//Profiles are the known bank profiles by name
+var Profiles = map[string]*Profile{
+	"caixabank": {
+		Name:         "caixabank",
+		BIC:          "CAIXESBBXXX",
+		ChargeBearer: "SLEV",
+	},
+}
+
+//DefaultProfile is used when Options.Profile is not set
+var DefaultProfile = Profiles["caixabank"]
+
+//ParseProfile returns the Profile named name, or DefaultProfile for ""
+func ParseProfile(name string) (*Profile, error) {
+	if name == "" {
+		return DefaultProfile, nil
+	}
+	p, ok := Profiles[name]
+	if !ok {
+		var names []string
+		for n := range Profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("Unknown profile %s, expected one of %s", name, strings.Join(names, ", "))
+	}
+	return p, nil
+}
+
+//localInstrument returns the SEPA scheme of the AEB 19 variant
+func localInstrument(v aeb19.Variant) string {
+	if v.IsB2B() {
+		return "B2B"
+	}
+	return "CORE"
+}
@@ -0,0 +1,132 @@
+package convert
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/apsl/sepakit/sepadebit"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+)
+
+//ReadDoc reads either a pain.008 XML document, a JSON remittance or an
+//ISO-8859-1 AEB 19 or CSB 19 TXT document, detected by its first characters,
+//as a SEPA Document
+func ReadDoc(in io.Reader) (*sepadebit.Document, error) {
+	return ReadDocWithOptions(in, Options{})
+}
+
+//ReadDocWithOptions is ReadDoc converting the AEB 19, CSB 19 and JSON inputs
+//with opts
+func ReadDocWithOptions(in io.Reader, opts Options) (*sepadebit.Document, error) {
+	return ReadContext(context.Background(), in, "", opts)
+}
+
+//Formats are the input formats of ReadContext
+var Formats = []string{"aeb19", "csb19", "csv", "xlsx", "json", "xml"}
+
+//ReadContext converts in, in one of Formats or detected as in ReadDoc when
+//format is empty, with opts. It stops with the context error when ctx is
+//done. ReadContext may run concurrently as long as each call has its own
+//Transliterator, which records the alterations of its conversion
+func ReadContext(ctx context.Context, in io.Reader, format string, opts Options) (*sepadebit.Document, error) {
+	doc, _, err := read(ctx, in, format, charmap.ISO8859_1, opts)
+	return doc, err
+}
+
+//read converts in as ReadContext, decoding the AEB 19 and CSB 19 text with
+//charset, and returns the format read
+func read(ctx context.Context, in io.Reader, format string, charset encoding.Encoding, opts Options) (*sepadebit.Document, string, error) {
+	br := bufio.NewReader(&contextReader{ctx: ctx, r: in})
+	if format == "" {
+		format = detect(br)
+	}
+	var doc *sepadebit.Document
+	var err error
+	switch format {
+	case "aeb19":
+		doc, err = debitTxtToXMLDoc(charset.NewDecoder().Reader(br), opts)
+	case "csb19":
+		doc, err = csb19TxtToXMLDoc(charset.NewDecoder().Reader(br), opts)
+	case "csv", "xlsx":
+		if opts.Mapping == nil {
+			return nil, format, fmt.Errorf("%s input needs a mapping", format)
+		}
+		doc, err = TabularToXMLDocWithOptions(br, opts.Mapping, opts)
+	case "json":
+		doc, err = JSONToXMLDocWithOptions(br, opts)
+	case "xml":
+		doc, err = sepadebit.ReadDocument(br)
+	default:
+		return nil, format, fmt.Errorf("Unknown input format %s", format)
+	}
+	if ctx.Err() != nil {
+		return nil, format, ctx.Err()
+	}
+	return doc, format, err
+}
+
+//detect returns the format of an input from its first characters: XML, JSON,
+//CSB 19 or else AEB 19
+func detect(br *bufio.Reader) string {
+	switch firstNonBlank(br) {
+	case '<':
+		return "xml"
+	case '{':
+		return "json"
+	}
+	if isCSB19(br) {
+		return "csb19"
+	}
+	return "aeb19"
+}
+
+//contextReader fails with the context error once its context is done
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+//Merge reads every input with ReadDoc and merges them in a single Document
+func Merge(ins ...io.Reader) (*sepadebit.Document, error) {
+	var docs []*sepadebit.Document
+	for _, in := range ins {
+		doc, err := ReadDoc(in)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	return sepadebit.Merge(docs...)
+}
+
+//firstNonBlank peeks the reader for its first non blank character, 0 if none
+func firstNonBlank(br *bufio.Reader) byte {
+	for i := 1; ; i++ {
+		b, err := br.Peek(i)
+		if err != nil {
+			return 0
+		}
+		switch c := b[i-1]; c {
+		case ' ', '\t', '\r', '\n', 0xEF, 0xBB, 0xBF: // blanks and UTF-8 BOM
+			continue
+		default:
+			return c
+		}
+	}
+}
+
+//isCSB19 peeks the reader looking for the CSB 19 presenter register code
+func isCSB19(br *bufio.Reader) bool {
+	b, err := br.Peek(4)
+	return err == nil && string(b) == "5180"
+}
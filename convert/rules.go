@@ -0,0 +1,254 @@
+package convert
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/apsl/sepakit/sepadebit"
+)
+
+//Rules is a TransactionHook configured by a JSON rules file, for the client
+//specific mappings that need no code:
+//
+//	{"rules": [
+//	  {"name": "no zero debits", "when": {"amount": "0\\.00"}, "skip": true},
+//	  {"set": {"mandateId": "{creditorId}-{id}"}},
+//	  {"when": {"creditorId": "ES08000E77846772"},
+//	   "replace": [{"field": "concept", "pattern": "^FACT ", "with": "FACTURA "}]}
+//	]}
+//
+//Every rule whose conditions hold applies to the transaction, in order. The
+//conditions in "when" are regular expressions matching the whole field. A
+//rule may skip the transaction, "set" fields to templates with {field}
+//placeholders, evaluated before any of them is set, and "replace" the
+//matches of a regular expression in a field, with $1 for its groups. The
+//fields are in RuleFields
+type Rules struct {
+	Rules []*Rule `json:"rules"`
+}
+
+//Rule is a rule of Rules
+type Rule struct {
+	Name    string            `json:"name,omitempty"`
+	When    map[string]string `json:"when,omitempty"`
+	Skip    bool              `json:"skip,omitempty"`
+	Set     map[string]string `json:"set,omitempty"`
+	Replace []Replacement     `json:"replace,omitempty"`
+
+	when map[string]*regexp.Regexp
+}
+
+//Replacement replaces the matches of Pattern in Field with With
+type Replacement struct {
+	Field   string `json:"field"`
+	Pattern string `json:"pattern"`
+	With    string `json:"with"`
+
+	re *regexp.Regexp
+}
+
+//RuleFields are the transaction fields of the rules, true for those that can
+//be set or replaced. The others are from the payment
+var RuleFields = map[string]bool{
+	"id":             true, // EndToEndId
+	"mandateId":      true,
+	"mandateDate":    true, // YYYY-MM-DD
+	"amount":         true, // 123.45, positive with 2 decimals
+	"debtorName":     true,
+	"debtorIban":     true,
+	"debtorBic":      true,
+	"concept":        true, // unstructured remittance information, not with an RF reference
+	"purpose":        true, // ISO 20022 purpose code
+	"creditorId":     false,
+	"creditorName":   false,
+	"collectionDate": false,
+	"sequenceType":   false,
+}
+
+var placeholder = regexp.MustCompile(`\{(\w+)\}`)
+
+//ruleAmount is the format of the amounts set by the rules
+var ruleAmount = regexp.MustCompile(`^[0-9]+\.[0-9]{2}$`)
+
+//LoadRules reads and checks a JSON rules file
+func LoadRules(r io.Reader) (*Rules, error) {
+	rules := &Rules{}
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(rules); err != nil {
+		return nil, err
+	}
+	for i, rule := range rules.Rules {
+		if err := rule.compile(); err != nil {
+			return nil, fmt.Errorf("%s: %s", rule.label(i), err)
+		}
+	}
+	return rules, nil
+}
+
+func (r *Rule) label(i int) string {
+	if r.Name != "" {
+		return fmt.Sprintf("Rule %d (%s)", i+1, r.Name)
+	}
+	return fmt.Sprintf("Rule %d", i+1)
+}
+
+//compile checks the fields and compiles the regular expressions of the rule
+func (r *Rule) compile() error {
+	r.when = map[string]*regexp.Regexp{}
+	for field, expr := range r.When {
+		if _, ok := RuleFields[field]; !ok {
+			return fmt.Errorf("Unknown field %s", field)
+		}
+		re, err := regexp.Compile("^(?:" + expr + ")$")
+		if err != nil {
+			return err
+		}
+		r.when[field] = re
+	}
+	for field, tmpl := range r.Set {
+		if !RuleFields[field] {
+			return fmt.Errorf("Field %s can not be set", field)
+		}
+		for _, m := range placeholder.FindAllStringSubmatch(tmpl, -1) {
+			if _, ok := RuleFields[m[1]]; !ok {
+				return fmt.Errorf("Unknown field %s in %q", m[1], tmpl)
+			}
+		}
+	}
+	for i := range r.Replace {
+		rep := &r.Replace[i]
+		if !RuleFields[rep.Field] {
+			return fmt.Errorf("Field %s can not be replaced", rep.Field)
+		}
+		re, err := regexp.Compile(rep.Pattern)
+		if err != nil {
+			return err
+		}
+		rep.re = re
+	}
+	if !r.Skip && len(r.Set) == 0 && len(r.Replace) == 0 {
+		return fmt.Errorf("Rule does nothing")
+	}
+	return nil
+}
+
+//BeforeTransaction applies the rules to a transaction
+func (rules *Rules) BeforeTransaction(p *sepadebit.Payment, t sepadebit.Transaction) ([]sepadebit.Transaction, error) {
+	for i, r := range rules.Rules {
+		fields := ruleFields(p, &t)
+		if !r.matches(fields) {
+			continue
+		}
+		if r.Skip {
+			return nil, nil
+		}
+		//sorted, for the same errors on every run
+		var names []string
+		for field := range r.Set {
+			names = append(names, field)
+		}
+		sort.Strings(names)
+		for _, field := range names {
+			value := placeholder.ReplaceAllStringFunc(r.Set[field], func(m string) string {
+				return fields[m[1:len(m)-1]]
+			})
+			if err := setRuleField(&t, field, value); err != nil {
+				return nil, fmt.Errorf("%s: transaction %s: %s", r.label(i), t.ID, err)
+			}
+		}
+		for _, rep := range r.Replace {
+			value := rep.re.ReplaceAllString(ruleFields(p, &t)[rep.Field], rep.With)
+			if err := setRuleField(&t, rep.Field, value); err != nil {
+				return nil, fmt.Errorf("%s: transaction %s: %s", r.label(i), t.ID, err)
+			}
+		}
+	}
+	return []sepadebit.Transaction{t}, nil
+}
+
+func (r *Rule) matches(fields map[string]string) bool {
+	for field, re := range r.when {
+		if !re.MatchString(fields[field]) {
+			return false
+		}
+	}
+	return true
+}
+
+//ruleFields returns the RuleFields of a transaction
+func ruleFields(p *sepadebit.Payment, t *sepadebit.Transaction) map[string]string {
+	fields := map[string]string{
+		"id":             t.ID,
+		"mandateId":      t.Mandate.ID,
+		"mandateDate":    t.Mandate.SignatureDate.String(),
+		"amount":         t.Amount.Amount,
+		"debtorName":     t.Debtor.Name,
+		"debtorIban":     t.Debtor.IBAN,
+		"debtorBic":      t.Debtor.BIC,
+		"concept":        strings.Join(t.RemittanceInfo, " "),
+		"collectionDate": p.RequestedCollectionDate,
+		"sequenceType":   p.SequenceType,
+	}
+	if t.Purpose != nil {
+		fields["purpose"] = t.Purpose.Code
+	}
+	if p.Creditor != nil {
+		fields["creditorId"] = p.Creditor.ID
+		fields["creditorName"] = p.Creditor.Name
+	}
+	return fields
+}
+
+//setRuleField sets a field of RuleFields
+func setRuleField(t *sepadebit.Transaction, field, value string) error {
+	switch field {
+	case "id":
+		t.ID = value
+	case "mandateId":
+		t.Mandate.ID = value
+	case "mandateDate":
+		d, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			return fmt.Errorf("Invalid mandate date %q", value)
+		}
+		t.Mandate.SignatureDate = sepadebit.Date(d)
+	case "amount":
+		if !ruleAmount.MatchString(value) {
+			return fmt.Errorf("Invalid amount %q, expected a positive decimal with 2 places such as 123.45", value)
+		}
+		cents, err := sepadebit.ParseAmount(value)
+		if err != nil {
+			return err
+		}
+		if cents <= 0 {
+			return fmt.Errorf("Invalid amount %q, expected a positive amount", value)
+		}
+		t.Amount.Amount = sepadebit.FormatAmount(cents)
+	case "debtorName":
+		t.Debtor.Name = value
+	case "debtorIban":
+		t.Debtor.IBAN = strings.Replace(value, " ", "", -1)
+	case "debtorBic":
+		t.Debtor.BIC = value
+	case "concept":
+		if value != "" && t.CreditorReference != nil {
+			return fmt.Errorf("Cannot set concept %q with the RF creditor reference %s, only one of them is allowed", value, t.CreditorReference.Ref)
+		}
+		t.RemittanceInfo = nil
+		if value != "" {
+			t.RemittanceInfo = []string{value}
+		}
+	case "purpose":
+		t.Purpose = nil
+		if value != "" {
+			return t.SetPurpose(value)
+		}
+	}
+	return nil
+}
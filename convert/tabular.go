@@ -0,0 +1,106 @@
+package convert
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/apsl/sepakit/sepadebit"
+	"github.com/apsl/sepakit/tabular"
+)
+
+//TabularToXMLDocWithOptions creates XML SEPA Document from a CSV or XLSX
+//spreadsheet, with its columns mapped by m
+func TabularToXMLDocWithOptions(in io.Reader, m *tabular.Mapping, opts Options) (*sepadebit.Document, error) {
+	doctab, err := tabular.Parse(in, m)
+	if err != nil {
+		return nil, err
+	}
+	return TabularDocToXMLWithOptions(doctab, opts)
+}
+
+//TabularDocToXMLWithOptions creates XML SEPA Document from spreadsheet debits.
+//Debits are grouped in a payment per collection date and sequence type. The
+//EndToEndId is the ID column, or the mandate ID and the collection date
+func TabularDocToXMLWithOptions(doctab *tabular.Document, opts Options) (*sepadebit.Document, error) {
+	profile := opts.Profile
+	if profile == nil {
+		profile = DefaultProfile
+	}
+	docxml := sepadebit.NewDocumentWithOptions(opts.Options)
+	c := doctab.Creditor
+	docxml.SetInitiatingParty(c.Name, c.ID)
+	bic := c.BIC
+	if bic == "" {
+		bic = profile.BIC
+		opts.derived("Creditor "+c.ID, c.IBAN, bic, "profile")
+	}
+
+	type paymentKey struct{ date, sequence string }
+	payments := map[paymentKey]*sepadebit.Payment{}
+	for _, d := range doctab.Debits {
+		date := d.CollectionDate.Format("20060102")
+		key := paymentKey{date, d.SequenceType}
+		p, ok := payments[key]
+		if !ok {
+			p = &sepadebit.Payment{
+				Creditor: &sepadebit.Creditor{
+					ID:               c.ID,
+					Name:             c.Name,
+					IBAN:             c.IBAN,
+					SchemeName:       "SEPA",
+					BIC:              bic,
+					ChargeBearer:     profile.ChargeBearer,
+					UltimateCreditor: opts.Parties.ultimateCreditor(c.ID),
+				},
+				RequestedCollectionDate: d.CollectionDate.Format("2006-01-02"),
+				ID:                      docxml.NewID("rem" + date),
+				Method:                  "DD",
+				ServiceLevel:            "SEPA",
+				LocalInstrument:         "CORE",
+				SequenceType:            d.SequenceType,
+			}
+			payments[key] = p
+			docxml.AddPayment(p)
+		}
+		id := d.ID
+		if id == "" {
+			id = d.MandateID + "-" + date
+		}
+		t := sepadebit.Transaction{
+			ID: id,
+			Mandate: sepadebit.MandateInfo{
+				ID:            d.MandateID,
+				SignatureDate: sepadebit.Date(d.MandateDate),
+			},
+			Debtor: sepadebit.Debtor{
+				IBAN: d.IBAN,
+				BIC:  d.BIC,
+				Name: d.Name,
+			},
+			Amount: sepadebit.TAmount{
+				Amount:   sepadebit.FormatAmount(d.Amount),
+				Currency: "EUR",
+			},
+		}
+		t.UltimateDebtor = opts.Parties.ultimateDebtor(c.ID, d.MandateID)
+		if d.Concept != "" {
+			t.RemittanceInfo = []string{d.Concept}
+		}
+		if err := t.SetPurpose(d.Purpose); err != nil {
+			opts.warnf("Row %d: %s", d.Row, err)
+		}
+		rf, err := rfReference(id, d.Concept, opts.RFReference)
+		if err == nil && rf != "" {
+			err = t.SetCreditorReference(rf)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Row %d: %s", d.Row, err)
+		}
+		p.Transactions = append(p.Transactions, t)
+	}
+	if err := docxml.UpdateTotals(); err != nil {
+		return nil, err
+	}
+	docxml.Transliterate(docxml.Options().Transliterator)
+	return docxml, nil
+}
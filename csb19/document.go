@@ -0,0 +1,75 @@
+//Package csb19 parses the pre-SEPA Cuaderno 19 (CSB 19) direct debit text
+//format, with 162 character records and CCC accounts
+package csb19
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/apsl/sepakit/redact"
+)
+
+//Presenter is the presenter header (5180)
+type Presenter struct {
+	NIF          string
+	Suffix       string
+	CreationDate time.Time
+	Name         string
+	Entity       string
+	Office       string
+}
+
+//Orderer is the orderer (creditor) header (5380)
+type Orderer struct {
+	NIF          string
+	Suffix       string
+	CreationDate time.Time
+	ChargeDate   time.Time
+	Name         string
+	//Account is the CCC to be credited
+	Account   string
+	Procedure string
+}
+
+//Debit is an individual debit (5680) with its optional concept records (5681 to 5685)
+type Debit struct {
+	//Reference identifies the debtor for the orderer
+	Reference string
+	Name      string
+	//Account is the CCC to be debited
+	Account           string
+	Amount            float64
+	ReturnCode        string
+	InternalReference string
+	Concepts          []string
+}
+
+type OrdererPayments struct {
+	Orderer            Orderer
+	Debits             []*Debit
+	TotalAmount        float64
+	DebitRegisterCount int
+	TotalRegisterCount int
+}
+
+type Document struct {
+	Presenter          *Presenter
+	OrdererPayments    []*OrdererPayments
+	TotalAmount        float64
+	DebitRegisterCount int
+	TotalRegisterCount int
+}
+
+//NewDocument returns an *csb19.Document
+func NewDocument() *Document {
+	return &Document{}
+}
+
+func (doc *Document) String() string {
+	return fmt.Sprintf("Document Presenter: %s Totals: amount=%f, debits=%d, registers=%d", doc.Presenter.Name, doc.TotalAmount, doc.DebitRegisterCount, doc.TotalRegisterCount)
+}
+
+//String returns the debit with the debtor personal data redacted
+func (d *Debit) String() string {
+	return fmt.Sprintf("Debit Amount: %.2f, Reference: %s, Debtor: %s, Account: %s", d.Amount, d.Reference, redact.Name(d.Name), redact.IBAN(d.Account))
+}
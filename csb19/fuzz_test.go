@@ -0,0 +1,31 @@
+package csb19
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+//FuzzParse checks the parser returns an error rather than panic on any
+//input. Run it with
+//
+//	go test -fuzz FuzzParse ./csb19
+func FuzzParse(f *testing.F) {
+	sample, err := ioutil.ReadFile("../input-csb19.txt")
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(sample)
+	f.Add([]byte(strings.Replace(string(sample), "\r\n", "\n", -1)))
+	f.Add(append([]byte("\xef\xbb\xbf"), sample...))
+	f.Add(sample[:200])
+	f.Add([]byte("\n\n5\n5680"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		p := NewParser()
+		p.Warn = func(string) {}
+		p.Parse(charmap.ISO8859_1.NewDecoder().Reader(bytes.NewReader(data)))
+	})
+}
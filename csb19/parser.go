@@ -0,0 +1,324 @@
+package csb19
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//RecordLength is the length of CSB 19 records. Shorter lines are padded,
+//as many programs strip the trailing blanks
+const RecordLength = 162
+
+//Parser represents the main Parser object
+type Parser struct {
+	doc            *Document
+	currentOrderer *OrdererPayments
+	currentDebit   *Debit
+	//Warn receives the non-fatal issues found while parsing, such as
+	//invalid dates. They are logged when nil
+	Warn func(msg string)
+}
+
+//warnf reports a non-fatal issue through Warn
+func (p *Parser) warnf(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	if p.Warn == nil {
+		log.Println(msg)
+		return
+	}
+	p.Warn(msg)
+}
+
+//NewParser returns a CSB 19 Parser
+func NewParser() *Parser {
+	return &Parser{}
+}
+
+//Parse takes a io.Reader with CSB 19 contents in iso-8859 encoding. Blank
+//lines, trailing CR and a byte order mark are ignored, and errors tell the
+//line
+func (p *Parser) Parse(r io.Reader) (doc *Document, err error) {
+	p.doc = NewDocument()
+	p.currentOrderer = nil
+	p.currentDebit = nil
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if n == 1 {
+			text = strings.TrimPrefix(strings.TrimPrefix(text, "\ufeff"), "\u00ef\u00bb\u00bf")
+		}
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		if err = p.parseRecord([]rune(text)); err != nil {
+			return nil, fmt.Errorf("Line %d: %s", n, err)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return
+	}
+	if p.doc.Presenter == nil {
+		return nil, fmt.Errorf("Missing presenter register (5180)")
+	}
+	doc = p.doc
+	return
+}
+
+//parseRecord pads a record to RecordLength and parses it
+func (p *Parser) parseRecord(line []rune) (err error) {
+	if len(line) > RecordLength {
+		return fmt.Errorf("Register %s longer than %d characters. Check the input encoding", string(line[:4]), RecordLength)
+	}
+	line = append(line, []rune(strings.Repeat(" ", RecordLength-len(line)))...)
+	switch regCode := string(line[:4]); regCode {
+	case "5180":
+		err = p.parsePresenter(line)
+	case "5380":
+		err = p.parseOrderer(line)
+	case "5680":
+		err = p.parseDebit(line)
+	case "5681", "5682", "5683", "5684", "5685":
+		err = p.parseConcepts(line)
+	case "5686":
+		//debtor address, not used in SEPA debits
+		err = p.parseOptional(line)
+	case "5880":
+		err = p.parseOrdererTotals(line)
+	case "5980":
+		err = p.parseTotals(line)
+	default:
+		err = fmt.Errorf("Unknown register %q", regCode)
+	}
+	return
+}
+
+func (p *Parser) countRegister() {
+	p.doc.TotalRegisterCount++
+	if p.currentOrderer != nil {
+		p.currentOrderer.TotalRegisterCount++
+	}
+}
+
+func (p *Parser) countDebitRegister() {
+	p.doc.DebitRegisterCount++
+	if p.currentOrderer != nil {
+		p.currentOrderer.DebitRegisterCount++
+	}
+}
+
+func (p *Parser) addDebitAmount(amount float64) {
+	p.doc.TotalAmount += amount
+	if p.currentOrderer != nil {
+		p.currentOrderer.TotalAmount += amount
+	}
+}
+
+func (p *Parser) parsePresenter(line []rune) (err error) {
+	pr := &Presenter{}
+	pr.NIF = getString(line[4:13])
+	pr.Suffix = getString(line[13:16])
+	pr.CreationDate, err = getDate(line[16:22])
+	if err != nil {
+		p.warnf("Error parsing file creation date: %s", err)
+	}
+	pr.Name = getString(line[28:68])
+	pr.Entity = getString(line[88:92])
+	pr.Office = getString(line[92:96])
+	p.doc.Presenter = pr
+	p.countRegister()
+	return nil
+}
+
+func (p *Parser) parseOrderer(line []rune) (err error) {
+	if p.doc.Presenter == nil {
+		return fmt.Errorf("Got orderer register (5380) before presenter register (5180)")
+	}
+	op := &OrdererPayments{}
+	o := &op.Orderer
+	o.NIF = getString(line[4:13])
+	o.Suffix = getString(line[13:16])
+	o.CreationDate, err = getDate(line[16:22])
+	if err != nil {
+		p.warnf("Error parsing orderer creation date: %s", err)
+	}
+	o.ChargeDate, err = getDate(line[22:28])
+	if err != nil {
+		return fmt.Errorf("Error parsing charge date: %s", err)
+	}
+	o.Name = getString(line[28:68])
+	o.Account = getString(line[68:88])
+	o.Procedure = getString(line[96:98])
+	p.doc.OrdererPayments = append(p.doc.OrdererPayments, op)
+	p.currentOrderer = op
+	p.currentDebit = nil
+	p.countRegister()
+	return
+}
+
+func (p *Parser) parseDebit(line []rune) (err error) {
+	if p.currentOrderer == nil {
+		return fmt.Errorf("Parser: got debit register (5680) with no current orderer")
+	}
+	if err = p.checkOrderer(line); err != nil {
+		return
+	}
+	d := &Debit{}
+	d.Reference = getString(line[16:28])
+	d.Name = getString(line[28:68])
+	d.Account = getString(line[68:88])
+	d.Amount, err = getMoney(line[88:98])
+	if err != nil {
+		return fmt.Errorf("Error parsing debit amount: %s", err)
+	}
+	d.ReturnCode = getString(line[98:104])
+	d.InternalReference = getString(line[104:114])
+	if concept := getString(line[114:154]); concept != "" {
+		d.Concepts = append(d.Concepts, concept)
+	}
+	p.currentOrderer.Debits = append(p.currentOrderer.Debits, d)
+	p.currentDebit = d
+	p.countDebitRegister()
+	p.countRegister()
+	p.addDebitAmount(d.Amount)
+	return
+}
+
+//parseConcepts reads the three additional concepts of the optional records
+//5681 to 5685
+func (p *Parser) parseConcepts(line []rune) (err error) {
+	if err = p.parseOptional(line); err != nil {
+		return
+	}
+	for i := 28; i < 148; i += 40 {
+		if concept := getString(line[i : i+40]); concept != "" {
+			p.currentDebit.Concepts = append(p.currentDebit.Concepts, concept)
+		}
+	}
+	return
+}
+
+//parseOptional checks an optional record follows the debit it belongs to
+func (p *Parser) parseOptional(line []rune) (err error) {
+	if p.currentDebit == nil {
+		return fmt.Errorf("Parser: got optional register %s with no current debit", string(line[:4]))
+	}
+	if err = p.checkOrderer(line); err != nil {
+		return
+	}
+	if reference := getString(line[16:28]); reference != p.currentDebit.Reference {
+		return fmt.Errorf("Optional register %s reference %s differs from debit reference %s", string(line[:4]), reference, p.currentDebit.Reference)
+	}
+	p.countRegister()
+	return
+}
+
+//checkOrderer checks the NIF and suffix of a register against the current orderer
+func (p *Parser) checkOrderer(line []rune) error {
+	o := p.currentOrderer.Orderer
+	if nif, suffix := getString(line[4:13]), getString(line[13:16]); nif != o.NIF || suffix != o.Suffix {
+		return fmt.Errorf("Register %s of orderer %s%s, expected %s%s", string(line[:4]), nif, suffix, o.NIF, o.Suffix)
+	}
+	return nil
+}
+
+func (p *Parser) parseOrdererTotals(line []rune) (err error) {
+	if p.currentOrderer == nil {
+		return fmt.Errorf("Received orderer totals line (5880) with no current orderer")
+	}
+	if err = p.checkOrderer(line); err != nil {
+		return
+	}
+	totalAmount, err := getMoney(line[88:98])
+	if err != nil {
+		return
+	}
+	debitRegisterCount, err := getInt(line[104:114])
+	if err != nil {
+		return
+	}
+	totalRegisterCount, err := getInt(line[114:124])
+	if err != nil {
+		return
+	}
+	if math.Abs(totalAmount-p.currentOrderer.TotalAmount) > 0.01 {
+		return fmt.Errorf("Calculated amount = %f diferent from parsed amount = %f", p.currentOrderer.TotalAmount, totalAmount)
+	}
+	p.currentOrderer.TotalAmount = totalAmount
+	if debitRegisterCount != p.currentOrderer.DebitRegisterCount {
+		return fmt.Errorf("Debits on totals line = %d. Parsed debits = %d", debitRegisterCount, p.currentOrderer.DebitRegisterCount)
+	}
+	p.countRegister()
+	if p.currentOrderer.TotalRegisterCount != totalRegisterCount {
+		return fmt.Errorf("Parsed number of register differs: %d - %d", p.currentOrderer.TotalRegisterCount, totalRegisterCount)
+	}
+	p.currentOrderer = nil
+	p.currentDebit = nil
+	return
+}
+
+func (p *Parser) parseTotals(line []rune) (err error) {
+	ordererCount, err := getInt(line[68:72])
+	if err != nil {
+		return
+	}
+	totalAmount, err := getMoney(line[88:98])
+	if err != nil {
+		return
+	}
+	debitRegisterCount, err := getInt(line[104:114])
+	if err != nil {
+		return
+	}
+	totalRegisterCount, err := getInt(line[114:124])
+	if err != nil {
+		return
+	}
+	if ordererCount != len(p.doc.OrdererPayments) {
+		return fmt.Errorf("Orderers on totals line = %d. Parsed orderers = %d", ordererCount, len(p.doc.OrdererPayments))
+	}
+	if math.Abs(totalAmount-p.doc.TotalAmount) > 0.01 {
+		return fmt.Errorf("Calculated amount = %f diferent from parsed amount = %f", p.doc.TotalAmount, totalAmount)
+	}
+	p.doc.TotalAmount = totalAmount
+	if debitRegisterCount != p.doc.DebitRegisterCount {
+		return fmt.Errorf("Debits on totals line = %d. Parsed debits = %d", debitRegisterCount, p.doc.DebitRegisterCount)
+	}
+	p.countRegister()
+	if totalRegisterCount != p.doc.TotalRegisterCount {
+		return fmt.Errorf("Total doc register (5980) missmatch. Calculated value = %d. Parsed = %d", p.doc.TotalRegisterCount, totalRegisterCount)
+	}
+	return
+}
+
+func getString(rs []rune) string {
+	return strings.TrimSpace(string(rs))
+}
+
+//getDate parses DDMMYY dates
+func getDate(rs []rune) (date time.Time, err error) {
+	date, err = time.Parse("020106", getString(rs))
+	return
+}
+
+func getMoney(rs []rune) (amount float64, err error) {
+	cents, err := getInt(rs)
+	return float64(cents) / 100, err
+}
+
+//getInt reads a number of digits only, rejecting the signs Atoi accepts
+func getInt(rs []rune) (num int, err error) {
+	s := getString(rs)
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return 0, fmt.Errorf("Invalid number %q", s)
+		}
+	}
+	num, err = strconv.Atoi(s)
+	return
+}
@@ -0,0 +1,70 @@
+package csb19
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+func TestParse(t *testing.T) {
+	f, err := os.Open("../input-csb19.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	doc, err := NewParser().Parse(charmap.ISO8859_1.NewDecoder().Reader(f))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Presenter.NIF != "B07123456" || len(doc.OrdererPayments) != 1 {
+		t.Fatalf("Unexpected document %s", doc)
+	}
+	op := doc.OrdererPayments[0]
+	if op.Orderer.ChargeDate.Format("2006-01-02") != "2013-12-20" || op.Orderer.Account != "21000418450200051332" {
+		t.Errorf("Unexpected orderer %+v", op.Orderer)
+	}
+	if len(op.Debits) != 3 || doc.TotalAmount != 1183.95 {
+		t.Fatalf("Expected 3 debits of 1183.95, got %d of %.2f", len(op.Debits), doc.TotalAmount)
+	}
+	d := op.Debits[1]
+	if d.Name != "MUÑOZ LÓPEZ, MARÍA" || d.Amount != 60.50 || d.Reference != "000000000002" {
+		t.Errorf("Unexpected debit %s", d)
+	}
+	if c := op.Debits[0].Concepts; len(c) != 2 || c[1] != "SERVICIO DE MANTENIMIENTO" {
+		t.Errorf("Unexpected concepts %q", c)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	b, err := ioutil.ReadFile("../input-csb19.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := string(b)
+	for name, input := range map[string]string{
+		"wrong total":   strings.Replace(valid, "0000118395", "0000118495", 1),
+		"debit first":   valid[strings.Index(valid, "5680"):],
+		"unknown":       valid + "5780\r\n",
+		"other orderer": strings.Replace(valid, "5680B07123456", "5680B07999999", 1),
+		"empty":         "",
+		"short":         valid + "5\r\n",
+		"garbage":       "\xe2\xc3\xc2\x40\xf1\xf9" + valid,
+		"signed amount": strings.Replace(valid, "0000118395", "-000118395", 1),
+	} {
+		if _, err := NewParser().Parse(strings.NewReader(input)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+	for name, input := range map[string]string{
+		"lf":          strings.Replace(valid, "\r\n", "\n", -1),
+		"bom":         "\ufeff" + valid,
+		"blank lines": strings.Replace(valid, "\r\n", "\r\n\r\n", -1),
+	} {
+		if _, err := NewParser().Parse(strings.NewReader(input)); err != nil {
+			t.Errorf("%s: %s", name, err)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/apsl/sepakit/convert"
+	"github.com/apsl/sepakit/sepadebit"
+)
+
+//runDiff implements "sepakit diff": compares two AEB 19.14 or pain.008 files.
+//Exits with status 1 when differences are found, as diff(1) does
+func runDiff(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	format := fs.String("format", "text", "output format: text or json")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Compares two AEB 19.14 TXT or SEPA XML files\nUsage: %s diff [-format text|json] OLDFILE NEWFILE\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	paths := parseArgs(fs, args)
+	if len(paths) != 2 {
+		fs.Usage()
+		return fmt.Errorf("diff needs two files")
+	}
+	if *format != "text" && *format != "json" {
+		return fmt.Errorf("Unknown output format %s", *format)
+	}
+
+	var docs [2]*sepadebit.Document
+	for i, path := range paths {
+		fin, err := openInput(path)
+		if err != nil {
+			return err
+		}
+		docs[i], err = convert.ReadDoc(fin)
+		fin.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %s", path, err)
+		}
+	}
+
+	d := sepadebit.Diff(docs[0], docs[1])
+	var err error
+	if *format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(d)
+	} else {
+		err = d.WriteText(os.Stdout)
+	}
+	if err != nil {
+		return err
+	}
+	if !d.Empty() {
+		return errDiffers
+	}
+	return nil
+}
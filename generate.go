@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/apsl/sepakit/generate"
+)
+
+//runGenerate implements "sepakit generate": writes a synthetic remittance
+//for load tests and fixtures
+func runGenerate(args []string) error {
+	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+	creditors := fs.Int("creditors", 1, "number of creditors")
+	dates := fs.Int("dates", 1, "number of collection dates of each creditor, a month apart")
+	debits := fs.Int("debits", 100, "total number of debits")
+	seed := fs.Int64("seed", 0, "seed of the generated data, for the same output on every run (random if 0)")
+	format := fs.String("format", "aeb1914", "output format: "+strings.Join(generate.Formats, ", "))
+	start := fs.String("start", generate.DefaultStart.Format("2006-01-02"), "first collection date, YYYY-MM-DD")
+	mappingPath := fs.String("mapping", "", "csv format: file to write the column mapping to (JSON), for sepakit convert -from csv -mapping")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Writes a synthetic remittance with fake but valid names, accounts and identifiers\nUsage: %s generate [options] [OUTFILE]\nDefaults to stdout (-)\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	paths := parseArgs(fs, args)
+	if len(paths) > 1 {
+		fs.Usage()
+		return fmt.Errorf("generate needs at most one file")
+	}
+	outpath := "-"
+	if len(paths) > 0 {
+		outpath = paths[0]
+	}
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	cfg := generate.Config{Creditors: *creditors, Dates: *dates, Debits: *debits, Seed: *seed}
+	var err error
+	if cfg.Start, err = time.Parse("2006-01-02", *start); err != nil {
+		return fmt.Errorf("Invalid start date %s, expected YYYY-MM-DD", *start)
+	}
+	r, err := generate.Remittance(cfg)
+	if err != nil {
+		return err
+	}
+	if *mappingPath != "" {
+		m, err := generate.Mapping(r)
+		if err != nil {
+			return err
+		}
+		data, err := json.MarshalIndent(m, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(*mappingPath, append(data, '\n'), 0644); err != nil {
+			return err
+		}
+	}
+
+	out := os.Stdout
+	if outpath != "-" {
+		if out, err = os.Create(outpath); err != nil {
+			return err
+		}
+		defer out.Close()
+	}
+	w := bufio.NewWriter(out)
+	if err := generate.Write(w, r, *format); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if outpath == "-" {
+		return nil
+	}
+	return out.Close()
+}
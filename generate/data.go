@@ -0,0 +1,108 @@
+package generate
+
+//givenNames, surnames, streets and towns are the pieces of the fake names
+//and addresses. They keep their accents and Ñ, which AEB 19 files carry in
+//ISO-8859-1 and pain.008 files transliterate
+var givenNames = []string{
+	"ANTONIO", "JOSÉ", "MANUEL", "FRANCISCO", "DAVID", "JUAN", "JAVIER",
+	"JOSÉ LUIS", "JESÚS", "MIGUEL ÁNGEL", "RAMÓN", "IÑAKI", "ÁLVARO", "RUBÉN",
+	"ADRIÁN", "SERGIO", "PABLO", "JORDI", "XAVIER", "ANDRÉS", "JOAQUÍN",
+	"MARÍA", "CARMEN", "ANA MARÍA", "LAURA", "ISABEL", "MARÍA PILAR",
+	"CRISTINA", "MARTA", "LUCÍA", "ELENA", "ROCÍO", "INMACULADA", "MONTSERRAT",
+	"BEGOÑA", "NURIA", "ÁNGELA", "SONIA", "RAQUEL", "CONCEPCIÓN", "ASUNCIÓN",
+}
+
+var surnames = []string{
+	"GARCÍA", "RODRÍGUEZ", "GONZÁLEZ", "FERNÁNDEZ", "LÓPEZ", "MARTÍNEZ",
+	"SÁNCHEZ", "PÉREZ", "GÓMEZ", "MARTÍN", "JIMÉNEZ", "RUIZ", "HERNÁNDEZ",
+	"DÍAZ", "MORENO", "MUÑOZ", "ÁLVAREZ", "ROMERO", "ALONSO", "GUTIÉRREZ",
+	"NAVARRO", "TORRES", "DOMÍNGUEZ", "VÁZQUEZ", "RAMOS", "GIL", "RAMÍREZ",
+	"SERRANO", "BLANCO", "MOLINA", "MORALES", "SUÁREZ", "ORTEGA", "DELGADO",
+	"CASTRO", "ORTIZ", "RUBIO", "MARÍN", "SANZ", "NÚÑEZ", "IGLESIAS",
+	"MEDINA", "GARRIDO", "CORTÉS", "CASTILLO", "SANTOS", "LOZANO", "GUERRERO",
+	"CANO", "PRIETO", "MÉNDEZ", "CRUZ", "CALVO", "GALLEGO", "VIDAL", "LEÓN",
+	"MÁRQUEZ", "HERRERA", "PEÑA", "FLORES", "CABRERA", "CAMPOS", "VEGA",
+	"FUENTES", "CARRASCO", "DÍEZ", "REYES", "CABALLERO", "NIETO", "AGUILAR",
+	"PASCUAL", "SANTANA", "HERRERO", "MONTERO", "LORENZO", "HIDALGO",
+	"GIMÉNEZ", "IBÁÑEZ", "FERRER", "DURÁN", "SANTIAGO", "BENÍTEZ", "MORA",
+	"VICENTE", "VARGAS", "ARIAS", "CARMONA", "CRESPO", "ROMÁN", "PASTOR",
+	"SOTO", "SÁEZ", "VELASCO", "MOYA", "SOLER", "PARRA", "ESTEBAN", "BRAVO",
+	"GALLARDO", "ROJAS", "ECHEVERRÍA", "ARRIETA", "COLL", "BAUZÀ",
+}
+
+var streetTypes = []string{"CALLE", "CALLE", "CALLE", "AVENIDA", "PLAZA", "PASEO", "RONDA", "CAMINO"}
+
+var streets = []string{
+	"MAYOR", "REAL", "DEL SOL", "DE LA IGLESIA", "DE CERVANTES",
+	"DE ANTONIO MACHADO", "DE FEDERICO GARCÍA LORCA", "DE LA CONSTITUCIÓN",
+	"DE ESPAÑA", "DE ANDALUCÍA", "DE CASTILLA", "DE GALICIA", "DE ARAGÓN",
+	"DEL GENERAL RICARDOS", "DE SAN JOSÉ", "DE SANTA MARÍA", "DE LA PAZ",
+	"DE LOS ÁLAMOS", "DE LAS ACACIAS", "DEL MAR", "DE LA ESTACIÓN",
+	"DE JAIME III", "DE BLAS INFANTE", "DE LA MARINA", "DEL PORTAL NOU",
+	"DE RAMÓN Y CAJAL", "DE NÚÑEZ DE BALBOA", "DE LA CASTELLANA",
+}
+
+//town is a town with a post code prefix and its province
+type town struct {
+	name, postCode, province string
+}
+
+var towns = []town{
+	{"MADRID", "280", "MADRID"},
+	{"ALCALÁ DE HENARES", "288", "MADRID"},
+	{"MÓSTOLES", "289", "MADRID"},
+	{"BARCELONA", "080", "BARCELONA"},
+	{"L'HOSPITALET DE LLOBREGAT", "089", "BARCELONA"},
+	{"SABADELL", "082", "BARCELONA"},
+	{"VALENCIA", "460", "VALENCIA"},
+	{"GANDIA", "467", "VALENCIA"},
+	{"SEVILLA", "410", "SEVILLA"},
+	{"MÁLAGA", "290", "MÁLAGA"},
+	{"CÓRDOBA", "140", "CÓRDOBA"},
+	{"JAÉN", "230", "JAÉN"},
+	{"ALMERÍA", "040", "ALMERÍA"},
+	{"CÁDIZ", "110", "CÁDIZ"},
+	{"ZARAGOZA", "500", "ZARAGOZA"},
+	{"PALMA", "070", "ILLES BALEARS"},
+	{"MANACOR", "075", "ILLES BALEARS"},
+	{"BILBAO", "480", "BIZKAIA"},
+	{"DONOSTIA-SAN SEBASTIÁN", "200", "GIPUZKOA"},
+	{"A CORUÑA", "150", "A CORUÑA"},
+	{"OURENSE", "320", "OURENSE"},
+	{"OVIEDO", "330", "ASTURIAS"},
+	{"LOGROÑO", "260", "LA RIOJA"},
+	{"PAMPLONA", "310", "NAVARRA"},
+	{"LEÓN", "240", "LEÓN"},
+	{"CÁCERES", "100", "CÁCERES"},
+	{"ÁVILA", "050", "ÁVILA"},
+	{"CASTELLÓN DE LA PLANA", "120", "CASTELLÓN"},
+	{"SANTA CRUZ DE TENERIFE", "380", "SANTA CRUZ DE TENERIFE"},
+	{"LAS PALMAS DE GRAN CANARIA", "350", "LAS PALMAS"},
+}
+
+//business is a kind of creditor: the start of its name, the CIF letter and
+//legal form of its organisation, the concept of its recurring debits and
+//its one-off one
+type business struct {
+	name, legalForm string
+	cifLetter       byte
+	recurring, once string
+	//fees are the usual amounts in cents
+	fees []int64
+}
+
+var businesses = []business{
+	{"GIMNASIO", ", S.L.", 'B', "CUOTA GIMNASIO", "MATRÍCULA ALTA SOCIO", []int64{2990, 3550, 4500}},
+	{"ACADEMIA DE IDIOMAS", ", S.L.", 'B', "MENSUALIDAD CURSO", "LIBROS Y MATERIAL", []int64{6500, 8900, 12000}},
+	{"COLEGIO", "", 'R', "CUOTA COMEDOR Y ACTIVIDADES", "SEGURO ESCOLAR Y MATRÍCULA", []int64{9850, 14200, 21075}},
+	{"CLUB DEPORTIVO", "", 'G', "CUOTA SOCIO", "FICHA FEDERATIVA", []int64{1500, 2500, 4000}},
+	{"COMUNIDAD DE PROPIETARIOS", "", 'H', "CUOTA COMUNIDAD", "DERRAMA OBRAS FACHADA", []int64{4500, 6000, 8520}},
+	{"ASESORÍA", ", S.L.P.", 'B', "HONORARIOS ASESORAMIENTO", "DECLARACIÓN DE LA RENTA", []int64{6050, 9075, 15125}},
+	{"DISTRIBUIDORA DE AGUAS", ", S.A.", 'A', "RECIBO SUMINISTRO AGUA", "ALTA CONTADOR", []int64{1875, 3240, 5610}},
+	{"ASOCIACIÓN CULTURAL", "", 'G', "CUOTA ASOCIADO", "INSCRIPCIÓN TALLER", []int64{1000, 1200, 3000}},
+}
+
+var months = []string{
+	"ENERO", "FEBRERO", "MARZO", "ABRIL", "MAYO", "JUNIO", "JULIO", "AGOSTO",
+	"SEPTIEMBRE", "OCTUBRE", "NOVIEMBRE", "DICIEMBRE",
+}
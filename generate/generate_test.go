@@ -0,0 +1,129 @@
+package generate
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/apsl/sepakit/aeb19"
+	"github.com/apsl/sepakit/ccc"
+	"github.com/apsl/sepakit/sepadebit"
+	"github.com/apsl/sepakit/tabular"
+	"golang.org/x/text/encoding/charmap"
+)
+
+func generate(t *testing.T, cfg Config, format string) []byte {
+	r, err := Remittance(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := Write(&buf, r, format); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestAEB1914(t *testing.T) {
+	cfg := Config{Creditors: 3, Dates: 2, Debits: 1001, Seed: 42}
+	out := generate(t, cfg, "aeb1914")
+	if !bytes.Equal(out, generate(t, cfg, "aeb1914")) {
+		t.Error("Expected the same output with the same seed")
+	}
+	doc, err := aeb19.NewParser().Parse(charmap.ISO8859_1.NewDecoder().Reader(bytes.NewReader(out)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.DebitRegisterCount != 1001 || len(doc.CreditorPayments) != 3 {
+		t.Fatalf("Expected 1001 debits of 3 creditors, got %s", doc)
+	}
+	sequences := map[string]int{}
+	for _, cp := range doc.CreditorPayments {
+		id := cp.Creditor.ID
+		if want, _ := ccc.CreditorID(id[7:], id[4:7]); id != want {
+			t.Errorf("Wrong creditor ID %s, expected %s", id, want)
+		}
+		if len(cp.DatePayments) != 2 {
+			t.Errorf("Expected 2 dates of creditor %s, got %d", id, len(cp.DatePayments))
+		}
+		for _, dp := range cp.DatePayments {
+			for _, dt := range dp.DebitTransactions {
+				sequences[dt.Sequence]++
+				if err := ccc.ValidateIBAN(dt.Debtor.Account); err != nil {
+					t.Error(err)
+				}
+			}
+		}
+	}
+	if len(sequences) != 4 {
+		t.Errorf("Expected every sequence type, got %v", sequences)
+	}
+}
+
+func TestPain008(t *testing.T) {
+	out := generate(t, Config{Creditors: 2, Dates: 3, Debits: 500, Seed: 7}, "pain008")
+	doc, err := sepadebit.ReadDocument(bytes.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.TransacNb != 500 {
+		t.Errorf("Expected 500 transactions, got %d", doc.TransacNb)
+	}
+	ctrlSum := doc.CtrlSum
+	if err := doc.UpdateTotals(); err != nil {
+		t.Fatal(err)
+	}
+	if doc.CtrlSum != ctrlSum {
+		t.Errorf("Wrong control sum %s, expected %s", ctrlSum, doc.CtrlSum)
+	}
+	for _, p := range doc.Payments {
+		if err := ccc.ValidateIBAN(p.Creditor.IBAN); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestCSV(t *testing.T) {
+	cfg := Config{Creditors: 1, Dates: 2, Debits: 200, Seed: 1}
+	r, err := Remittance(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, r); err != nil {
+		t.Fatal(err)
+	}
+	m, err := Mapping(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m, err = tabular.LoadMapping(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	doc, err := tabular.Parse(&buf, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Debits) != 200 {
+		t.Errorf("Expected 200 debits, got %d", len(doc.Debits))
+	}
+	cfg.Creditors = 2
+	if r, err = Remittance(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteCSV(&buf, r); err == nil {
+		t.Error("Expected error writing 2 creditors as CSV")
+	}
+}
+
+func TestConfigErrors(t *testing.T) {
+	for _, cfg := range []Config{{}, {Creditors: 2, Dates: 2, Debits: 3}} {
+		if _, err := Remittance(cfg); err == nil {
+			t.Errorf("Expected error for %+v", cfg)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package generate
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/apsl/sepakit/remittance"
+	"github.com/apsl/sepakit/sepadebit"
+	"github.com/apsl/sepakit/tabular"
+)
+
+//Formats are the formats written by Write
+var Formats = []string{"aeb1914", "pain008", "csv"}
+
+//Write writes r in format, one of Formats
+func Write(w io.Writer, r *remittance.Remittance, format string) error {
+	switch format {
+	case "aeb1914":
+		return WriteAEB1914(w, r)
+	case "pain008":
+		return WritePain008(w, r)
+	case "csv":
+		return WriteCSV(w, r)
+	}
+	return fmt.Errorf("Unknown format %s, expected %s", format, strings.Join(Formats, ", "))
+}
+
+//WriteAEB1914 writes r as an AEB 19.14 file in ISO-8859-1
+func WriteAEB1914(w io.Writer, r *remittance.Remittance) error {
+	doc, err := r.ToAEB()
+	if err != nil {
+		return err
+	}
+	return doc.Write(w)
+}
+
+//WritePain008 writes r as pain.008 XML in ISO-8859-1, transliterated to the
+//EPC basic Latin set
+func WritePain008(w io.Writer, r *remittance.Remittance) error {
+	doc, err := r.ToSEPA(sepadebit.Options{})
+	if err != nil {
+		return err
+	}
+	doc.Transliterate(doc.Options().Transliterator)
+	return doc.Write(w, sepadebit.Latin1)
+}
+
+//csvHeader are the CSV columns, as referenced by Mapping
+var csvHeader = []string{"id", "name", "iban", "bic", "amount", "mandateId", "mandateDate", "collectionDate", "sequenceType", "concept"}
+
+//WriteCSV writes the debits of r as UTF-8 CSV with a header row, to be
+//converted with Mapping(r). CSV files hold a single creditor
+func WriteCSV(w io.Writer, r *remittance.Remittance) error {
+	if len(r.Creditors) != 1 {
+		return fmt.Errorf("CSV files hold a single creditor, got %d", len(r.Creditors))
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, p := range r.Creditors[0].Payments {
+		for _, t := range p.Transactions {
+			err := cw.Write([]string{
+				t.ID,
+				t.Debtor.Name,
+				t.Debtor.IBAN,
+				t.Debtor.BIC,
+				t.Amount.String(),
+				t.Mandate.ID,
+				t.Mandate.SignatureDate.String(),
+				p.CollectionDate.String(),
+				p.SequenceType,
+				strings.Join(t.RemittanceInfo, " "),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+//Mapping returns the column mapping of the CSV written by WriteCSV
+func Mapping(r *remittance.Remittance) (*tabular.Mapping, error) {
+	if len(r.Creditors) != 1 {
+		return nil, fmt.Errorf("CSV files hold a single creditor, got %d", len(r.Creditors))
+	}
+	c := r.Creditors[0].Creditor
+	m := &tabular.Mapping{
+		Delimiter:  ",",
+		Decimal:    ".",
+		DateFormat: "YYYY-MM-DD",
+		Creditor:   tabular.Creditor{Name: c.Name, ID: c.ID, IBAN: c.IBAN, BIC: c.BIC},
+		Columns: tabular.Columns{
+			ID:             "id",
+			Name:           "name",
+			IBAN:           "iban",
+			BIC:            "bic",
+			Amount:         "amount",
+			MandateID:      "mandateId",
+			MandateDate:    "mandateDate",
+			CollectionDate: "collectionDate",
+			SequenceType:   "sequenceType",
+			Concept:        "concept",
+		},
+	}
+	return m, nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/apsl/sepakit/ledger"
+)
+
+//defaultLedgerPath returns the ledger of the user, or "" to disable it when
+//there is no configuration folder
+func defaultLedgerPath() string {
+	path, err := ledger.DefaultPath()
+	if err != nil {
+		return ""
+	}
+	return path
+}
+
+//runLedger implements "sepakit ledger query": lists the files and
+//transactions recorded by convert
+func runLedger(args []string) error {
+	if len(args) == 0 || args[0] != "query" {
+		fmt.Fprintf(os.Stderr, "Usage: %s ledger query [options]\n", os.Args[0])
+		return fmt.Errorf("Unknown ledger command")
+	}
+	fs := flag.NewFlagSet("ledger query", flag.ExitOnError)
+	path := fs.String("ledger", defaultLedgerPath(), "submission ledger")
+	msgID := fs.String("msgid", "", "message ID (MsgId) of the file")
+	e2e := fs.String("e2e", "", "transaction EndToEndId")
+	mandate := fs.String("mandate", "", "transaction mandate ID")
+	hash := fs.String("hash", "", "SHA-256 hash of the generated file or of its source")
+	since := fs.String("since", "", "files converted from this date (YYYY-MM-DD)")
+	until := fs.String("until", "", "files converted before this date (YYYY-MM-DD)")
+	format := fs.String("format", "text", "output format: text or json")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Lists the files and transactions of the submission ledger\nUsage: %s ledger query [options]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	parseArgs(fs, args[1:])
+	if *format != "text" && *format != "json" {
+		return fmt.Errorf("Unknown output format %s", *format)
+	}
+	if *path == "" {
+		return fmt.Errorf("No ledger, use -ledger FILE")
+	}
+
+	q := ledger.Query{MessageID: *msgID, EndToEndID: *e2e, MandateID: *mandate, Hash: *hash}
+	var err error
+	if *since != "" {
+		if q.Since, err = time.ParseInLocation("2006-01-02", *since, time.Local); err != nil {
+			return fmt.Errorf("Invalid -since date %s", *since)
+		}
+	}
+	if *until != "" {
+		if q.Until, err = time.ParseInLocation("2006-01-02", *until, time.Local); err != nil {
+			return fmt.Errorf("Invalid -until date %s", *until)
+		}
+	}
+	book, err := ledger.Open(*path)
+	if err != nil {
+		return err
+	}
+	entries, err := book.Query(q)
+	if err != nil {
+		return err
+	}
+	if *format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(entries)
+	}
+	return ledger.WriteText(os.Stdout, entries)
+}
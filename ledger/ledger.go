@@ -0,0 +1,398 @@
+//Package ledger records the generated pain.008 files, so that the same file
+//or transaction is not submitted twice. The ledger is a JSON Lines file with
+//an Entry per generated file, appended on every Record and read again
+//before every Check, so that several processes may share it. Reserve holds
+//the ledger from the check to the record, both for the concurrent
+//conversions of a process and for the other processes, through a lock file
+//next to the ledger.
+package ledger
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apsl/sepakit/sepadebit"
+)
+
+//Entry is a generated file
+type Entry struct {
+	Time      time.Time `json:"time"`
+	MessageID string    `json:"messageId"`
+	//OutputHash and InputHash are the SHA-256 hashes of the generated file
+	//and of its source, if known
+	OutputHash   string        `json:"outputHash"`
+	InputHash    string        `json:"inputHash,omitempty"`
+	Transactions []Transaction `json:"transactions"`
+	CtrlSum      string        `json:"ctrlSum"`
+}
+
+//Transaction is a debit of a generated file
+type Transaction struct {
+	EndToEndID     string `json:"endToEndId"`
+	MandateID      string `json:"mandateId"`
+	Amount         string `json:"amount"`
+	CollectionDate string `json:"collectionDate"`
+}
+
+//key identifies a transaction among the submitted ones
+func (t Transaction) key() string {
+	return strings.Join([]string{t.EndToEndID, t.MandateID, t.Amount, t.CollectionDate}, "|")
+}
+
+func (t Transaction) String() string {
+	return fmt.Sprintf("%s (mandate %s, %s on %s)", t.EndToEndID, t.MandateID, t.Amount, t.CollectionDate)
+}
+
+//Hash returns the hex SHA-256 hash of data
+func Hash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+//NewEntry returns the entry of doc, written as output
+func NewEntry(doc *sepadebit.Document, output []byte) *Entry {
+	e := &Entry{
+		Time:       time.Now(),
+		MessageID:  doc.MsgID,
+		OutputHash: Hash(output),
+		CtrlSum:    doc.CtrlSum,
+	}
+	for _, p := range doc.Payments {
+		for _, t := range p.Transactions {
+			e.Transactions = append(e.Transactions, Transaction{
+				EndToEndID:     t.ID,
+				MandateID:      t.Mandate.ID,
+				Amount:         t.Amount.Amount,
+				CollectionDate: p.RequestedCollectionDate,
+			})
+		}
+	}
+	return e
+}
+
+//Duplicate is a file or transaction found in the ledger
+type Duplicate struct {
+	//Transaction is nil when the whole file was submitted
+	Transaction *Transaction
+	//Previous is the entry that submitted it
+	Previous *Entry
+}
+
+func (d Duplicate) String() string {
+	when := d.Previous.Time.Format(time.RFC3339)
+	if d.Transaction == nil {
+		return fmt.Sprintf("File already submitted as %s on %s", d.Previous.MessageID, when)
+	}
+	return fmt.Sprintf("Transaction %s already submitted in %s on %s", d.Transaction, d.Previous.MessageID, when)
+}
+
+//DuplicateError is the error of a conversion refused by the ledger
+type DuplicateError []Duplicate
+
+func (e DuplicateError) Error() string {
+	msg := e[0].String()
+	if len(e) > 1 {
+		msg += fmt.Sprintf(" (and %d more)", len(e)-1)
+	}
+	return msg
+}
+
+//lockTimeout is how long Reserve and Record wait for the lock file held by
+//another process
+var lockTimeout = 30 * time.Second
+
+//Ledger is an open ledger file. Its methods may be called concurrently
+type Ledger struct {
+	path string
+
+	mu      sync.Mutex
+	offset  int64
+	entries []*Entry
+	files   map[string]*Entry
+	txs     map[string]*Entry
+}
+
+//DefaultPath returns the ledger of the user, in its configuration folder
+func DefaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "sepakit", "ledger.jsonl"), nil
+}
+
+//Open reads the ledger at path, which is created by the first Record
+func Open(path string) (*Ledger, error) {
+	l := &Ledger{path: path, files: map[string]*Entry{}, txs: map[string]*Entry{}}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.refresh(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+//refresh reads the entries appended since the last read
+func (l *Ledger) refresh() error {
+	f, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Seek(l.offset, io.SeekStart); err != nil {
+		return err
+	}
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			//a line being written by another process is read next time
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		l.offset += int64(len(line))
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		e := &Entry{}
+		if err := json.Unmarshal(line, e); err != nil {
+			return fmt.Errorf("%s: invalid entry at byte %d: %s", l.path, l.offset-int64(len(line)), err)
+		}
+		l.add(e)
+	}
+}
+
+func (l *Ledger) add(e *Entry) {
+	l.entries = append(l.entries, e)
+	l.files[e.OutputHash] = e
+	if e.InputHash != "" {
+		l.files[e.InputHash] = e
+	}
+	for _, t := range e.Transactions {
+		l.txs[t.key()] = e
+	}
+}
+
+//Check returns the files and transactions of e already in the ledger
+func (l *Ledger) Check(e *Entry) ([]Duplicate, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.check(e)
+}
+
+func (l *Ledger) check(e *Entry) ([]Duplicate, error) {
+	if err := l.refresh(); err != nil {
+		return nil, err
+	}
+	var dups []Duplicate
+	if prev, ok := l.files[e.OutputHash]; ok {
+		dups = append(dups, Duplicate{Previous: prev})
+	} else if prev, ok := l.files[e.InputHash]; ok && e.InputHash != "" {
+		dups = append(dups, Duplicate{Previous: prev})
+	}
+	for i := range e.Transactions {
+		if prev, ok := l.txs[e.Transactions[i].key()]; ok {
+			dups = append(dups, Duplicate{Transaction: &e.Transactions[i], Previous: prev})
+		}
+	}
+	return dups, nil
+}
+
+//Record appends e to the ledger
+func (l *Ledger) Record(e *Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.lock(); err != nil {
+		return err
+	}
+	defer l.unlock()
+	return l.record(e)
+}
+
+//lock creates the lock file of the ledger, waiting while another process
+//holds it. Creating it with O_EXCL works on every platform and file system
+func (l *Ledger) lock() error {
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return err
+	}
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		f, err := os.OpenFile(l.path+".lock", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			fmt.Fprintf(f, "%d\n", os.Getpid())
+			return f.Close()
+		}
+		if !os.IsExist(err) {
+			return err
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("Ledger %s is locked by another process, remove %s.lock if no conversion is running", l.path, l.path)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+//unlock removes the lock file of the ledger
+func (l *Ledger) unlock() {
+	os.Remove(l.path + ".lock")
+}
+
+func (l *Ledger) record(e *Entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if err := l.refresh(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	//a single write, so that concurrent appends do not interleave
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return l.refresh()
+}
+
+//Reservation is an entry checked by Reserve, holding the ledger until it is
+//recorded or released
+type Reservation struct {
+	l    *Ledger
+	e    *Entry
+	done bool
+}
+
+//Reserve checks e as Check and holds the ledger, so that no other
+//conversion, of the process or of another one, reserves or records an entry
+//until the reservation is recorded or released: two conversions of the same
+//file or transactions cannot both pass the check. When e has duplicates and force is not set, the
+//ledger is not held and the error is a DuplicateError. Forced duplicates are
+//returned
+func (l *Ledger) Reserve(e *Entry, force bool) (*Reservation, []Duplicate, error) {
+	l.mu.Lock()
+	if err := l.lock(); err != nil {
+		l.mu.Unlock()
+		return nil, nil, err
+	}
+	dups, err := l.check(e)
+	if err == nil && len(dups) > 0 && !force {
+		err = DuplicateError(dups)
+	}
+	if err != nil {
+		l.unlock()
+		l.mu.Unlock()
+		return nil, dups, err
+	}
+	return &Reservation{l: l, e: e}, dups, nil
+}
+
+//Record appends the reserved entry to the ledger and releases it
+func (r *Reservation) Record() error {
+	if r.done {
+		return nil
+	}
+	r.done = true
+	defer r.l.mu.Unlock()
+	defer r.l.unlock()
+	return r.l.record(r.e)
+}
+
+//Release releases the ledger without recording the entry. It does nothing
+//once the entry is recorded
+func (r *Reservation) Release() {
+	if r.done {
+		return
+	}
+	r.done = true
+	r.l.unlock()
+	r.l.mu.Unlock()
+}
+
+//Query selects entries. Empty fields match every entry
+type Query struct {
+	MessageID  string
+	EndToEndID string
+	MandateID  string
+	//Hash matches the output or the input hash
+	Hash         string
+	Since, Until time.Time
+}
+
+//Query returns the entries matching q, keeping only the matching
+//transactions when q selects transactions
+func (l *Ledger) Query(q Query) ([]*Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.refresh(); err != nil {
+		return nil, err
+	}
+	var found []*Entry
+	for _, e := range l.entries {
+		if q.MessageID != "" && e.MessageID != q.MessageID ||
+			q.Hash != "" && e.OutputHash != q.Hash && e.InputHash != q.Hash ||
+			!q.Since.IsZero() && e.Time.Before(q.Since) ||
+			!q.Until.IsZero() && !e.Time.Before(q.Until) {
+			continue
+		}
+		if q.EndToEndID == "" && q.MandateID == "" {
+			found = append(found, e)
+			continue
+		}
+		match := *e
+		match.Transactions = nil
+		for _, t := range e.Transactions {
+			if (q.EndToEndID == "" || t.EndToEndID == q.EndToEndID) && (q.MandateID == "" || t.MandateID == q.MandateID) {
+				match.Transactions = append(match.Transactions, t)
+			}
+		}
+		if len(match.Transactions) > 0 {
+			found = append(found, &match)
+		}
+	}
+	return found, nil
+}
+
+//WriteText writes entries as text, a line per entry followed by its
+//transactions
+func WriteText(w io.Writer, entries []*Entry) error {
+	for _, e := range entries {
+		_, err := fmt.Fprintf(w, "%s %s %d transactions, %s EUR, sha256 %s\n", e.Time.Format(time.RFC3339), e.MessageID, len(e.Transactions), e.CtrlSum, e.OutputHash)
+		if err != nil {
+			return err
+		}
+		for _, t := range e.Transactions {
+			if _, err := fmt.Fprintf(w, "  %s\n", t); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package ledger
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testEntry(msgID, hash string, ids ...string) *Entry {
+	e := &Entry{Time: time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC), MessageID: msgID, OutputHash: hash, CtrlSum: "10.00"}
+	for _, id := range ids {
+		e.Transactions = append(e.Transactions, Transaction{EndToEndID: id, MandateID: "M-" + id, Amount: "5.00", CollectionDate: "2026-03-10"})
+	}
+	return e
+}
+
+func TestCheckRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sub", "ledger.jsonl")
+	l, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Record(testEntry("MSG1", "h1", "A", "B")); err != nil {
+		t.Fatal(err)
+	}
+	//another process sharing the file
+	other, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dups, err := other.Check(testEntry("MSG2", "h1", "C"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dups) != 1 || dups[0].Transaction != nil || dups[0].Previous.MessageID != "MSG1" {
+		t.Errorf("Expected the file duplicate, got %v", dups)
+	}
+	dups, err = other.Check(testEntry("MSG2", "h2", "B", "C"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dups) != 1 || dups[0].Transaction == nil || dups[0].Transaction.EndToEndID != "B" {
+		t.Errorf("Expected the transaction B duplicate, got %v", dups)
+	}
+	if err := other.Record(testEntry("MSG2", "h2", "C")); err != nil {
+		t.Fatal(err)
+	}
+	dups, err = l.Check(testEntry("MSG3", "h3", "C"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dups) != 1 || dups[0].Previous.MessageID != "MSG2" {
+		t.Errorf("Expected the transaction C recorded by the other ledger, got %v", dups)
+	}
+	if msg := DuplicateError(dups).Error(); msg != "Transaction C (mandate M-C, 5.00 on 2026-03-10) already submitted in MSG2 on 2026-03-02T10:00:00Z" {
+		t.Errorf("Unexpected error %q", msg)
+	}
+}
+
+func TestReserve(t *testing.T) {
+	l, err := Open(filepath.Join(t.TempDir(), "ledger.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	//the same debit converted at once: only one of them passes
+	var wg sync.WaitGroup
+	var recorded, refused int32
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			r, _, err := l.Reserve(testEntry(fmt.Sprintf("MSG%d", i), fmt.Sprintf("h%d", i), "A"), false)
+			if _, ok := err.(DuplicateError); ok {
+				atomic.AddInt32(&refused, 1)
+				return
+			}
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer r.Release()
+			if err := r.Record(); err != nil {
+				t.Error(err)
+				return
+			}
+			atomic.AddInt32(&recorded, 1)
+		}(i)
+	}
+	wg.Wait()
+	if recorded != 1 || refused != 7 {
+		t.Errorf("Expected 1 recorded and 7 refused, got %d and %d", recorded, refused)
+	}
+
+	//a released reservation records nothing
+	r, _, err := l.Reserve(testEntry("MSG9", "h9", "B"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Release()
+	if r, dups, err := l.Reserve(testEntry("MSG10", "h10", "B", "A"), true); err != nil || len(dups) != 1 {
+		t.Errorf("Expected a forced duplicate, got %v %v", dups, err)
+	} else {
+		r.Release()
+	}
+	if entries, _ := l.Query(Query{}); len(entries) != 1 {
+		t.Errorf("Expected 1 entry, got %d", len(entries))
+	}
+}
+
+func TestReserveProcesses(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ledger.jsonl")
+	//a Ledger per process sharing the file, each converting the same debit
+	ledgers := make([]*Ledger, 8)
+	for i := range ledgers {
+		l, err := Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ledgers[i] = l
+	}
+	var wg sync.WaitGroup
+	var recorded, refused int32
+	for i, l := range ledgers {
+		wg.Add(1)
+		go func(i int, l *Ledger) {
+			defer wg.Done()
+			r, _, err := l.Reserve(testEntry(fmt.Sprintf("MSG%d", i), fmt.Sprintf("h%d", i), "A"), false)
+			if _, ok := err.(DuplicateError); ok {
+				atomic.AddInt32(&refused, 1)
+				return
+			}
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer r.Release()
+			//give the other ledgers time to check while the entry is reserved
+			time.Sleep(20 * time.Millisecond)
+			if err := r.Record(); err != nil {
+				t.Error(err)
+				return
+			}
+			atomic.AddInt32(&recorded, 1)
+		}(i, l)
+	}
+	wg.Wait()
+	if recorded != 1 || refused != 7 {
+		t.Errorf("Expected 1 recorded and 7 refused, got %d and %d", recorded, refused)
+	}
+
+	//a lock file left by another process makes Reserve fail after a while
+	r, _, err := ledgers[0].Reserve(testEntry("MSG9", "h9", "B"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Release()
+	defer func(timeout time.Duration) { lockTimeout = timeout }(lockTimeout)
+	lockTimeout = 50 * time.Millisecond
+	if _, _, err := ledgers[1].Reserve(testEntry("MSG10", "h10", "C"), false); err == nil || !strings.Contains(err.Error(), "locked") {
+		t.Errorf("Expected a locked ledger error, got %v", err)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	l, err := Open(filepath.Join(t.TempDir(), "ledger.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := testEntry("MSG1", "h1", "A", "B")
+	old.Time = old.Time.AddDate(0, -1, 0)
+	for _, e := range []*Entry{old, testEntry("MSG2", "h2", "C")} {
+		if err := l.Record(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		q        Query
+		expected []string
+	}{
+		{Query{}, []string{"MSG1", "MSG2"}},
+		{Query{MessageID: "MSG2"}, []string{"MSG2"}},
+		{Query{Hash: "h1"}, []string{"MSG1"}},
+		{Query{Since: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)}, []string{"MSG2"}},
+		{Query{Until: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)}, []string{"MSG1"}},
+		{Query{MandateID: "M-B"}, []string{"MSG1"}},
+		{Query{EndToEndID: "X"}, nil},
+	}
+	for _, test := range tests {
+		found, err := l.Query(test.q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, e := range found {
+			ids = append(ids, e.MessageID)
+		}
+		if len(ids) != len(test.expected) || len(ids) > 0 && ids[0] != test.expected[0] {
+			t.Errorf("Query %+v: expected %v, got %v", test.q, test.expected, ids)
+		}
+	}
+	found, _ := l.Query(Query{EndToEndID: "B"})
+	if len(found) != 1 || len(found[0].Transactions) != 1 {
+		t.Errorf("Expected only the matching transaction, got %+v", found)
+	}
+}
@@ -1,53 +1,368 @@
 package main
 
 import (
-	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"syscall"
 
+	"github.com/apsl/sepakit/batch"
+	"github.com/apsl/sepakit/ccc"
 	"github.com/apsl/sepakit/convert"
+	"github.com/apsl/sepakit/ledger"
+	"github.com/apsl/sepakit/redact"
+	"github.com/apsl/sepakit/sepadebit"
+	"github.com/apsl/sepakit/tabular"
+	"github.com/apsl/sepakit/translit"
 )
 
+//commands maps subcommand names to their entry points. Without a known
+//subcommand sepakit behaves as the original AEB 19.14 to XML converter.
+var commands = map[string]func(args []string) error{
+	"convert":   runConvert,
+	"split":     runSplit,
+	"merge":     runMerge,
+	"diff":      runDiff,
+	"serve":     runServe,
+	"watch":     runWatch,
+	"ledger":    runLedger,
+	"anonymize": runAnonymize,
+	"generate":  runGenerate,
+}
+
 func main() {
-	inpath := "-"
-	outpath := "-"
-	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Converts AEB 19.14 TXT file to SEPA XML file\nUsage: %s [INFILE] [OUTFILE]\nDefaults to stdin and stdout (-)\n", os.Args[0])
+	//the redacted identifiers are followed across the runs of the installation
+	if path, err := redact.DefaultKeyPath(); err == nil {
+		if key, err := redact.LoadKey(path); err == nil {
+			redact.SetKey(key)
+		}
+	}
+	args := os.Args[1:]
+	if len(args) > 0 {
+		if cmd, ok := commands[args[0]]; ok {
+			if err := cmd(args[1:]); err != nil {
+				fatal(err)
+			}
+			return
+		}
+	}
+	if err := convertFiles(args, "none"); err != nil {
+		fatal(err)
 	}
-	flag.Parse()
+}
 
-	if len(os.Args) > 1 {
-		inpath = os.Args[1]
+//errDiffers is returned by runDiff when the files differ, to exit with
+//status 1 without a message, as diff(1) does
+var errDiffers = errors.New("Files differ")
+
+//fatal logs err and exits with status 1
+func fatal(err error) {
+	if err == errDiffers {
+		os.Exit(1)
 	}
-	if len(os.Args) > 2 {
-		outpath = os.Args[2]
+	log.Fatal(err)
+}
+
+//runConvert implements "sepakit convert": converts an AEB 19, CSB 19, CSV
+//or XLSX file to SEPA XML, recording it in the ledger of the user by default
+func runConvert(args []string) error {
+	return convertFiles(args, defaultLedgerPath())
+}
+
+//convertFiles implements runConvert, also run without subcommand, with
+//defaultLedger as the default -ledger. Without subcommand the ledger is only
+//used when -ledger is given, as in the original converter
+func convertFiles(args []string, defaultLedger string) error {
+	fs := flag.NewFlagSet("convert", flag.ExitOnError)
+	encoding := fs.String("encoding", "iso-8859-1", "output encoding: iso-8859-1 or utf-8")
+	inputEncodingName := fs.String("input-encoding", "iso-8859-1", "AEB 19 and CSB 19 input encoding: iso-8859-1, windows-1252, cp850 or utf-8")
+	charsets := fs.String("charset", "", "extended character sets allowed besides EPC basic Latin, comma separated (es, de)")
+	report := fs.Bool("report", false, "report every field altered by transliteration or length policy on stderr")
+	audit := fs.Bool("audit", false, "write the JSON audit report of the conversion next to the output, as OUTFILE.audit.json")
+	auditKeyPath := fs.String("audit-key", "", "file with the key signing the audit reports (HMAC-SHA256)")
+	lengths := fs.String("lengths", "error", "policy for texts longer than allowed: error, truncate or wrap")
+	rf := fs.String("rf", "none", "RF creditor references from the concepts that are RF references or generated from the transaction ids: none, concept or id")
+	address := fs.String("address", "lines", "creditor address format: lines (AdrLine) or structured (StrtNm, PstCd, TwnNm...)")
+	partiesPath := fs.String("parties", "", "JSON file with ultimate creditors and debtors by creditor ID")
+	profileName := fs.String("profile", "caixabank", "bank profile setting the creditor agent BIC and the charge bearer")
+	from := fs.String("from", "aeb19", "input format: aeb19 (AEB 19.14 and 19.44), csb19 (pre-SEPA Cuaderno 19), csv, xlsx or json")
+	to := fs.String("to", "xml", "output format: xml (pain.008) or json (remittance)")
+	mappingPath := fs.String("mapping", "", "csv and xlsx column mapping file (YAML or JSON)")
+	rulesPath := fs.String("rules", "", "JSON rules file rewriting or skipping transactions")
+	ledgerPath := fs.String("ledger", defaultLedger, "submission ledger refusing files and transactions already converted, none to disable")
+	force := fs.Bool("force", false, "convert files and transactions already in the ledger, with a warning")
+	entitiesPath := fs.String("entities", "", "csb19 bank entity list updating the built-in one, \"code BIC\" lines")
+	mandateRule := fs.String("mandate-id", convert.DefaultMandateRule, "csb19 mandate ID rule, with {nif}, {suffix}, {reference} and {internal} placeholders")
+	nameLength := fs.Int("name-length", 0, "maximum length of party names, if shorter than ISO 20022 Max140Text")
+	batchGlob := fs.String("batch", "", "convert every file matching the pattern, such as 'in/*.txt', into -out-dir")
+	outDir := fs.String("out-dir", "", "batch output folder")
+	jobs := fs.Int("jobs", runtime.NumCPU(), "batch concurrent conversions")
+	summary := fs.String("summary", "table", "batch summary format: table or json")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Converts AEB 19.14 TXT file to SEPA XML file\nUsage: %s [convert] [options] [INFILE] [OUTFILE]\n       %s convert [options] -batch 'in/*.txt' -out-dir DIR [-jobs N]\nDefaults to stdin and stdout (-)\n", os.Args[0], os.Args[0])
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\nOther commands:\n  %s split [options] INFILE\n  %s merge INFILE... -o OUTFILE\n  %s diff OLDFILE NEWFILE\n  %s serve [-addr :8080]\n  %s watch -in DIR -out DIR -archive DIR -error DIR\n  %s ledger query [-msgid ID] [-e2e ID] [-mandate ID]\n  %s anonymize INFILE OUTFILE\n  %s generate [-creditors N] [-dates N] [-debits N] [-format F] OUTFILE\n", os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0])
 	}
+	paths := parseArgs(fs, args)
 
-	var err error
-	fin := os.Stdin
-	if inpath != "-" {
-		fin, err = os.Open(inpath)
+	inpath := "-"
+	outpath := "-"
+	if len(paths) > 0 {
+		inpath = paths[0]
+	}
+	if len(paths) > 1 {
+		outpath = paths[1]
+	}
+	enc, err := sepadebit.ParseEncoding(*encoding)
+	if err != nil {
+		return err
+	}
+	inputEncoding, err := convert.ParseInputEncoding(*inputEncodingName)
+	if err != nil {
+		return err
+	}
+	if *to != "xml" && *to != "json" {
+		return fmt.Errorf("Unknown output format %s", *to)
+	}
+	sets, err := translit.SetsByName(*charsets)
+	if err != nil {
+		return err
+	}
+	policy := sepadebit.LengthPolicy{}
+	policy.Mode, err = sepadebit.ParseLengthMode(*lengths)
+	if err != nil {
+		return err
+	}
+	rfSource, err := convert.ParseRFSource(*rf)
+	if err != nil {
+		return err
+	}
+	addressMode, err := convert.ParseAddressMode(*address)
+	if err != nil {
+		return err
+	}
+	profile, err := convert.ParseProfile(*profileName)
+	if err != nil {
+		return err
+	}
+	var parties convert.PartiesMap
+	if *entitiesPath != "" {
+		f, err := openInput(*entitiesPath)
+		if err != nil {
+			return err
+		}
+		err = ccc.LoadEntities(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %s", *entitiesPath, err)
+		}
+	}
+	if *partiesPath != "" {
+		f, err := openInput(*partiesPath)
+		if err != nil {
+			return err
+		}
+		parties, err = convert.LoadPartiesMap(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %s", *partiesPath, err)
+		}
+	}
+	var mapping *tabular.Mapping
+	switch *from {
+	case "aeb19", "csb19", "json":
+	case "csv", "xlsx":
+		if *mappingPath == "" {
+			return fmt.Errorf("-from %s needs a -mapping file", *from)
+		}
+		f, err := openInput(*mappingPath)
+		if err != nil {
+			return err
+		}
+		mapping, err = tabular.LoadMapping(f)
+		f.Close()
 		if err != nil {
-			log.Fatalf("%s: file not found\n", inpath)
+			return fmt.Errorf("%s: %s", *mappingPath, err)
 		}
-		defer fin.Close()
+	default:
+		return fmt.Errorf("Unknown input format %s", *from)
+	}
+	if *nameLength > 0 {
+		policy.Limits = map[string]int{"Nm": *nameLength}
+	}
+	var hooks []convert.TransactionHook
+	if *rulesPath != "" {
+		f, err := openInput(*rulesPath)
+		if err != nil {
+			return err
+		}
+		rules, err := convert.LoadRules(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %s", *rulesPath, err)
+		}
+		hooks = append(hooks, rules)
 	}
 
-	fout := bufio.NewWriter(os.Stdout)
+	var book *ledger.Ledger
+	if *ledgerPath != "none" && *ledgerPath != "" {
+		if book, err = ledger.Open(*ledgerPath); err != nil {
+			return err
+		}
+	}
+	conv, err := convert.NewConverter(
+		convert.WithOptions(convert.Options{
+			RFReference: rfSource,
+			Parties:     parties,
+			Address:     addressMode,
+			MandateRule: convert.MandateRule(*mandateRule),
+			Mapping:     mapping,
+		}),
+		convert.WithInputFormat(*from),
+		convert.WithInputEncoding(inputEncoding),
+		convert.WithOutputFormat(*to),
+		convert.WithOutputEncoding(enc),
+		convert.WithProfile(profile),
+		convert.WithCharsets(sets...),
+		convert.WithLengths(policy),
+		convert.WithTransactionHooks(hooks...),
+		convert.WithLedger(book, *force),
+		convert.WithLogger(log.New(os.Stderr, "", log.LstdFlags)),
+	)
+	if err != nil {
+		return err
+	}
+	var auditKey []byte
+	if *auditKeyPath != "" {
+		if auditKey, err = ioutil.ReadFile(*auditKeyPath); err != nil {
+			return err
+		}
+		auditKey = bytes.TrimSpace(auditKey)
+	}
+	if *batchGlob != "" {
+		return runBatch(*batchGlob, batch.Config{Jobs: *jobs, OutDir: *outDir, Converter: conv, Audit: *audit, AuditKey: auditKey}, *summary)
+	}
+	if *audit && outpath == "-" {
+		return fmt.Errorf("-audit needs an output file")
+	}
+
+	fin, err := openInput(inpath)
+	if err != nil {
+		return err
+	}
+	defer fin.Close()
+
+	//the output file is replaced only once converted, and recorded in the
+	//ledger once written
+	var fout io.Writer = os.Stdout
 	if outpath != "-" {
-		f, err := os.Create(outpath)
+		f, err := convert.CreateFile(outpath)
 		if err != nil {
-			log.Fatalf("Cannot open file %s for writing: %s\n", outpath, err)
+			return fmt.Errorf("Cannot open file %s for writing: %s", outpath, err)
 		}
 		defer f.Close()
-		fout = bufio.NewWriter(f)
+		fout = f
 	}
 
-	err = convert.Latin1DebitTxtToXML(fin, fout)
+	rep, err := conv.Convert(context.Background(), fin, fout)
+	if *report {
+		for _, t := range rep.Transformations {
+			fmt.Fprintf(os.Stderr, "%s: %q -> %q\n", t.Field, t.Original, t.Result)
+		}
+	}
 	if err != nil {
-		log.Fatal("error writting xml: ", err)
+		return err
+	}
+	if *audit {
+		return convert.WriteAudit(convert.AuditPath(outpath), rep, auditKey)
+	}
+	return nil
+}
+
+//runBatch converts the files matching pattern concurrently and writes the
+//summary on stdout. SIGINT and SIGTERM cancel the pending conversions
+func runBatch(pattern string, cfg batch.Config, summary string) error {
+	if cfg.OutDir == "" {
+		return fmt.Errorf("-batch needs an -out-dir folder")
+	}
+	if summary != "table" && summary != "json" {
+		return fmt.Errorf("Unknown summary format %s", summary)
+	}
+	inputs, err := filepath.Glob(pattern)
+	if err != nil {
+		return err
+	}
+	if len(inputs) == 0 {
+		return fmt.Errorf("No files match %s", pattern)
+	}
+	if err := os.MkdirAll(cfg.OutDir, 0755); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(stop)
+	go func() {
+		select {
+		case sig := <-stop:
+			log.Printf("Got %s, canceling the pending conversions\n", sig)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	s := batch.Run(ctx, inputs, cfg)
+	if summary == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(s)
+	} else {
+		err = s.WriteTable(os.Stdout)
+	}
+	if err != nil {
+		return err
+	}
+	if s.Failed > 0 {
+		return fmt.Errorf("%d of %d files failed", s.Failed, s.Files)
+	}
+	return nil
+}
+
+//openInput opens path for reading, "-" meaning stdin
+func openInput(path string) (io.ReadCloser, error) {
+	if path == "-" {
+		return os.Stdin, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: file not found", path)
+	}
+	return f, nil
+}
+
+//parseArgs parses the flags found anywhere in args, not only before the first
+//positional argument, and returns the positional arguments
+func parseArgs(fs *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		fs.Parse(args)
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
 	}
-	fout.Flush()
 }
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/apsl/sepakit/convert"
+)
+
+//runMerge implements "sepakit merge": reads several AEB 19.14 or pain.008
+//files and writes a single consolidated SEPA XML file
+func runMerge(args []string) error {
+	fs := flag.NewFlagSet("merge", flag.ExitOnError)
+	outpath := fs.String("o", "-", "output file")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Merges AEB 19.14 TXT and SEPA XML files into a single SEPA XML file\nUsage: %s merge INFILE... [-o OUTFILE]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	inpaths := parseArgs(fs, args)
+	if len(inpaths) == 0 {
+		fs.Usage()
+		return fmt.Errorf("No input files")
+	}
+
+	var ins []io.Reader
+	for _, inpath := range inpaths {
+		fin, err := openInput(inpath)
+		if err != nil {
+			return err
+		}
+		defer fin.Close()
+		ins = append(ins, fin)
+	}
+	doc, err := convert.Merge(ins...)
+	if err != nil {
+		return err
+	}
+	if *outpath != "-" {
+		return writeXMLFile(*outpath, doc)
+	}
+	w := bufio.NewWriter(os.Stdout)
+	if err := doc.WriteLatin1(w); err != nil {
+		return err
+	}
+	return w.Flush()
+}
@@ -0,0 +1,180 @@
+//Package redact masks the personal data of debtors and creditors in logs,
+//reports and error messages: accounts keep their last 4 characters,
+//identifiers such as NIFs become a short keyed hash, names their initials
+//and addresses and concepts are masked.
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+//KeySize is the size of the keys created by LoadKey
+const KeySize = 32
+
+var (
+	keyMu sync.Mutex
+	key   []byte
+)
+
+//SetKey sets the HMAC key of ID, usually the key of the installation read by
+//LoadKey. Without it a random key of the process is used, and the same
+//identifier can only be followed within a run
+func SetKey(k []byte) {
+	keyMu.Lock()
+	defer keyMu.Unlock()
+	key = append([]byte(nil), k...)
+}
+
+func idKey() []byte {
+	keyMu.Lock()
+	defer keyMu.Unlock()
+	if key == nil {
+		key = make([]byte, KeySize)
+		if _, err := rand.Read(key); err != nil {
+			panic(err)
+		}
+	}
+	return key
+}
+
+//DefaultKeyPath returns the key file of the installation, in the
+//configuration folder of the user
+func DefaultKeyPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "sepakit", "redact.key"), nil
+}
+
+//LoadKey reads the key file at path, creating it with a random key readable
+//only by the user when it does not exist
+func LoadKey(path string) ([]byte, error) {
+	k, err := ioutil.ReadFile(path)
+	if err == nil {
+		if len(k) < KeySize {
+			return nil, fmt.Errorf("%s: key shorter than %d bytes", path, KeySize)
+		}
+		return k, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+	k = make([]byte, KeySize)
+	if _, err := rand.Read(k); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if os.IsExist(err) {
+		//created meanwhile by another process
+		return LoadKey(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Write(k); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return k, f.Close()
+}
+
+//IBAN masks an IBAN or another account number, such as a CCC, but for its
+//country code and its last 4 characters:
+//
+//	ES91 2100 0418 4502 0005 1332 -> ES******************1332
+func IBAN(iban string) string {
+	iban = strings.NewReplacer(" ", "", "-", "").Replace(iban)
+	if len(iban) <= 4 {
+		return strings.Repeat("*", len(iban))
+	}
+	prefix := ""
+	if len(iban) > 6 && isLetter(iban[0]) && isLetter(iban[1]) {
+		prefix = iban[:2]
+	}
+	return prefix + strings.Repeat("*", len(iban)-len(prefix)-4) + iban[len(iban)-4:]
+}
+
+func isLetter(b byte) bool {
+	return b >= 'A' && b <= 'Z' || b >= 'a' && b <= 'z'
+}
+
+//ID replaces an identifier, such as a NIF, with the first 10 hex digits of
+//its HMAC-SHA256 with the key set by SetKey, so that the same identifier can
+//be followed across logs without being shown, nor found by hashing every
+//possible NIF:
+//
+//	12345678Z -> #1c9f963289 (with one key)
+func ID(id string) string {
+	id = strings.ToUpper(strings.TrimSpace(id))
+	if id == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, idKey())
+	mac.Write([]byte(id))
+	return "#" + hex.EncodeToString(mac.Sum(nil))[:10]
+}
+
+//Name replaces a name with its initials:
+//
+//	MUÑOZ IBÁÑEZ, JUAN -> M.I.J.
+func Name(name string) string {
+	var initials []rune
+	start := true
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			start = true
+			continue
+		}
+		if start {
+			initials = append(initials, unicode.ToUpper(r), '.')
+			start = false
+		}
+	}
+	return string(initials)
+}
+
+//Text masks the letters and digits of a free text, such as an address or a
+//concept, keeping its length and punctuation:
+//
+//	C/ MAYOR, 1 -> */ *****, *
+func Text(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return '*'
+		}
+		return r
+	}, s)
+}
+
+//Field redacts the value of a pain.008 element given by its path, such as
+//"PmtInf[rem1]/DrctDbtTxInf[R1]/Dbtr/Nm": names become initials, IBANs and
+//address lines, streets and concepts are masked and identifiers hashed.
+//Other elements are returned as they are
+func Field(path, value string) string {
+	element := path[strings.LastIndex(path, "/")+1:]
+	switch {
+	case element == "Nm":
+		return Name(value)
+	case strings.HasSuffix(element, "IBAN"):
+		return IBAN(value)
+	case element == "AdrLine" || element == "StrtNm" || element == "Ustrd":
+		return Text(value)
+	case element == "Id":
+		return ID(value)
+	}
+	return value
+}
@@ -0,0 +1,70 @@
+package redact
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		got, expected string
+	}{
+		{IBAN("ES91 2100 0418 4502 0005 1332"), "ES******************1332"},
+		{IBAN("21000418450200051332"), "****************1332"},
+		{IBAN("123"), "***"},
+		{Name("MUÑOZ IBÁÑEZ, JUAN"), "M.I.J."},
+		{Name("NOMBRE DEL DEUDOR, S.L."), "N.D.D.S.L."},
+		{Name(""), ""},
+		{ID(""), ""},
+		{Field("PmtInf[rem1]/DrctDbtTxInf[R1]/Dbtr/Nm", "ÁLVARO"), "Á."},
+		{Field("PmtInf[rem1]/DrctDbtTxInf[R1]/RmtInf/Ustrd", "RECIBO 12"), "****** **"},
+		{Field("PmtInf[rem1]/Cdtr/PstlAdr/AdrLine", "C/ MAYOR, 1"), "*/ *****, *"},
+		{Field("PmtInf[rem1]/Cdtr/PstlAdr/StrtNm", "MAYOR"), "*****"},
+		{Field("PmtInf[rem1]/DrctDbtTxInf[R1]/UltmtDbtr/Othr/Id", "12345678Z"), ID("12345678Z")},
+		{Field("PmtInf[rem1]/DrctDbtTxInf[R1]/EndToEndId", "R1"), "R1"},
+	}
+	for _, test := range tests {
+		if test.got != test.expected {
+			t.Errorf("Expected %q, got %q", test.expected, test.got)
+		}
+	}
+	if id := ID("12345678z"); len(id) != 11 || id != ID(" 12345678Z") || id == ID("87654321X") {
+		t.Errorf("Unexpected ID hash %s", id)
+	}
+}
+
+func TestKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sepakit", "redact.key")
+	key, err := LoadKey(path)
+	if err != nil || len(key) != KeySize {
+		t.Fatalf("Expected a new key, got %d bytes (%v)", len(key), err)
+	}
+	if again, err := LoadKey(path); err != nil || !bytes.Equal(again, key) {
+		t.Errorf("Expected the stored key, got %v", err)
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0600 {
+		t.Errorf("Expected a key file readable by the user only, got %v", fi.Mode())
+	}
+
+	//the hashes depend on the key, so they can not be found by hashing NIFs
+	SetKey(key)
+	id := ID("12345678Z")
+	SetKey(bytes.Repeat([]byte{1}, KeySize))
+	if ID("12345678Z") == id {
+		t.Error("ID does not depend on the key")
+	}
+	SetKey(key)
+	if ID("12345678Z") != id {
+		t.Error("ID is not stable with a key")
+	}
+
+	if err := ioutil.WriteFile(path, []byte("short"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadKey(path); err == nil {
+		t.Error("Expected an error for a short key")
+	}
+}
@@ -0,0 +1,165 @@
+package remittance
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/apsl/sepakit/aeb19"
+	"github.com/apsl/sepakit/sepadebit"
+)
+
+//FromAEB returns the remittance of an AEB 19 document. The transactions of a
+//date are grouped in a payment per sequence type and category purpose. AEB
+//files carry no creditor BIC, which must be set before ToSEPA
+func FromAEB(d *aeb19.Document) *Remittance {
+	r := &Remittance{}
+	if ip := d.InitiatingParty; ip != nil {
+		r.InitiatingParty = InitiatingParty{Name: ip.Name, ID: ip.ID}
+		if !ip.CreationDate.IsZero() {
+			r.CreationDateTime = ip.CreationDate.Format("2006-01-02T15:04:05")
+		}
+		r.MessageID = ip.FileID
+	}
+	instrument := "CORE"
+	if d.Variant.IsB2B() {
+		instrument = "B2B"
+	}
+	for _, cp := range d.CreditorPayments {
+		c := cp.Creditor
+		g := &CreditorGroup{Creditor: Creditor{ID: c.ID, Name: c.Name, IBAN: c.Account}}
+		if a := sepadebit.NewUnstructuredAddress(c.Country, c.AddressD1, c.AddressD2, c.AddressD3); !a.IsEmpty() {
+			g.Creditor.Address = a
+		}
+		for _, dp := range cp.DatePayments {
+			type key struct{ sequence, category string }
+			payments := map[key]*Payment{}
+			for _, dt := range dp.DebitTransactions {
+				k := key{dt.Sequence, dt.CategoryCode}
+				p, ok := payments[k]
+				if !ok {
+					p = &Payment{
+						CollectionDate:  Date(dp.Date),
+						SequenceType:    dt.Sequence,
+						LocalInstrument: instrument,
+						CategoryPurpose: dt.CategoryCode,
+					}
+					payments[k] = p
+					g.Payments = append(g.Payments, p)
+				}
+				t := &Transaction{
+					ID:       dt.ID,
+					Amount:   Amount(dt.Amount),
+					Currency: "EUR",
+					Mandate:  Mandate{ID: dt.MandateID, SignatureDate: Date(dt.MandateSignatureDate)},
+					Debtor:   Debtor{Name: dt.Debtor.Name, IBAN: dt.Debtor.Account, BIC: dt.Debtor.Entity},
+					Purpose:  dt.Purpose,
+				}
+				if dt.Concept != "" {
+					t.RemittanceInfo = []string{dt.Concept}
+				}
+				p.Transactions = append(p.Transactions, t)
+			}
+		}
+		r.Creditors = append(r.Creditors, g)
+	}
+	return r
+}
+
+//ToAEB returns the AEB 19.14 document of the remittance, or AEB 19.44 for
+//B2B payments, with its totals. An AEB file holds either CORE or B2B debits,
+//so mixing them is an error. AEB has no room for ultimate parties nor
+//mandate amendments, which are dropped. RF references are written as concept
+//when there is none
+func (r *Remittance) ToAEB() (*aeb19.Document, error) {
+	d := aeb19.NewDocument()
+	d.Variant = aeb19.AEB1914
+	d.InitiatingParty = &aeb19.InitiatingParty{
+		ID:     r.InitiatingParty.ID,
+		Name:   r.InitiatingParty.Name,
+		FileID: r.MessageID,
+	}
+	if r.CreationDateTime != "" {
+		t, err := parseDateTime(r.CreationDateTime)
+		if err != nil {
+			return nil, err
+		}
+		d.InitiatingParty.CreationDate = t
+	}
+	d.TotalRegisterCount = 2 // 01 and 99
+	instrument := ""
+	for _, g := range r.Creditors {
+		c := g.Creditor
+		cp := &aeb19.CreditorPayments{Creditor: aeb19.Creditor{ID: c.ID, Name: c.Name, Account: c.IBAN}}
+		if a := c.Address; a != nil {
+			cp.Creditor.Country = a.Country
+			lines := a.AddressLines
+			if len(lines) == 0 {
+				lines = []string{strings.TrimSpace(a.StreetName + " " + a.BuildingNumber), strings.TrimSpace(a.PostCode + " " + a.TownName), a.CountrySubDivision}
+			}
+			for i, line := range lines {
+				switch i {
+				case 0:
+					cp.Creditor.AddressD1 = line
+				case 1:
+					cp.Creditor.AddressD2 = line
+				case 2:
+					cp.Creditor.AddressD3 = line
+				}
+			}
+		}
+		dates := map[time.Time]*aeb19.DatePayment{}
+		for _, p := range g.Payments {
+			pi := p.LocalInstrument
+			if pi == "" {
+				pi = "CORE"
+			}
+			if instrument != "" && pi != instrument {
+				return nil, fmt.Errorf("Payments with %s and %s debits, an AEB file holds only one of them", instrument, pi)
+			}
+			instrument = pi
+			if pi == "B2B" {
+				d.Variant = aeb19.AEB1944
+			}
+			date := time.Time(p.CollectionDate)
+			dp, ok := dates[date]
+			if !ok {
+				dp = &aeb19.DatePayment{Date: date}
+				dates[date] = dp
+				cp.DatePayments = append(cp.DatePayments, dp)
+			}
+			for _, t := range p.Transactions {
+				dt := &aeb19.DebitTransaction{
+					ID:                   t.ID,
+					MandateID:            t.Mandate.ID,
+					Sequence:             p.SequenceType,
+					CategoryCode:         p.CategoryPurpose,
+					Amount:               int64(t.Amount),
+					MandateSignatureDate: time.Time(t.Mandate.SignatureDate),
+					Debtor:               aeb19.Debtor{Name: t.Debtor.Name, AccountID: "A", Account: t.Debtor.IBAN, Entity: t.Debtor.BIC},
+					Purpose:              t.Purpose,
+					Concept:              strings.Join(t.RemittanceInfo, " "),
+				}
+				if t.CreditorReference != "" && dt.Concept == "" {
+					dt.Concept = t.CreditorReference
+				}
+				dp.DebitTransactions = append(dp.DebitTransactions, dt)
+				dp.TotalAmount += dt.Amount
+				dp.DebitRegisterCount++
+			}
+			// transactions plus the 02 and 04 registers
+			dp.TotalRegisterCount = dp.DebitRegisterCount + 2
+		}
+		for _, dp := range cp.DatePayments {
+			cp.TotalAmount += dp.TotalAmount
+			cp.DebitRegisterCount += dp.DebitRegisterCount
+			cp.TotalRegisterCount += dp.TotalRegisterCount
+		}
+		cp.TotalRegisterCount++ // 05
+		d.CreditorPayments = append(d.CreditorPayments, cp)
+		d.TotalAmount += cp.TotalAmount
+		d.DebitRegisterCount += cp.DebitRegisterCount
+		d.TotalRegisterCount += cp.TotalRegisterCount
+	}
+	return d, nil
+}
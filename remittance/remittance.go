@@ -0,0 +1,185 @@
+//Package remittance is the canonical JSON model of a direct debit
+//remittance, for integrators that do not produce AEB text or pain.008 XML.
+//Amounts are decimal strings ("123.45") or integer cents (12345), and dates
+//ISO 8601, so conversions are not lossy. Schema is its JSON Schema.
+package remittance
+
+import (
+	_ "embed" // JSON Schema
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/apsl/sepakit/sepadebit"
+)
+
+//Schema is the JSON Schema of a Remittance
+//
+//go:embed remittance.schema.json
+var Schema []byte
+
+//Remittance is a direct debit remittance: an initiating party and the
+//payments of one or more creditors
+type Remittance struct {
+	MessageID string `json:"messageId,omitempty"`
+	//CreationDateTime is ISO 8601, e.g. 2013-12-17T17:29:52
+	CreationDateTime string           `json:"creationDateTime,omitempty"`
+	InitiatingParty  InitiatingParty  `json:"initiatingParty"`
+	Creditors        []*CreditorGroup `json:"creditors"`
+}
+
+//InitiatingParty is the party sending the remittance
+type InitiatingParty struct {
+	Name string `json:"name"`
+	ID   string `json:"id"`
+}
+
+//CreditorGroup holds the payments of a creditor
+type CreditorGroup struct {
+	Creditor Creditor   `json:"creditor"`
+	Payments []*Payment `json:"payments"`
+}
+
+//Creditor is the creditor and its account
+type Creditor struct {
+	//ID is the SEPA creditor identifier
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	IBAN string `json:"iban"`
+	//BIC is required by pain.008. convert takes the one of the bank profile
+	//when it is empty
+	BIC              string                   `json:"bic,omitempty"`
+	Address          *sepadebit.PostalAddress `json:"address,omitempty"`
+	UltimateCreditor *sepadebit.Party         `json:"ultimateCreditor,omitempty"`
+	//ChargeBearer defaults to SLEV
+	ChargeBearer string `json:"chargeBearer,omitempty"`
+}
+
+//Payment is a collection date of a creditor
+type Payment struct {
+	ID             string `json:"id,omitempty"`
+	CollectionDate Date   `json:"collectionDate"`
+	//SequenceType is FRST, RCUR, FNAL or OOFF
+	SequenceType string `json:"sequenceType"`
+	//LocalInstrument is CORE (default), B2B or COR1
+	LocalInstrument string         `json:"localInstrument,omitempty"`
+	CategoryPurpose string         `json:"categoryPurpose,omitempty"`
+	Transactions    []*Transaction `json:"transactions"`
+}
+
+//Transaction is a direct debit
+type Transaction struct {
+	//ID is the EndToEndId
+	ID     string `json:"id"`
+	Amount Amount `json:"amount"`
+	//Currency defaults to EUR
+	Currency                    string           `json:"currency,omitempty"`
+	Mandate                     Mandate          `json:"mandate"`
+	Debtor                      Debtor           `json:"debtor"`
+	UltimateCreditor            *sepadebit.Party `json:"ultimateCreditor,omitempty"`
+	UltimateDebtor              *sepadebit.Party `json:"ultimateDebtor,omitempty"`
+	InstructionForCreditorAgent string           `json:"instructionForCreditorAgent,omitempty"`
+	Purpose                     string           `json:"purpose,omitempty"`
+	RemittanceInfo              []string         `json:"remittanceInfo,omitempty"`
+	//CreditorReference is a structured RF creditor reference
+	CreditorReference string `json:"creditorReference,omitempty"`
+}
+
+//Mandate is the mandate of a transaction
+type Mandate struct {
+	ID            string `json:"id"`
+	SignatureDate Date   `json:"signatureDate"`
+	//Amended tells the mandate changed since the previous collection. It is
+	//implied by Amendment
+	Amended             bool                        `json:"amended,omitempty"`
+	Amendment           *sepadebit.MandateAmendment `json:"amendment,omitempty"`
+	ElectronicSignature string                      `json:"electronicSignature,omitempty"`
+	FirstCollectionDate *Date                       `json:"firstCollectionDate,omitempty"`
+	FinalCollectionDate *Date                       `json:"finalCollectionDate,omitempty"`
+	Frequency           string                      `json:"frequency,omitempty"`
+}
+
+//Debtor is the debtor and its account
+type Debtor struct {
+	Name string `json:"name"`
+	IBAN string `json:"iban"`
+	BIC  string `json:"bic,omitempty"`
+}
+
+//Amount is an amount in cents. It is written as a decimal string and read
+//from a decimal string or integer cents
+type Amount int64
+
+var decimalAmount = regexp.MustCompile(`^-?\d+(\.\d{1,2})?$`)
+
+func (a Amount) String() string {
+	return sepadebit.FormatAmount(int64(a))
+}
+
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.String())
+}
+
+func (a *Amount) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		if !decimalAmount.MatchString(s) {
+			return fmt.Errorf("Invalid amount %q, expected a decimal such as \"123.45\"", s)
+		}
+		cents, err := sepadebit.ParseAmount(s)
+		*a = Amount(cents)
+		return err
+	}
+	cents, err := strconv.ParseInt(string(b), 10, 64)
+	if err != nil {
+		return fmt.Errorf("Invalid amount %s, expected a decimal string or integer cents", b)
+	}
+	*a = Amount(cents)
+	return nil
+}
+
+//Date is an ISO 8601 date, written as 2006-01-02. Date times are accepted
+type Date time.Time
+
+func (d Date) String() string {
+	return time.Time(d).Format("2006-01-02")
+}
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Date) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("Invalid date %s", b)
+	}
+	for _, layout := range []string{"2006-01-02", time.RFC3339, "2006-01-02T15:04:05"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			*d = Date(t)
+			return nil
+		}
+	}
+	return fmt.Errorf("Invalid date %q, expected ISO 8601", s)
+}
+
+//Read decodes a JSON Remittance, rejecting unknown fields
+func Read(r io.Reader) (*Remittance, error) {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	rem := &Remittance{}
+	if err := dec.Decode(rem); err != nil {
+		return nil, err
+	}
+	return rem, nil
+}
+
+//Write encodes the remittance as indented JSON
+func (r *Remittance) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
@@ -0,0 +1,181 @@
+package remittance
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/apsl/sepakit/aeb19"
+	"github.com/apsl/sepakit/sepadebit"
+	"golang.org/x/text/encoding/charmap"
+)
+
+func TestSEPARoundTrip(t *testing.T) {
+	golden, err := ioutil.ReadFile("../convert/testdata/input-aeb1914.golden.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := sepadebit.ReadDocument(bytes.NewReader(golden))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := FromSEPA(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var js bytes.Buffer
+	if err := r.Write(&js); err != nil {
+		t.Fatal(err)
+	}
+	r, err = Read(&js)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err = r.ToSEPA(sepadebit.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := doc.WriteLatin1(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Bytes(), golden) {
+		t.Errorf("Round trip differs:\n%s", out.String())
+	}
+}
+
+func TestAmount(t *testing.T) {
+	var tx struct{ A, B Amount }
+	if err := json.Unmarshal([]byte(`{"A": "1234.5", "B": 123450}`), &tx); err != nil {
+		t.Fatal(err)
+	}
+	if tx.A != 123450 || tx.B != 123450 {
+		t.Errorf("Unexpected amounts %d %d", tx.A, tx.B)
+	}
+	b, _ := json.Marshal(tx)
+	if string(b) != `{"A":"1234.50","B":"1234.50"}` {
+		t.Errorf("Unexpected JSON %s", b)
+	}
+	for _, invalid := range []string{`"1,5"`, `12.5`, `"1.234"`} {
+		var a Amount
+		if err := json.Unmarshal([]byte(invalid), &a); err == nil {
+			t.Errorf("Expected error for amount %s", invalid)
+		}
+	}
+}
+
+func TestToSEPAErrors(t *testing.T) {
+	valid := `{"initiatingParty": {"name": "E", "id": "ES18000B07123456"}, "creditors": [{"creditor": {"id": "ES18000B07123456", "name": "E", "iban": "ES9121000418450200051332", "bic": "CAIXESBBXXX"},
+		"payments": [{"collectionDate": "2024-01-10", "sequenceType": "RCUR", "transactions": [{"id": "T1", "amount": 1000,
+		"mandate": {"id": "M1", "signatureDate": "2012-03-15"}, "debtor": {"name": "D", "iban": "ES7620770024003102575766"}, "purpose": "GDDS"}]}]}]}`
+	r, err := Read(strings.NewReader(valid))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := r.ToSEPA(sepadebit.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.CtrlSum != "10.00" || doc.Payments[0].LocalInstrument != "CORE" {
+		t.Errorf("Unexpected document %+v", doc)
+	}
+	for _, invalid := range []string{
+		strings.Replace(valid, `"RCUR"`, `"XXXX"`, 1),
+		strings.Replace(valid, `"GDDS"`, `"XXXX"`, 1),
+		strings.Replace(valid, `"amount": 1000`, `"amount": 0`, 1),
+		strings.Replace(valid, `"signatureDate"`, `"signed"`, 1),
+		strings.Replace(valid, `, "bic": "CAIXESBBXXX"`, ``, 1),
+		strings.Replace(valid, `"sequenceType": "RCUR"`, `"sequenceType": "RCUR", "localInstrument": "XXXX"`, 1),
+	} {
+		r, err := Read(strings.NewReader(invalid))
+		if err == nil {
+			_, err = r.ToSEPA(sepadebit.Options{})
+		}
+		if err == nil {
+			t.Errorf("Expected error for %s", invalid)
+		}
+	}
+}
+
+func TestAEBRoundTrip(t *testing.T) {
+	f, err := os.Open("../input-aeb1914.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	doc, err := aeb19.NewParser().Parse(charmap.ISO8859_1.NewDecoder().Reader(f))
+	if err != nil {
+		t.Fatal(err)
+	}
+	back, err := FromAEB(doc).ToAEB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if back.TotalAmount != doc.TotalAmount || back.DebitRegisterCount != doc.DebitRegisterCount || back.TotalRegisterCount != doc.TotalRegisterCount {
+		t.Errorf("Totals differ: %s, expected %s", back, doc)
+	}
+	dt, want := back.CreditorPayments[0].DatePayments[0].DebitTransactions[0], doc.CreditorPayments[0].DatePayments[0].DebitTransactions[0]
+	if dt.String() != want.String() || dt.MandateID != want.MandateID || dt.Debtor.Account != want.Debtor.Account {
+		t.Errorf("Transaction differs: %s, expected %s", dt, want)
+	}
+	if _, err := FromAEB(doc).ToSEPA(sepadebit.Options{}); err == nil {
+		t.Error("Expected error for creditor without BIC")
+	}
+}
+
+func TestToAEB(t *testing.T) {
+	f, err := os.Open("../input-aeb1914.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	doc, err := aeb19.NewParser().Parse(charmap.ISO8859_1.NewDecoder().Reader(f))
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden, err := ioutil.ReadFile("../convert/testdata/input-aeb1914.golden.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sepa, err := sepadebit.ReadDocument(bytes.NewReader(golden))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := FromSEPA(sepa)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aeb, err := r.ToAEB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := aeb.Write(&out); err != nil {
+		t.Fatal(err)
+	}
+	back, err := aeb19.NewParser().Parse(charmap.ISO8859_1.NewDecoder().Reader(&out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if back.Variant != aeb19.AEB1914 || back.TotalAmount != doc.TotalAmount || back.DebitRegisterCount != doc.DebitRegisterCount || back.TotalRegisterCount != doc.TotalRegisterCount {
+		t.Errorf("Totals differ: %s, expected %s", back, doc)
+	}
+	dt, want := back.CreditorPayments[0].DatePayments[0].DebitTransactions[0], doc.CreditorPayments[0].DatePayments[0].DebitTransactions[0]
+	if dt.String() != want.String() || dt.MandateID != want.MandateID || dt.Debtor.Account != want.Debtor.Account || dt.Sequence != want.Sequence {
+		t.Errorf("Transaction differs: %s, expected %s", dt, want)
+	}
+
+	r.Creditors[0].Payments[0].LocalInstrument = "B2B"
+	if aeb, err = r.ToAEB(); err != nil || aeb.Variant != aeb19.AEB1944 {
+		t.Errorf("Expected an AEB 19.44 document, got %v %v", aeb, err)
+	}
+	p := *r.Creditors[0].Payments[0]
+	p.LocalInstrument = "CORE"
+	r.Creditors[0].Payments = append(r.Creditors[0].Payments, &p)
+	if _, err := r.ToAEB(); err == nil {
+		t.Error("Expected error for CORE and B2B payments in a file")
+	}
+}
@@ -0,0 +1,240 @@
+package remittance
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/apsl/sepakit/sepadebit"
+)
+
+//FromSEPA returns the remittance of a pain.008 document. Consecutive
+//payments of the same creditor are grouped
+func FromSEPA(d *sepadebit.Document) (*Remittance, error) {
+	r := &Remittance{
+		MessageID:        d.MsgID,
+		CreationDateTime: d.CreationDateTime,
+		InitiatingParty:  InitiatingParty{Name: d.InitiatingParty.Name, ID: d.InitiatingParty.ID},
+	}
+	var group *CreditorGroup
+	for _, p := range d.Payments {
+		var c Creditor
+		if p.Creditor != nil {
+			c = Creditor{
+				ID:               p.Creditor.ID,
+				Name:             p.Creditor.Name,
+				IBAN:             p.Creditor.IBAN,
+				BIC:              p.Creditor.BIC,
+				Address:          p.Creditor.PostalAddress,
+				UltimateCreditor: p.Creditor.UltimateCreditor,
+				ChargeBearer:     p.Creditor.ChargeBearer,
+			}
+		}
+		if group == nil || !reflect.DeepEqual(group.Creditor, c) {
+			group = &CreditorGroup{Creditor: c}
+			r.Creditors = append(r.Creditors, group)
+		}
+		date, err := time.Parse("2006-01-02", p.RequestedCollectionDate)
+		if err != nil {
+			return nil, fmt.Errorf("Payment %s: invalid collection date %s", p.ID, p.RequestedCollectionDate)
+		}
+		payment := &Payment{
+			ID:              p.ID,
+			CollectionDate:  Date(date),
+			SequenceType:    p.SequenceType,
+			LocalInstrument: p.LocalInstrument,
+		}
+		if p.CategoryPurpose != nil {
+			payment.CategoryPurpose = p.CategoryPurpose.Code
+		}
+		for _, t := range p.Transactions {
+			amount, err := sepadebit.ParseAmount(t.Amount.Amount)
+			if err != nil {
+				return nil, fmt.Errorf("Transaction %s: %s", t.ID, err)
+			}
+			m := t.Mandate
+			tx := &Transaction{
+				ID:       t.ID,
+				Amount:   Amount(amount),
+				Currency: t.Amount.Currency,
+				Mandate: Mandate{
+					ID:                  m.ID,
+					SignatureDate:       Date(m.SignatureDate),
+					Amended:             m.AmendmentIndicator,
+					Amendment:           m.Amendment,
+					ElectronicSignature: m.ElectronicSignature,
+					FirstCollectionDate: (*Date)(m.FirstCollectionDate),
+					FinalCollectionDate: (*Date)(m.FinalCollectionDate),
+					Frequency:           m.Frequency,
+				},
+				Debtor:                      Debtor{Name: t.Debtor.Name, IBAN: t.Debtor.IBAN, BIC: t.Debtor.BIC},
+				UltimateCreditor:            t.UltimateCreditor,
+				UltimateDebtor:              t.UltimateDebtor,
+				InstructionForCreditorAgent: t.InstructionForCreditorAgent,
+				RemittanceInfo:              t.RemittanceInfo,
+			}
+			if t.Purpose != nil {
+				tx.Purpose = t.Purpose.Code
+			}
+			if t.CreditorReference != nil {
+				tx.CreditorReference = t.CreditorReference.Ref
+			}
+			payment.Transactions = append(payment.Transactions, tx)
+		}
+		group.Payments = append(group.Payments, payment)
+	}
+	return r, nil
+}
+
+//ToSEPA returns the pain.008 document of the remittance, checking its
+//required fields and codes. Payments without ID get one from opts. pain.008
+//requires the BIC of the creditor agent: convert fills it from the bank
+//profile for creditors without it
+func (r *Remittance) ToSEPA(opts sepadebit.Options) (*sepadebit.Document, error) {
+	d := sepadebit.NewDocumentWithOptions(opts)
+	if r.MessageID != "" {
+		d.MsgID = r.MessageID
+	}
+	if r.CreationDateTime != "" {
+		t, err := parseDateTime(r.CreationDateTime)
+		if err != nil {
+			return nil, err
+		}
+		d.SetCreationDateTime(t)
+	}
+	if r.InitiatingParty.Name == "" {
+		return nil, fmt.Errorf("initiatingParty: missing name")
+	}
+	d.SetInitiatingParty(r.InitiatingParty.Name, r.InitiatingParty.ID)
+	if len(r.Creditors) == 0 {
+		return nil, fmt.Errorf("creditors: no creditors")
+	}
+	for i, g := range r.Creditors {
+		path := fmt.Sprintf("creditors[%d]", i)
+		c := g.Creditor
+		if c.ID == "" || c.Name == "" || c.IBAN == "" {
+			return nil, fmt.Errorf("%s.creditor: id, name and iban are required", path)
+		}
+		if c.BIC == "" {
+			return nil, fmt.Errorf("%s.creditor: missing bic", path)
+		}
+		if c.ChargeBearer == "" {
+			c.ChargeBearer = "SLEV"
+		}
+		for j, p := range g.Payments {
+			path := fmt.Sprintf("%s.payments[%d]", path, j)
+			if time.Time(p.CollectionDate).IsZero() {
+				return nil, fmt.Errorf("%s: missing collectionDate", path)
+			}
+			payment := &sepadebit.Payment{
+				ID:              p.ID,
+				Method:          "DD",
+				ServiceLevel:    "SEPA",
+				LocalInstrument: p.LocalInstrument,
+				SequenceType:    p.SequenceType,
+				Creditor: &sepadebit.Creditor{
+					ID:               c.ID,
+					Name:             c.Name,
+					IBAN:             c.IBAN,
+					BIC:              c.BIC,
+					PostalAddress:    c.Address,
+					UltimateCreditor: c.UltimateCreditor,
+					ChargeBearer:     c.ChargeBearer,
+					SchemeName:       "SEPA",
+				},
+				RequestedCollectionDate: p.CollectionDate.String(),
+			}
+			if payment.ID == "" {
+				payment.ID = d.NewID("rem" + time.Time(p.CollectionDate).Format("20060102"))
+			}
+			switch payment.LocalInstrument {
+			case "":
+				payment.LocalInstrument = "CORE"
+			case "CORE", "B2B", "COR1":
+			default:
+				return nil, fmt.Errorf("%s: invalid localInstrument %q", path, p.LocalInstrument)
+			}
+			switch p.SequenceType {
+			case "FRST", "RCUR", "FNAL", "OOFF":
+			default:
+				return nil, fmt.Errorf("%s: invalid sequenceType %q", path, p.SequenceType)
+			}
+			if err := payment.SetCategoryPurpose(p.CategoryPurpose); err != nil {
+				return nil, fmt.Errorf("%s: %s", path, err)
+			}
+			for k, t := range p.Transactions {
+				path := fmt.Sprintf("%s.transactions[%d]", path, k)
+				tx, err := t.toSEPA()
+				if err != nil {
+					return nil, fmt.Errorf("%s: %s", path, err)
+				}
+				payment.Transactions = append(payment.Transactions, tx)
+			}
+			d.AddPayment(payment)
+		}
+	}
+	if err := d.UpdateTotals(); err != nil {
+		return nil, err
+	}
+	if err := d.CheckAmendments(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (t *Transaction) toSEPA() (sepadebit.Transaction, error) {
+	if t.ID == "" || t.Mandate.ID == "" || t.Debtor.IBAN == "" || t.Debtor.Name == "" {
+		return sepadebit.Transaction{}, fmt.Errorf("id, mandate.id, debtor.name and debtor.iban are required")
+	}
+	if t.Amount <= 0 {
+		return sepadebit.Transaction{}, fmt.Errorf("amount must be positive")
+	}
+	if time.Time(t.Mandate.SignatureDate).IsZero() {
+		return sepadebit.Transaction{}, fmt.Errorf("missing mandate.signatureDate")
+	}
+	currency := t.Currency
+	if currency == "" {
+		currency = "EUR"
+	}
+	m := t.Mandate
+	tx := sepadebit.Transaction{
+		ID:     t.ID,
+		Amount: sepadebit.TAmount{Amount: t.Amount.String(), Currency: currency},
+		Mandate: sepadebit.MandateInfo{
+			ID:                  m.ID,
+			SignatureDate:       sepadebit.Date(m.SignatureDate),
+			ElectronicSignature: m.ElectronicSignature,
+			FirstCollectionDate: (*sepadebit.Date)(m.FirstCollectionDate),
+			FinalCollectionDate: (*sepadebit.Date)(m.FinalCollectionDate),
+			Frequency:           m.Frequency,
+		},
+		Debtor:                      sepadebit.Debtor{Name: t.Debtor.Name, IBAN: t.Debtor.IBAN, BIC: t.Debtor.BIC},
+		UltimateCreditor:            t.UltimateCreditor,
+		UltimateDebtor:              t.UltimateDebtor,
+		InstructionForCreditorAgent: t.InstructionForCreditorAgent,
+		RemittanceInfo:              t.RemittanceInfo,
+	}
+	tx.Mandate.SetAmendment(m.Amendment)
+	if m.Amended {
+		tx.Mandate.AmendmentIndicator = true
+	}
+	if err := tx.SetPurpose(t.Purpose); err != nil {
+		return tx, err
+	}
+	if t.CreditorReference != "" {
+		if err := tx.SetCreditorReference(t.CreditorReference); err != nil {
+			return tx, err
+		}
+	}
+	return tx, nil
+}
+
+//parseDateTime parses ISO 8601 date times, with or without time zone
+func parseDateTime(s string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("Invalid creationDateTime %q, expected ISO 8601", s)
+}
@@ -0,0 +1,87 @@
+//Package rfref generates and validates ISO 11649 structured creditor
+//references (RF references), e.g. RF18539007547034
+package rfref
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+//MaxRefLength is the maximum length of the reference without RF and check digits
+const MaxRefLength = 21
+
+//Generate returns the RF reference for ref, computing its check digits.
+//Spaces are removed and letters uppercased
+func Generate(ref string) (string, error) {
+	ref = normalize(ref)
+	if ref == "" {
+		return "", fmt.Errorf("Empty creditor reference")
+	}
+	if len(ref) > MaxRefLength {
+		return "", fmt.Errorf("Creditor reference %s longer than %d characters", ref, MaxRefLength)
+	}
+	if !isAlphanumeric(ref) {
+		return "", fmt.Errorf("Creditor reference %s has not alphanumeric characters", ref)
+	}
+	check := 98 - mod97(ref+"RF00")
+	return fmt.Sprintf("RF%02d%s", check, ref), nil
+}
+
+//Validate checks the format and check digits of an RF reference
+func Validate(rf string) error {
+	rf = normalize(rf)
+	if len(rf) < 5 || len(rf) > MaxRefLength+4 || !strings.HasPrefix(rf, "RF") {
+		return fmt.Errorf("Invalid RF creditor reference %s", rf)
+	}
+	if !isAlphanumeric(rf) || rf[2] < '0' || rf[2] > '9' || rf[3] < '0' || rf[3] > '9' {
+		return fmt.Errorf("Invalid RF creditor reference %s", rf)
+	}
+	if mod97(rf[4:]+rf[:4]) != 1 {
+		return fmt.Errorf("Wrong check digits on RF creditor reference %s", rf)
+	}
+	return nil
+}
+
+//IsValid reports whether rf is a valid RF reference
+func IsValid(rf string) bool {
+	return Validate(rf) == nil
+}
+
+//Format returns rf in groups of four characters, as printed on invoices
+func Format(rf string) string {
+	rf = normalize(rf)
+	var groups []string
+	for len(rf) > 4 {
+		groups = append(groups, rf[:4])
+		rf = rf[4:]
+	}
+	return strings.Join(append(groups, rf), " ")
+}
+
+func normalize(s string) string {
+	return strings.ToUpper(strings.Replace(s, " ", "", -1))
+}
+
+func isAlphanumeric(s string) bool {
+	for _, r := range s {
+		if !(r >= '0' && r <= '9' || r >= 'A' && r <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+//mod97 converts letters to numbers (A=10 ... Z=35) and returns the number mod 97
+func mod97(s string) int {
+	var digits strings.Builder
+	for _, r := range s {
+		if r >= 'A' && r <= 'Z' {
+			fmt.Fprintf(&digits, "%d", r-'A'+10)
+		} else {
+			digits.WriteRune(r)
+		}
+	}
+	n, _ := new(big.Int).SetString(digits.String(), 10)
+	return int(new(big.Int).Mod(n, big.NewInt(97)).Int64())
+}
@@ -0,0 +1,34 @@
+package rfref
+
+import "testing"
+
+func TestGenerate(t *testing.T) {
+	rf, err := Generate("539007547034")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rf != "RF18539007547034" {
+		t.Errorf("Generate = %s, want RF18539007547034", rf)
+	}
+	if _, err := Generate("THIS REFERENCE IS FAR TOO LONG"); err == nil {
+		t.Error("Expected error for long reference")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := []string{"RF18539007547034", "RF18 5390 0754 7034", "RF712348231"}
+	for _, rf := range valid {
+		if err := Validate(rf); err != nil {
+			t.Error(err)
+		}
+	}
+	invalid := []string{"RF19539007547034", "RF1", "XX18539007547034", "RF18-539007547034"}
+	for _, rf := range invalid {
+		if IsValid(rf) {
+			t.Errorf("%s should not be valid", rf)
+		}
+	}
+	if f := Format("RF18539007547034"); f != "RF18 5390 0754 7034" {
+		t.Errorf("Format = %s", f)
+	}
+}
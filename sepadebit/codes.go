@@ -0,0 +1,81 @@
+package sepadebit
+
+import (
+	_ "embed" // code lists
+	"fmt"
+	"strings"
+)
+
+//go:embed codes/ExternalPurpose1Code.txt
+var purposeCodesList string
+
+//go:embed codes/ExternalCategoryPurpose1Code.txt
+var categoryPurposeCodesList string
+
+var (
+	purposeCodes         = parseCodes(purposeCodesList)
+	categoryPurposeCodes = parseCodes(categoryPurposeCodesList)
+)
+
+//Code is an ISO external code element (Cd)
+type Code struct {
+	Code string `xml:"Cd"`
+}
+
+//parseCodes reads a code list, one code per line, ignoring # comments
+func parseCodes(list string) map[string]bool {
+	codes := map[string]bool{}
+	for _, line := range strings.Split(list, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			codes[line] = true
+		}
+	}
+	return codes
+}
+
+//IsPurposeCode reports whether code is in the ISO ExternalPurpose1Code list
+func IsPurposeCode(code string) bool {
+	return purposeCodes[code]
+}
+
+//IsCategoryPurposeCode reports whether code is in the ISO
+//ExternalCategoryPurpose1Code list
+func IsCategoryPurposeCode(code string) bool {
+	return categoryPurposeCodes[code]
+}
+
+//SetPurpose sets the transaction purpose (Purp>Cd). An empty code removes it
+func (t *Transaction) SetPurpose(code string) error {
+	if code == "" {
+		t.Purpose = nil
+		return nil
+	}
+	if !IsPurposeCode(code) {
+		return fmt.Errorf("Unknown purpose code %s", code)
+	}
+	t.Purpose = &Code{Code: code}
+	return nil
+}
+
+//SetCategoryPurpose sets the payment category purpose (PmtTpInf>CtgyPurp>Cd).
+//An empty code removes it
+func (p *Payment) SetCategoryPurpose(code string) error {
+	if code == "" {
+		p.CategoryPurpose = nil
+		return nil
+	}
+	if !IsCategoryPurposeCode(code) {
+		return fmt.Errorf("Unknown category purpose code %s", code)
+	}
+	p.CategoryPurpose = &Code{Code: code}
+	return nil
+}
+
+//code returns the code of c or "" when c is nil
+func (c *Code) code() string {
+	if c == nil {
+		return ""
+	}
+	return c.Code
+}
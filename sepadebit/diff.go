@@ -0,0 +1,235 @@
+package sepadebit
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+//FieldChange is a modified value of a transaction
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+//TransactionChange is an added, removed or modified debit transaction
+type TransactionChange struct {
+	Kind       string        `json:"kind"` // added, removed or modified
+	EndToEndID string        `json:"endToEndId"`
+	MandateID  string        `json:"mandateId"`
+	CreditorID string        `json:"creditorId"`
+	Date       string        `json:"date"`
+	Fields     []FieldChange `json:"fields,omitempty"`
+}
+
+//GroupChange reports changed totals of a creditor and collection date group
+type GroupChange struct {
+	CreditorID string `json:"creditorId"`
+	Date       string `json:"date"`
+	OldNb      int    `json:"oldNbOfTxs"`
+	NewNb      int    `json:"newNbOfTxs"`
+	OldCtrlSum string `json:"oldCtrlSum"`
+	NewCtrlSum string `json:"newCtrlSum"`
+}
+
+//DocumentDiff holds the differences between two documents
+type DocumentDiff struct {
+	Transactions []TransactionChange `json:"transactions"`
+	Groups       []GroupChange       `json:"groups"`
+}
+
+//diffEntry is a transaction with the payment data needed to compare it
+type diffEntry struct {
+	t          *Transaction
+	creditorID string
+	date       string
+	matched    bool
+}
+
+//Diff compares two documents. Transactions are matched by EndToEndId and,
+//failing that, by MandateId.
+func Diff(oldDoc, newDoc *Document) *DocumentDiff {
+	d := &DocumentDiff{}
+	oldEntries := diffEntries(oldDoc)
+	newEntries := diffEntries(newDoc)
+
+	byID := map[string]*diffEntry{}
+	for _, e := range newEntries {
+		byID[e.t.ID] = e
+	}
+	for _, o := range oldEntries {
+		if n, ok := byID[o.t.ID]; ok && !n.matched {
+			d.compare(o, n)
+		}
+	}
+	byMandate := map[string][]*diffEntry{}
+	for _, e := range newEntries {
+		if !e.matched && e.t.Mandate.ID != "" {
+			byMandate[e.t.Mandate.ID] = append(byMandate[e.t.Mandate.ID], e)
+		}
+	}
+	for _, o := range oldEntries {
+		if o.matched {
+			continue
+		}
+		for _, n := range byMandate[o.t.Mandate.ID] {
+			if !n.matched {
+				d.compare(o, n)
+				break
+			}
+		}
+	}
+	for _, o := range oldEntries {
+		if !o.matched {
+			d.Transactions = append(d.Transactions, o.change("removed"))
+		}
+	}
+	for _, n := range newEntries {
+		if !n.matched {
+			d.Transactions = append(d.Transactions, n.change("added"))
+		}
+	}
+	d.diffGroups(oldDoc, newDoc)
+	return d
+}
+
+//Empty reports whether both documents are equivalent
+func (d *DocumentDiff) Empty() bool {
+	return len(d.Transactions) == 0 && len(d.Groups) == 0
+}
+
+//WriteText writes a human readable report of the differences
+func (d *DocumentDiff) WriteText(w io.Writer) error {
+	for _, c := range d.Transactions {
+		_, err := fmt.Fprintf(w, "%s %s (mandate %s, creditor %s, date %s)\n", c.Kind, c.EndToEndID, c.MandateID, c.CreditorID, c.Date)
+		if err != nil {
+			return err
+		}
+		for _, f := range c.Fields {
+			fmt.Fprintf(w, "    %s: %s -> %s\n", f.Field, f.Old, f.New)
+		}
+	}
+	for _, g := range d.Groups {
+		_, err := fmt.Fprintf(w, "totals creditor %s date %s: %d txs %s -> %d txs %s\n", g.CreditorID, g.Date, g.OldNb, g.OldCtrlSum, g.NewNb, g.NewCtrlSum)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *DocumentDiff) compare(o, n *diffEntry) {
+	o.matched = true
+	n.matched = true
+	c := n.change("modified")
+	add := func(field, ov, nv string) {
+		if ov != nv {
+			c.Fields = append(c.Fields, FieldChange{Field: field, Old: ov, New: nv})
+		}
+	}
+	add("EndToEndId", o.t.ID, n.t.ID)
+	add("MandateId", o.t.Mandate.ID, n.t.Mandate.ID)
+	add("SignatureDate", o.t.Mandate.SignatureDate.String(), n.t.Mandate.SignatureDate.String())
+	add("Amount", o.t.Amount.Amount, n.t.Amount.Amount)
+	add("IBAN", o.t.Debtor.IBAN, n.t.Debtor.IBAN)
+	add("Date", o.date, n.date)
+	add("Concept", strings.Join(o.t.RemittanceInfo, " "), strings.Join(n.t.RemittanceInfo, " "))
+	add("Reference", o.t.creditorRef(), n.t.creditorRef())
+	add("Purpose", o.t.Purpose.code(), n.t.Purpose.code())
+	add("Creditor", o.creditorID, n.creditorID)
+	if len(c.Fields) > 0 {
+		d.Transactions = append(d.Transactions, c)
+	}
+}
+
+func (e *diffEntry) change(kind string) TransactionChange {
+	return TransactionChange{
+		Kind:       kind,
+		EndToEndID: e.t.ID,
+		MandateID:  e.t.Mandate.ID,
+		CreditorID: e.creditorID,
+		Date:       e.date,
+	}
+}
+
+func diffEntries(doc *Document) []*diffEntry {
+	var entries []*diffEntry
+	for _, p := range doc.Payments {
+		creditorID := ""
+		if p.Creditor != nil {
+			creditorID = p.Creditor.ID
+		}
+		for i := range p.Transactions {
+			entries = append(entries, &diffEntry{
+				t:          &p.Transactions[i],
+				creditorID: creditorID,
+				date:       p.RequestedCollectionDate,
+			})
+		}
+	}
+	return entries
+}
+
+type groupTotals struct {
+	nb  int
+	sum int64
+}
+
+func (d *DocumentDiff) diffGroups(oldDoc, newDoc *Document) {
+	oldGroups := groupsOf(oldDoc)
+	newGroups := groupsOf(newDoc)
+	keys := map[[2]string]bool{}
+	for k := range oldGroups {
+		keys[k] = true
+	}
+	for k := range newGroups {
+		keys[k] = true
+	}
+	var sorted [][2]string
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i][0] != sorted[j][0] {
+			return sorted[i][0] < sorted[j][0]
+		}
+		return sorted[i][1] < sorted[j][1]
+	})
+	for _, k := range sorted {
+		o, n := oldGroups[k], newGroups[k]
+		if o != n {
+			d.Groups = append(d.Groups, GroupChange{
+				CreditorID: k[0],
+				Date:       k[1],
+				OldNb:      o.nb,
+				NewNb:      n.nb,
+				OldCtrlSum: FormatAmount(o.sum),
+				NewCtrlSum: FormatAmount(n.sum),
+			})
+		}
+	}
+}
+
+//groupsOf sums transactions by creditor ID and collection date
+func groupsOf(doc *Document) map[[2]string]groupTotals {
+	groups := map[[2]string]groupTotals{}
+	for _, e := range diffEntries(doc) {
+		k := [2]string{e.creditorID, e.date}
+		g := groups[k]
+		g.nb++
+		amount, _ := ParseAmount(e.t.Amount.Amount)
+		g.sum += amount
+		groups[k] = g
+	}
+	return groups
+}
+
+//creditorRef returns the structured creditor reference or ""
+func (t *Transaction) creditorRef() string {
+	if t.CreditorReference == nil {
+		return ""
+	}
+	return t.CreditorReference.Ref
+}
@@ -0,0 +1,74 @@
+package sepadebit
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		edit   func(d *Document)
+		kinds  []string
+		fields []string
+		groups int
+	}{
+		{"same", func(d *Document) {}, nil, nil, 0},
+		{"added", func(d *Document) {
+			p := d.Payments[1]
+			p.Transactions = append(p.Transactions, Transaction{ID: "new", Amount: TAmount{Amount: "1.00", Currency: "EUR"}})
+		}, []string{"added"}, nil, 1},
+		{"removed", func(d *Document) {
+			d.Payments[0].Transactions = d.Payments[0].Transactions[:2]
+		}, []string{"removed"}, nil, 1},
+		{"amount", func(d *Document) {
+			d.Payments[0].Transactions[0].Amount.Amount = "11.00"
+		}, []string{"modified"}, []string{"Amount"}, 1},
+		{"same mandate", func(d *Document) {
+			tx := &d.Payments[1].Transactions[0]
+			tx.ID = "renamed"
+			tx.RemittanceInfo = []string{"OTRO CONCEPTO"}
+		}, []string{"modified"}, []string{"EndToEndId", "Concept"}, 0},
+		{"date", func(d *Document) {
+			d.Payments[1].RequestedCollectionDate = "2013-12-27"
+		}, []string{"modified"}, []string{"Date"}, 2},
+	} {
+		oldDoc, newDoc := testDocument(), testDocument()
+		for i := range oldDoc.Payments {
+			for j := range oldDoc.Payments[i].Transactions {
+				oldDoc.Payments[i].Transactions[j].Mandate.ID = oldDoc.Payments[i].Transactions[j].ID
+				newDoc.Payments[i].Transactions[j].Mandate.ID = newDoc.Payments[i].Transactions[j].ID
+			}
+			oldDoc.Payments[i].RequestedCollectionDate = "2013-12-20"
+			newDoc.Payments[i].RequestedCollectionDate = "2013-12-20"
+		}
+		c.edit(newDoc)
+		d := Diff(oldDoc, newDoc)
+		var kinds, fields []string
+		for _, tc := range d.Transactions {
+			kinds = append(kinds, tc.Kind)
+			for _, f := range tc.Fields {
+				fields = append(fields, f.Field)
+			}
+		}
+		if !reflect.DeepEqual(kinds, c.kinds) || !reflect.DeepEqual(fields, c.fields) {
+			t.Errorf("%s: got changes %v %v, expected %v %v", c.name, kinds, fields, c.kinds, c.fields)
+		}
+		if len(d.Groups) != c.groups {
+			t.Errorf("%s: got group changes %+v, expected %d", c.name, d.Groups, c.groups)
+		}
+		if d.Empty() != (c.kinds == nil) {
+			t.Errorf("%s: Empty() = %v", c.name, d.Empty())
+		}
+	}
+}
+
+func TestDiffTotals(t *testing.T) {
+	oldDoc, newDoc := testDocument(), testDocument()
+	newDoc.Payments[0].Transactions[0].Amount.Amount = "15.50"
+	d := Diff(oldDoc, newDoc)
+	want := []GroupChange{{CreditorID: "ES08000E77846772", OldNb: 4, NewNb: 4, OldCtrlSum: "65.50", NewCtrlSum: "71.00"}}
+	if !reflect.DeepEqual(d.Groups, want) {
+		t.Errorf("Got group changes %+v, expected %+v", d.Groups, want)
+	}
+}
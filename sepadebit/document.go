@@ -1,12 +1,14 @@
 package sepadebit
 
 import (
-	"crypto/rand"
 	"encoding/xml"
 	"fmt"
 	"io"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/apsl/sepakit/rfref"
 	"golang.org/x/text/encoding/charmap"
 )
 
@@ -21,6 +23,9 @@ type Document struct {
 	CtrlSum          string          `xml:"CstmrDrctDbtInitn>GrpHdr>CtrlSum"`
 	InitiatingParty  InitiatingParty `xml:"CstmrDrctDbtInitn>GrpHdr>InitgPty"`
 	Payments         []*Payment      `xml:"CstmrDrctDbtInitn>PmtInf"`
+	opts             Options
+	//defaults fills the unset opts once, for documents built as literals
+	defaults sync.Once
 }
 
 //InitiatingParty is the Initiating Party
@@ -31,18 +36,76 @@ type InitiatingParty struct {
 }
 
 type Creditor struct {
-	Name          string        `xml:"Cdtr>Nm"`
-	PostalAddress PostalAddress `xml:"Cdtr>PstlAdr,omitempty"`
-	IBAN          string        `xml:"CdtrAcct>Id>IBAN"`
-	BIC           string        `xml:"CdtrAgt>FinInstnId>BIC"`
-	ChargeBearer  string        `xml:"ChrgBr"`
-	ID            string        `xml:"CdtrSchmeId>Id>PrvtId>Othr>Id"`
-	SchemeName    string        `xml:"CdtrSchmeId>Id>PrvtId>Othr>SchmeNm>Prtry"`
+	Name          string         `xml:"Cdtr>Nm"`
+	PostalAddress *PostalAddress `xml:"Cdtr>PstlAdr,omitempty"`
+	IBAN          string         `xml:"CdtrAcct>Id>IBAN"`
+	BIC           string         `xml:"CdtrAgt>FinInstnId>BIC"`
+	//UltimateCreditor is the party on whose behalf the creditor collects,
+	//for every transaction of the payment
+	UltimateCreditor *Party `xml:"UltmtCdtr,omitempty"`
+	ChargeBearer     string `xml:"ChrgBr"`
+	ID               string `xml:"CdtrSchmeId>Id>PrvtId>Othr>Id"`
+	SchemeName       string `xml:"CdtrSchmeId>Id>PrvtId>Othr>SchmeNm>Prtry"`
 }
 
+//PostalAddress is a postal address, either structured (street, post code,
+//town...) or unstructured (address lines), or a mix of both
 type PostalAddress struct {
-	Address [2]string `xml:"Adrline,omitempty"`
-	Country string    `xml:"Ctry,omitempty"`
+	StreetName         string   `xml:"StrtNm,omitempty" json:"streetName,omitempty"`
+	BuildingNumber     string   `xml:"BldgNb,omitempty" json:"buildingNumber,omitempty"`
+	PostCode           string   `xml:"PstCd,omitempty" json:"postCode,omitempty"`
+	TownName           string   `xml:"TwnNm,omitempty" json:"townName,omitempty"`
+	CountrySubDivision string   `xml:"CtrySubDvsn,omitempty" json:"countrySubDivision,omitempty"`
+	Country            string   `xml:"Ctry,omitempty" json:"country,omitempty"`
+	AddressLines       []string `xml:"AdrLine,omitempty" json:"addressLines,omitempty"`
+}
+
+//MaxAddressLines is the number of AdrLine elements allowed by the EPC
+//implementation guidelines
+const MaxAddressLines = 2
+
+//NewUnstructuredAddress returns an address with the non empty lines. Lines
+//past the first are joined in the second, as only MaxAddressLines are allowed
+func NewUnstructuredAddress(country string, lines ...string) *PostalAddress {
+	a := &PostalAddress{Country: country}
+	for _, line := range lines {
+		if line == "" {
+			continue
+		}
+		if len(a.AddressLines) == MaxAddressLines {
+			a.AddressLines[MaxAddressLines-1] += " " + line
+			continue
+		}
+		a.AddressLines = append(a.AddressLines, line)
+	}
+	return a
+}
+
+//IsEmpty reports whether the address has no data
+func (a *PostalAddress) IsEmpty() bool {
+	return a == nil || a.StreetName == "" && a.BuildingNumber == "" && a.PostCode == "" &&
+		a.TownName == "" && a.CountrySubDivision == "" && a.Country == "" && len(a.AddressLines) == 0
+}
+
+//addressField is a text element of an address
+type addressField struct {
+	element string
+	value   *string
+}
+
+//textFields returns the text elements of the address in schema order
+func (a *PostalAddress) textFields() []addressField {
+	fields := []addressField{
+		{"StrtNm", &a.StreetName},
+		{"BldgNb", &a.BuildingNumber},
+		{"PstCd", &a.PostCode},
+		{"TwnNm", &a.TownName},
+		{"CtrySubDvsn", &a.CountrySubDivision},
+	}
+	for i := range a.AddressLines {
+		fields = append(fields, addressField{"AdrLine", &a.AddressLines[i]})
+	}
+	return fields
 }
 
 func NewCreditor() *Creditor {
@@ -60,6 +123,7 @@ type Payment struct {
 	ServiceLevel            string `xml:"PmtTpInf>SvcLvl>Cd"`
 	LocalInstrument         string `xml:"PmtTpInf>LclInstrm>Cd"`
 	SequenceType            string `xml:"PmtTpInf>SeqTp"`
+	CategoryPurpose         *Code  `xml:"PmtTpInf>CtgyPurp,omitempty"`
 	RequestedCollectionDate string `xml:"ReqdColltnDt"`
 	*Creditor
 	Transactions []Transaction `xml:"DrctDbtTxInf"`
@@ -74,10 +138,16 @@ func NewPayment() *Payment {
 	return p
 }
 
+//NotProvided identifies the debtor agent of a debtor without BIC
+const NotProvided = "NOTPROVIDED"
+
 type Debtor struct {
-	BIC  string `xml:"DbtrAgt>FinInstnId>BIC"`
-	Name string `xml:"Dbtr>Nm"`
-	IBAN string `xml:"DbtrAcct>Id>IBAN"`
+	BIC string `xml:"DbtrAgt>FinInstnId>BIC,omitempty"`
+	//AgentID is the debtor agent written when there is no BIC: Othr/Id
+	//NOTPROVIDED, as the EPC implementation guidelines require
+	AgentID *OtherID `xml:"DbtrAgt>FinInstnId>Othr,omitempty"`
+	Name    string   `xml:"Dbtr>Nm"`
+	IBAN    string   `xml:"DbtrAcct>Id>IBAN"`
 }
 type Date time.Time
 
@@ -87,13 +157,60 @@ func (d Date) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
 	return nil
 }
 
+//String returns the date as YYYY-MM-DD
+func (d Date) String() string {
+	return time.Time(d).Format("2006-01-02")
+}
+
 type Transaction struct {
-	ID        string  `xml:"PmtId>EndToEndId"`
-	Amount    TAmount `xml:"InstdAmt"`
-	MandateID string  `xml:"DrctDbtTx>MndtRltdInf>MndtId"`
-	Date      Date    `xml:"DrctDbtTx>MndtRltdInf>DtOfSgntr"`
+	ID      string      `xml:"PmtId>EndToEndId"`
+	Amount  TAmount     `xml:"InstdAmt"`
+	Mandate MandateInfo `xml:"DrctDbtTx>MndtRltdInf"`
+	//UltimateCreditor overrides the payment ultimate creditor for this transaction
+	UltimateCreditor *Party `xml:"UltmtCdtr,omitempty"`
 	Debtor
-	RemittanceInfo string `xml:"RmtInf>Ustrd"`
+	//UltimateDebtor is the party the debtor pays for
+	UltimateDebtor *Party `xml:"UltmtDbtr,omitempty"`
+	//InstructionForCreditorAgent is free text for the creditor bank
+	InstructionForCreditorAgent string             `xml:"InstrForCdtrAgt,omitempty"`
+	Purpose                     *Code              `xml:"Purp,omitempty"`
+	RemittanceInfo              []string           `xml:"RmtInf>Ustrd"`
+	CreditorReference           *CreditorReference `xml:"RmtInf>Strd>CdtrRefInf,omitempty"`
+}
+
+//MarshalXML writes the transaction with a NOTPROVIDED debtor agent when
+//there is no BIC
+func (t Transaction) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	type plain Transaction
+	if t.BIC == "" {
+		t.AgentID = &OtherID{ID: NotProvided}
+	} else {
+		t.AgentID = nil
+	}
+	return e.EncodeElement(plain(t), start)
+}
+
+//CreditorReference is a structured ISO 11649 (RF) creditor reference
+type CreditorReference struct {
+	Type   string `xml:"Tp>CdOrPrtry>Cd"`
+	Issuer string `xml:"Tp>Issr,omitempty"`
+	Ref    string `xml:"Ref"`
+}
+
+//SetCreditorReference sets a validated RF creditor reference as structured
+//remittance information. The unstructured one is removed, as the EPC
+//rulebook allows only one of them
+func (t *Transaction) SetCreditorReference(rf string) error {
+	if err := rfref.Validate(rf); err != nil {
+		return err
+	}
+	t.CreditorReference = &CreditorReference{
+		Type:   "SCOR",
+		Issuer: "ISO",
+		Ref:    strings.Replace(rf, " ", "", -1),
+	}
+	t.RemittanceInfo = nil
+	return nil
 }
 
 // TAmount is the transaction amount with its currency
@@ -103,18 +220,52 @@ type TAmount struct {
 }
 
 func NewDocument() *Document {
+	return NewDocumentWithOptions(Options{})
+}
+
+//NewDocumentWithOptions returns a Document whose creation time and
+//identifiers come from opts
+func NewDocumentWithOptions(opts Options) *Document {
 	d := &Document{
 		XMLNs:  "urn:iso:std:iso:20022:tech:xsd:pain.008.001.02",
 		XMLxsi: "http://www.w3.org/2001/XMLSchema-instance",
+		opts:   opts.withDefaults(),
 	}
-	t := time.Now()
-	d.SetCreationDateTime(t)
-	r := make([]byte, 8)
-	io.ReadFull(rand.Reader, r)
-	d.MsgID = fmt.Sprintf("f-%s-%x", t.Format("20060102"), r)
+	d.SetCreationDateTime(d.opts.Clock.Now())
+	d.MsgID = d.newMsgID()
 	return d
 }
 
+//NewID returns a new unique identifier from the document IDGenerator,
+//for MsgId and PmtInfId
+func (d *Document) NewID(prefix string) string {
+	return d.options().IDGenerator.NewID(prefix)
+}
+
+//newMsgID returns a new MsgId prefixed with the document creation date
+func (d *Document) newMsgID() string {
+	t, err := time.Parse("2006-01-02T15:04:05", d.CreationDateTime)
+	if err != nil {
+		t = d.options().Clock.Now()
+	}
+	return d.NewID("f-" + t.Format("20060102"))
+}
+
+//Options returns the document options
+func (d *Document) Options() Options {
+	return d.options()
+}
+
+//options returns the document options. The constructors set the defaults;
+//documents built as literals get them on the first call, so that their
+//identifiers all come from the same IDGenerator
+func (d *Document) options() Options {
+	d.defaults.Do(func() {
+		d.opts = d.opts.withDefaults()
+	})
+	return d.opts
+}
+
 func (d *Document) SetCreationDateTime(t time.Time) {
 	d.CreationDateTime = t.Format("2006-01-02T15:04:05")
 }
@@ -134,16 +285,44 @@ func (d *Document) WriteBytes() ([]byte, error) {
 	return xml.MarshalIndent(d, "", "  ")
 }
 
-//WriteLatin1 writes ISO8859-1 XML document to io.Writer argument
-func (d *Document) WriteLatin1(w io.Writer) error {
+//Encoding is the character encoding of the written XML document
+type Encoding int
 
+const (
+	Latin1 Encoding = iota // ISO-8859-1
+	UTF8
+)
+
+//ParseEncoding returns the Encoding for a name such as "utf-8" or "iso-8859-1"
+func ParseEncoding(name string) (Encoding, error) {
+	switch strings.ToLower(name) {
+	case "iso-8859-1", "iso8859-1", "latin1":
+		return Latin1, nil
+	case "utf-8", "utf8":
+		return UTF8, nil
+	}
+	return Latin1, fmt.Errorf("Unknown encoding %s", name)
+}
+
+//Write writes the XML document to w in the given encoding
+func (d *Document) Write(w io.Writer, enc Encoding) error {
 	data, err := d.WriteBytes()
 	if err != nil {
 		return err
 	}
-	wl1 := charmap.ISO8859_1.NewEncoder().Writer(w)
-	header := []byte(`<?xml version="1.0" encoding="iso-8859-1"?>` + "\n")
-	wl1.Write(header)
-	wl1.Write(data)
-	return nil
+	header := `<?xml version="1.0" encoding="utf-8"?>` + "\n"
+	if enc == Latin1 {
+		w = charmap.ISO8859_1.NewEncoder().Writer(w)
+		header = `<?xml version="1.0" encoding="iso-8859-1"?>` + "\n"
+	}
+	if _, err := io.WriteString(w, header); err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+//WriteLatin1 writes ISO8859-1 XML document to io.Writer argument
+func (d *Document) WriteLatin1(w io.Writer) error {
+	return d.Write(w, Latin1)
 }
@@ -0,0 +1,233 @@
+package sepadebit
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/apsl/sepakit/redact"
+)
+
+//ISO 20022 text types maximum lengths
+const (
+	Max16Text   = 16
+	Max35Text   = 35
+	Max70Text   = 70
+	Max140Text  = 140
+	Max1025Text = 1025
+)
+
+//ElementLengths are the pain.008.001.02 maximum lengths of the text elements
+//written by this package. Nm applies to every party name
+var ElementLengths = map[string]int{
+	"MsgId":           Max35Text,
+	"PmtInfId":        Max35Text,
+	"EndToEndId":      Max35Text,
+	"MndtId":          Max35Text,
+	"ElctrncSgntr":    Max1025Text,
+	"Id":              Max35Text,
+	"Nm":              Max140Text,
+	"AdrLine":         Max70Text,
+	"StrtNm":          Max70Text,
+	"BldgNb":          Max16Text,
+	"PstCd":           Max16Text,
+	"TwnNm":           Max35Text,
+	"CtrySubDvsn":     Max35Text,
+	"Ustrd":           Max140Text,
+	"InstrForCdtrAgt": Max140Text,
+}
+
+//FreeTextElements are the elements LengthTruncate and LengthWrap may alter.
+//Identifiers and codes such as MsgId, EndToEndId or MndtId are never cut:
+//a longer one always fails, whatever the mode
+var FreeTextElements = map[string]bool{
+	"Nm":      true,
+	"AdrLine": true,
+	"StrtNm":  true,
+	"Ustrd":   true,
+}
+
+//LengthMode tells what to do with a text longer than its element allows
+type LengthMode int
+
+const (
+	LengthError    LengthMode = iota // fail
+	LengthTruncate                   // truncate free texts at a word boundary
+	LengthWrap                       // spill Ustrd over several elements, truncate other free texts
+)
+
+//ParseLengthMode returns the LengthMode for "error", "truncate" or "wrap"
+func ParseLengthMode(name string) (LengthMode, error) {
+	switch name {
+	case "error":
+		return LengthError, nil
+	case "truncate":
+		return LengthTruncate, nil
+	case "wrap":
+		return LengthWrap, nil
+	}
+	return LengthError, fmt.Errorf("Unknown length policy %s", name)
+}
+
+//LengthPolicy sets the maximum text lengths and how to enforce them
+type LengthPolicy struct {
+	Mode LengthMode
+	//Limits overrides ElementLengths, e.g. {"Nm": 70} for banks requiring
+	//shorter names
+	Limits map[string]int
+	//MaxUstrd limits the number of Ustrd elements when wrapping (0 = unlimited)
+	MaxUstrd int
+}
+
+//LengthWarning records a text altered to fit its element
+type LengthWarning struct {
+	Field    string `json:"field"`
+	Original string `json:"original"`
+	Result   string `json:"result"`
+}
+
+//String returns the warning with the names redacted
+func (w LengthWarning) String() string {
+	return fmt.Sprintf("%s: %q -> %q", w.Field, redact.Field(w.Field, w.Original), redact.Field(w.Field, w.Result))
+}
+
+func (p LengthPolicy) limit(element string) int {
+	if l, ok := p.Limits[element]; ok {
+		return l
+	}
+	return ElementLengths[element]
+}
+
+//lengthEnforcer applies a policy recording warnings and the first error
+type lengthEnforcer struct {
+	policy   LengthPolicy
+	warnings []LengthWarning
+	err      error
+}
+
+func (e *lengthEnforcer) text(field, element, s string) string {
+	max := e.policy.limit(element)
+	if max <= 0 || len([]rune(s)) <= max {
+		return s
+	}
+	if e.policy.Mode == LengthError || !FreeTextElements[element] {
+		if e.err == nil {
+			//the value is not shown, as it may be a name, an address or a concept
+			e.err = fmt.Errorf("%s has %d characters, longer than %d", field, len([]rune(s)), max)
+		}
+		return s
+	}
+	result := truncateWords(s, max)
+	e.warnings = append(e.warnings, LengthWarning{Field: field, Original: s, Result: result})
+	return result
+}
+
+func (e *lengthEnforcer) ustrd(field string, lines []string) []string {
+	if e.policy.Mode != LengthWrap {
+		for i, line := range lines {
+			lines[i] = e.text(field, "Ustrd", line)
+		}
+		return lines
+	}
+	max := e.policy.limit("Ustrd")
+	if max <= 0 {
+		return lines
+	}
+	var wrapped []string
+	for _, line := range lines {
+		wrapped = append(wrapped, wrapWords(line, max)...)
+	}
+	if e.policy.MaxUstrd > 0 && len(wrapped) > e.policy.MaxUstrd {
+		wrapped = wrapped[:e.policy.MaxUstrd]
+	}
+	original := strings.Join(lines, " ")
+	if result := strings.Join(wrapped, " "); result != original {
+		e.warnings = append(e.warnings, LengthWarning{Field: field, Original: original, Result: result})
+	}
+	return wrapped
+}
+
+func (e *lengthEnforcer) party(field string, p *Party) {
+	if p == nil {
+		return
+	}
+	p.Name = e.text(field+"/Nm", "Nm", p.Name)
+	for _, id := range []*OtherID{p.OrgID, p.PrvtID} {
+		if id != nil {
+			id.ID = e.text(field+"/Id", "Id", id.ID)
+		}
+	}
+}
+
+//EnforceLengths checks every text element of the document against policy.
+//Under LengthError the first overflow is returned as an error; otherwise
+//free texts are truncated or wrapped and a warning is returned for each of
+//them, while an overflowing identifier is still an error
+func (d *Document) EnforceLengths(policy LengthPolicy) ([]LengthWarning, error) {
+	e := &lengthEnforcer{policy: policy}
+	d.MsgID = e.text("GrpHdr/MsgId", "MsgId", d.MsgID)
+	d.InitiatingParty.Name = e.text("InitgPty/Nm", "Nm", d.InitiatingParty.Name)
+	d.InitiatingParty.ID = e.text("InitgPty/Id", "Id", d.InitiatingParty.ID)
+	for _, p := range d.Payments {
+		path := "PmtInf[" + p.ID + "]"
+		p.ID = e.text(path+"/PmtInfId", "PmtInfId", p.ID)
+		if p.Creditor != nil {
+			p.Creditor.Name = e.text(path+"/Cdtr/Nm", "Nm", p.Creditor.Name)
+			e.party(path+"/UltmtCdtr", p.Creditor.UltimateCreditor)
+			p.Creditor.ID = e.text(path+"/CdtrSchmeId/Id", "Id", p.Creditor.ID)
+			if a := p.Creditor.PostalAddress; a != nil {
+				if len(a.AddressLines) > MaxAddressLines && e.err == nil {
+					e.err = fmt.Errorf("%s/Cdtr/PstlAdr has %d AdrLine, at most %d are allowed", path, len(a.AddressLines), MaxAddressLines)
+				}
+				for _, f := range a.textFields() {
+					*f.value = e.text(path+"/Cdtr/PstlAdr/"+f.element, f.element, *f.value)
+				}
+			}
+		}
+		for i := range p.Transactions {
+			t := &p.Transactions[i]
+			txPath := path + "/DrctDbtTxInf[" + t.ID + "]"
+			t.ID = e.text(txPath+"/EndToEndId", "EndToEndId", t.ID)
+			t.Mandate.ID = e.text(txPath+"/MndtId", "MndtId", t.Mandate.ID)
+			t.Mandate.ElectronicSignature = e.text(txPath+"/ElctrncSgntr", "ElctrncSgntr", t.Mandate.ElectronicSignature)
+			if a := t.Mandate.Amendment; a != nil {
+				a.OriginalMandateID = e.text(txPath+"/OrgnlMndtId", "MndtId", a.OriginalMandateID)
+			}
+			t.Debtor.Name = e.text(txPath+"/Dbtr/Nm", "Nm", t.Debtor.Name)
+			e.party(txPath+"/UltmtCdtr", t.UltimateCreditor)
+			e.party(txPath+"/UltmtDbtr", t.UltimateDebtor)
+			t.InstructionForCreditorAgent = e.text(txPath+"/InstrForCdtrAgt", "InstrForCdtrAgt", t.InstructionForCreditorAgent)
+			t.RemittanceInfo = e.ustrd(txPath+"/RmtInf/Ustrd", t.RemittanceInfo)
+		}
+	}
+	return e.warnings, e.err
+}
+
+//truncateWords cuts s to max characters, at the last space when it is not
+//too far from the limit
+func truncateWords(s string, max int) string {
+	rs := []rune(s)
+	if len(rs) <= max {
+		return s
+	}
+	cut := max
+	for i := max; i > max/2; i-- {
+		if rs[i] == ' ' {
+			cut = i
+			break
+		}
+	}
+	return strings.TrimRight(string(rs[:cut]), " ")
+}
+
+//wrapWords splits s in lines of at most max characters at word boundaries.
+//An empty or blank s has no lines, rather than an empty Ustrd
+func wrapWords(s string, max int) []string {
+	var lines []string
+	s = strings.Trim(s, " ")
+	for s != "" {
+		line := truncateWords(s, max)
+		lines = append(lines, line)
+		s = strings.TrimLeft(string([]rune(s)[len([]rune(line)):]), " ")
+	}
+	return lines
+}
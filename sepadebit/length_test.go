@@ -0,0 +1,105 @@
+package sepadebit
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEnforceLengths(t *testing.T) {
+	concept := "CONCEPTO DEL ADEUDO FRA.1234 CORRESPONDIENTE AL MES DE DICIEMBRE"
+	newDoc := func() *Document {
+		d := testDocument()
+		tx := &d.Payments[0].Transactions[0]
+		tx.Debtor.Name = "NOMBRE MUY LARGO DEL DEUDOR, S.L."
+		tx.RemittanceInfo = []string{concept}
+		return d
+	}
+
+	limits := map[string]int{"Nm": 20, "Ustrd": 30}
+	_, err := newDoc().EnforceLengths(LengthPolicy{Mode: LengthError, Limits: limits})
+	if err == nil || !strings.Contains(err.Error(), "/Dbtr/Nm has 33 characters") || strings.Contains(err.Error(), "DEUDOR") {
+		t.Errorf("Expected an error for the long name without the name, got %v", err)
+	}
+	limits["Nm"] = 70
+	_, err = newDoc().EnforceLengths(LengthPolicy{Mode: LengthError, Limits: limits})
+	if err == nil || strings.Contains(err.Error(), "ADEUDO") {
+		t.Errorf("Expected an error for the long concept without the concept, got %v", err)
+	}
+	limits["Nm"] = 20
+
+	d := newDoc()
+	warnings, err := d.EnforceLengths(LengthPolicy{Mode: LengthTruncate, Limits: limits})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := d.Payments[0].Transactions[0]
+	if tx.Debtor.Name != "NOMBRE MUY LARGO DEL" || len(tx.RemittanceInfo) != 1 || tx.RemittanceInfo[0] != "CONCEPTO DEL ADEUDO FRA.1234" {
+		t.Errorf("Unexpected truncation: %q %q", tx.Debtor.Name, tx.RemittanceInfo)
+	}
+	if len(warnings) != 2 {
+		t.Errorf("Expected 2 warnings, got %v", warnings)
+	}
+
+	d = newDoc()
+	if _, err := d.EnforceLengths(LengthPolicy{Mode: LengthWrap, Limits: limits}); err != nil {
+		t.Fatal(err)
+	}
+	lines := d.Payments[0].Transactions[0].RemittanceInfo
+	if len(lines) != 3 || lines[1] != "CORRESPONDIENTE AL MES DE" || lines[2] != "DICIEMBRE" {
+		t.Errorf("Unexpected wrap: %q", lines)
+	}
+	//blank concepts give no Ustrd
+	for _, blank := range [][]string{{""}, {"   "}, {"", concept}} {
+		d = newDoc()
+		tx := &d.Payments[0].Transactions[0]
+		tx.RemittanceInfo = append([]string(nil), blank...)
+		if _, err := d.EnforceLengths(LengthPolicy{Mode: LengthWrap, Limits: limits}); err != nil {
+			t.Fatal(err)
+		}
+		for _, line := range tx.RemittanceInfo {
+			if strings.TrimSpace(line) == "" {
+				t.Errorf("Unexpected empty Ustrd for %q: %q", blank, tx.RemittanceInfo)
+			}
+		}
+	}
+	out, err := d.WriteBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(out), "<Ustrd></Ustrd>") {
+		t.Errorf("Unexpected empty Ustrd:\n%s", out)
+	}
+}
+
+func TestEnforceLengthsIdentifiers(t *testing.T) {
+	long := strings.Repeat("X", Max35Text+1)
+	for _, set := range []func(*Document){
+		func(d *Document) { d.MsgID = long },
+		func(d *Document) { d.Payments[0].ID = long },
+		func(d *Document) { d.Payments[0].Transactions[0].ID = long },
+		func(d *Document) { d.Payments[0].Transactions[0].Mandate.ID = long },
+		func(d *Document) {
+			d.Payments[0].Transactions[0].Mandate.Amendment = &MandateAmendment{OriginalMandateID: long}
+		},
+	} {
+		for _, mode := range []LengthMode{LengthTruncate, LengthWrap} {
+			d := testDocument()
+			set(d)
+			if _, err := d.EnforceLengths(LengthPolicy{Mode: mode}); err == nil {
+				t.Errorf("Expected error for long identifier in mode %d", mode)
+			}
+		}
+	}
+}
+
+func TestAddressLines(t *testing.T) {
+	a := NewUnstructuredAddress("ES", "CALLE MAYOR, 1", "", "07001 PALMA", "ILLES BALEARS")
+	if len(a.AddressLines) != MaxAddressLines || a.AddressLines[1] != "07001 PALMA ILLES BALEARS" {
+		t.Errorf("Expected the last lines joined, got %q", a.AddressLines)
+	}
+	d := testDocument()
+	d.Payments[0].Creditor.PostalAddress = &PostalAddress{Country: "ES", AddressLines: []string{"1", "2", "3"}}
+	if _, err := d.EnforceLengths(LengthPolicy{Mode: LengthTruncate}); err == nil {
+		t.Error("Expected error for 3 address lines")
+	}
+}
@@ -0,0 +1,82 @@
+package sepadebit
+
+import "fmt"
+
+//MandateInfo is the mandate related information (MndtRltdInf) of a transaction
+type MandateInfo struct {
+	ID            string `xml:"MndtId"`
+	SignatureDate Date   `xml:"DtOfSgntr"`
+	//AmendmentIndicator tells the mandate changed since the previous
+	//collection. Set it with SetAmendment
+	AmendmentIndicator bool              `xml:"AmdmntInd,omitempty"`
+	Amendment          *MandateAmendment `xml:"AmdmntInfDtls,omitempty"`
+	//ElectronicSignature is the e-mandate signature or reference (Max1025Text)
+	ElectronicSignature string `xml:"ElctrncSgntr,omitempty"`
+	FirstCollectionDate *Date  `xml:"FrstColltnDt,omitempty"`
+	FinalCollectionDate *Date  `xml:"FnlColltnDt,omitempty"`
+	//Frequency is the collection frequency code: YEAR, MNTH, QURT, MIAN, WEEK, DAIL, ADHO or INDA
+	Frequency string `xml:"Frqcy,omitempty"`
+}
+
+//MandateAmendment holds the original values of an amended mandate
+type MandateAmendment struct {
+	OriginalMandateID string `xml:"OrgnlMndtId,omitempty" json:"originalMandateId,omitempty"`
+	//OriginalCreditorScheme is the previous creditor name and identifier
+	OriginalCreditorScheme *OriginalCreditorScheme `xml:"OrgnlCdtrSchmeId,omitempty" json:"originalCreditorScheme,omitempty"`
+	//OriginalDebtorAccount is the previous debtor IBAN
+	OriginalDebtorAccount *Account `xml:"OrgnlDbtrAcct,omitempty" json:"originalDebtorAccount,omitempty"`
+	//OriginalDebtorAgent is "SMNDA" when the debtor changed account to another bank
+	OriginalDebtorAgent *OtherID `xml:"OrgnlDbtrAgt>FinInstnId>Othr,omitempty" json:"originalDebtorAgent,omitempty"`
+}
+
+//OriginalCreditorScheme is the creditor of an amended mandate
+type OriginalCreditorScheme struct {
+	Name string   `xml:"Nm,omitempty" json:"name,omitempty"`
+	ID   *OtherID `xml:"Id>PrvtId>Othr,omitempty" json:"id,omitempty"`
+}
+
+//Account is an account identified by its IBAN
+type Account struct {
+	IBAN string `xml:"Id>IBAN" json:"iban"`
+}
+
+//SetAmendment sets the mandate amendment details and its indicator
+func (m *MandateInfo) SetAmendment(a *MandateAmendment) {
+	m.Amendment = a
+	m.AmendmentIndicator = a != nil
+}
+
+//SetDebtorAccountChanged records an amendment for a debtor that changed
+//account: to another IBAN of the same bank, or to another bank (SMNDA). A
+//debtor that changed bank must be collected in a FRST payment, see
+//CheckAmendments
+func (m *MandateInfo) SetDebtorAccountChanged(originalIBAN string, otherBank bool) {
+	a := m.Amendment
+	if a == nil {
+		a = &MandateAmendment{}
+	}
+	if otherBank {
+		a.OriginalDebtorAgent = &OtherID{ID: "SMNDA"}
+	} else {
+		a.OriginalDebtorAccount = &Account{IBAN: originalIBAN}
+	}
+	m.SetAmendment(a)
+}
+
+//changedBank reports whether the debtor moved the mandate to another bank
+func (a *MandateAmendment) changedBank() bool {
+	return a != nil && a.OriginalDebtorAgent != nil && a.OriginalDebtorAgent.ID == "SMNDA"
+}
+
+//CheckAmendments checks that the transactions of debtors that changed bank
+//(SMNDA) are in FRST payments, as the EPC rulebook requires
+func (d *Document) CheckAmendments() error {
+	for _, p := range d.Payments {
+		for _, t := range p.Transactions {
+			if t.Mandate.Amendment.changedBank() && p.SequenceType != "FRST" {
+				return fmt.Errorf("Transaction %s: a debtor that changed bank (SMNDA) must be collected as FRST, not %s", t.ID, p.SequenceType)
+			}
+		}
+	}
+	return nil
+}
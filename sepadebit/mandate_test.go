@@ -0,0 +1,80 @@
+package sepadebit
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSetAmendment(t *testing.T) {
+	var m MandateInfo
+	m.SetAmendment(&MandateAmendment{OriginalMandateID: "MANDATO-ANTERIOR"})
+	if !m.AmendmentIndicator || m.Amendment.OriginalMandateID != "MANDATO-ANTERIOR" {
+		t.Errorf("Unexpected amendment %+v", m)
+	}
+	m.SetAmendment(nil)
+	if m.AmendmentIndicator || m.Amendment != nil {
+		t.Errorf("Expected no amendment, got %+v", m)
+	}
+}
+
+func TestSetDebtorAccountChanged(t *testing.T) {
+	var m MandateInfo
+	m.SetDebtorAccountChanged("ES7600811234461234567890", false)
+	a := m.Amendment
+	if !m.AmendmentIndicator || a.OriginalDebtorAccount == nil || a.OriginalDebtorAccount.IBAN != "ES7600811234461234567890" || a.OriginalDebtorAgent != nil {
+		t.Errorf("Unexpected same bank amendment %+v", a)
+	}
+
+	m = MandateInfo{}
+	m.SetAmendment(&MandateAmendment{OriginalMandateID: "MANDATO-ANTERIOR"})
+	m.SetDebtorAccountChanged("", true)
+	a = m.Amendment
+	if a.OriginalMandateID != "MANDATO-ANTERIOR" || a.OriginalDebtorAgent == nil || a.OriginalDebtorAgent.ID != "SMNDA" || a.OriginalDebtorAccount != nil {
+		t.Errorf("Unexpected other bank amendment %+v", a)
+	}
+}
+
+func TestCheckAmendments(t *testing.T) {
+	d := testDocument()
+	if err := d.CheckAmendments(); err != nil {
+		t.Fatal(err)
+	}
+	d.Payments[0].Transactions[0].Mandate.SetDebtorAccountChanged("", true)
+	if err := d.CheckAmendments(); err == nil {
+		t.Error("Expected error for SMNDA in a RCUR payment")
+	}
+	d.Payments[0].SequenceType = "FRST"
+	if err := d.CheckAmendments(); err != nil {
+		t.Error(err)
+	}
+	d.Payments[1].Transactions[0].Mandate.SetDebtorAccountChanged("ES7600811234461234567890", false)
+	if err := d.CheckAmendments(); err != nil {
+		t.Errorf("Unexpected error for a same bank change in RCUR: %s", err)
+	}
+}
+
+func TestMandateXML(t *testing.T) {
+	d := testDocument()
+	first := Date(time.Date(2014, 1, 2, 0, 0, 0, 0, time.UTC))
+	d.Payments[0].SequenceType = "FRST"
+	d.Payments[0].Transactions[0].Mandate = MandateInfo{
+		ID:                  "MANDATO-1",
+		SignatureDate:       Date(time.Date(2013, 12, 1, 0, 0, 0, 0, time.UTC)),
+		ElectronicSignature: "FIRMA-ELECTRONICA-1",
+		FirstCollectionDate: &first,
+		Frequency:           "MNTH",
+	}
+	d.Payments[0].Transactions[0].Mandate.SetDebtorAccountChanged("", true)
+	data, err := d.WriteBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	xml := strings.Join(strings.Fields(string(data)), "")
+	expected := "<MndtRltdInf><MndtId>MANDATO-1</MndtId><DtOfSgntr>2013-12-01</DtOfSgntr><AmdmntInd>true</AmdmntInd>" +
+		"<AmdmntInfDtls><OrgnlDbtrAgt><FinInstnId><Othr><Id>SMNDA</Id></Othr></FinInstnId></OrgnlDbtrAgt></AmdmntInfDtls>" +
+		"<ElctrncSgntr>FIRMA-ELECTRONICA-1</ElctrncSgntr><FrstColltnDt>2014-01-02</FrstColltnDt><Frqcy>MNTH</Frqcy></MndtRltdInf>"
+	if !strings.Contains(xml, expected) {
+		t.Errorf("Expected %s in:\n%s", expected, data)
+	}
+}
@@ -0,0 +1,73 @@
+package sepadebit
+
+import (
+	"fmt"
+	"strings"
+)
+
+//paymentKey identifies the payments (PmtInf) that can be merged together
+type paymentKey struct {
+	creditorID, iban, ultimate, date, sequence, instrument, level, category string
+}
+
+func keyOf(p *Payment) paymentKey {
+	k := paymentKey{
+		date:       p.RequestedCollectionDate,
+		sequence:   p.SequenceType,
+		instrument: p.LocalInstrument,
+		level:      p.ServiceLevel,
+		category:   p.CategoryPurpose.code(),
+	}
+	if p.Creditor != nil {
+		k.creditorID = p.Creditor.ID
+		k.iban = p.Creditor.IBAN
+		k.ultimate = partyName(p.Creditor.UltimateCreditor)
+	}
+	return k
+}
+
+//Merge returns a new document with the payments of all docs. Payments sharing
+//creditor (and ultimate creditor), collection date, sequence type, scheme and category purpose are merged in a single
+//PmtInf. Duplicated EndToEndIds across transactions are reported as an error.
+//The initiating party is taken from the first document.
+func Merge(docs ...*Document) (*Document, error) {
+	if len(docs) == 0 {
+		return nil, fmt.Errorf("Nothing to merge")
+	}
+	merged := NewDocumentWithOptions(docs[0].options())
+	merged.InitiatingParty = docs[0].InitiatingParty
+
+	payments := map[paymentKey]*Payment{}
+	ids := map[string]int{}
+	seen := map[string]bool{}
+	var duplicates []string
+	for _, d := range docs {
+		for _, p := range d.Payments {
+			k := keyOf(p)
+			mp, ok := payments[k]
+			if !ok {
+				mp = p.copyHeader()
+				ids[mp.ID]++
+				if n := ids[mp.ID]; n > 1 {
+					mp.ID = suffixID(mp.ID, fmt.Sprintf("-%d", n))
+				}
+				payments[k] = mp
+				merged.AddPayment(mp)
+			}
+			for _, t := range p.Transactions {
+				if seen[t.ID] {
+					duplicates = append(duplicates, t.ID)
+				}
+				seen[t.ID] = true
+				mp.Transactions = append(mp.Transactions, t)
+			}
+		}
+	}
+	if len(duplicates) > 0 {
+		return nil, fmt.Errorf("Duplicated EndToEndId: %s", strings.Join(duplicates, ", "))
+	}
+	if err := merged.UpdateTotals(); err != nil {
+		return nil, err
+	}
+	return merged, nil
+}
@@ -0,0 +1,44 @@
+package sepadebit
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	a := testDocument()
+	b := testDocument()
+	for _, p := range b.Payments {
+		for i := range p.Transactions {
+			p.Transactions[i].ID += "-b"
+		}
+	}
+	merged, err := Merge(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(merged.Payments) != 1 {
+		t.Errorf("Expected payments to be merged in 1 PmtInf, got %d", len(merged.Payments))
+	}
+	if merged.TransacNb != 8 || merged.CtrlSum != "131.00" {
+		t.Errorf("Merged totals: %d %s", merged.TransacNb, merged.CtrlSum)
+	}
+	if _, err := Merge(a, a); err == nil {
+		t.Error("Expected duplicated EndToEndId error")
+	}
+}
+
+func TestReadDocument(t *testing.T) {
+	var buf bytes.Buffer
+	d := testDocument()
+	if err := d.WriteLatin1(&buf); err != nil {
+		t.Fatal(err)
+	}
+	r, err := ReadDocument(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.MsgID != d.MsgID || r.CtrlSum != d.CtrlSum || len(r.Payments) != len(d.Payments) {
+		t.Errorf("Read document differs: %+v", r)
+	}
+}
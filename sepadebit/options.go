@@ -0,0 +1,97 @@
+package sepadebit
+
+import (
+	"crypto/rand"
+	"fmt"
+	mrand "math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apsl/sepakit/translit"
+)
+
+//Clock returns the current time, used for CreDtTm and generated identifiers
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+//FixedClock is a Clock that always returns the same time
+type FixedClock time.Time
+
+//Now returns the fixed time
+func (c FixedClock) Now() time.Time { return time.Time(c) }
+
+//IDGenerator generates MsgId and PmtInfId identifiers
+type IDGenerator interface {
+	//NewID returns a new identifier starting with prefix. Identifiers must be
+	//unique and valid Max35Text
+	NewID(prefix string) string
+}
+
+//counterIDs generates identifiers as PREFIX-TOKEN-N, where TOKEN identifies
+//the run and N is a counter
+type counterIDs struct {
+	mu    sync.Mutex
+	token string
+	n     int
+}
+
+//NewIDGenerator returns the default IDGenerator, whose run token is random
+func NewIDGenerator() IDGenerator {
+	r := make([]byte, 4)
+	rand.Read(r)
+	return &counterIDs{token: fmt.Sprintf("%x", r)}
+}
+
+//NewSeededIDGenerator returns an IDGenerator that produces the same
+//identifiers for the same seed, for reproducible output
+func NewSeededIDGenerator(seed int64) IDGenerator {
+	return &counterIDs{token: fmt.Sprintf("%08x", mrand.New(mrand.NewSource(seed)).Uint32())}
+}
+
+func (g *counterIDs) NewID(prefix string) string {
+	g.mu.Lock()
+	g.n++
+	n := g.n
+	g.mu.Unlock()
+	suffix := fmt.Sprintf("-%s-%d", g.token, n)
+	prefix = strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' {
+			return r
+		}
+		return -1
+	}, prefix)
+	if max := 35 - len(suffix); len(prefix) > max {
+		prefix = prefix[:max]
+	}
+	return prefix + suffix
+}
+
+//Options configures the time and identifier sources of a Document, and the
+//character sets of its text
+type Options struct {
+	Clock       Clock
+	IDGenerator IDGenerator
+	//Transliterator cleans free text when converting documents. Defaults
+	//to the EPC basic Latin set
+	Transliterator *translit.Transliterator
+}
+
+//withDefaults fills unset options
+func (o Options) withDefaults() Options {
+	if o.Clock == nil {
+		o.Clock = systemClock{}
+	}
+	if o.IDGenerator == nil {
+		o.IDGenerator = NewIDGenerator()
+	}
+	if o.Transliterator == nil {
+		o.Transliterator = translit.New()
+	}
+	return o
+}
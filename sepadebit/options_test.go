@@ -0,0 +1,25 @@
+package sepadebit
+
+import "testing"
+
+//TestOptionsConcurrent runs with -race: the defaults of a literal document
+//are set once, even by concurrent callers
+func TestOptionsConcurrent(t *testing.T) {
+	d := &Document{}
+	done := make(chan string)
+	for i := 0; i < 4; i++ {
+		go func() {
+			done <- d.NewID("x")
+		}()
+	}
+	ids := map[string]bool{}
+	for i := 0; i < 4; i++ {
+		ids[<-done] = true
+	}
+	if len(ids) != 4 {
+		t.Errorf("Expected 4 different identifiers, got %v", ids)
+	}
+	if d.Options().IDGenerator != d.Options().IDGenerator || d.Options().Transliterator != d.Options().Transliterator {
+		t.Error("Expected the same defaults on every call")
+	}
+}
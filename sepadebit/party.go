@@ -0,0 +1,32 @@
+package sepadebit
+
+//Party is an ultimate creditor or debtor: a name plus an optional
+//organisation or private identification. Only one of OrgID and PrvtID
+//must be set
+type Party struct {
+	Name   string   `xml:"Nm,omitempty" json:"name,omitempty"`
+	OrgID  *OtherID `xml:"Id>OrgId>Othr,omitempty" json:"orgId,omitempty"`
+	PrvtID *OtherID `xml:"Id>PrvtId>Othr,omitempty" json:"prvtId,omitempty"`
+}
+
+//OtherID is a generic identification, such as a NIF or a creditor identifier
+type OtherID struct {
+	ID     string  `xml:"Id" json:"id"`
+	Scheme *Scheme `xml:"SchmeNm,omitempty" json:"scheme,omitempty"`
+	Issuer string  `xml:"Issr,omitempty" json:"issuer,omitempty"`
+}
+
+//Scheme is the name of an identification scheme, either an ISO code (e.g.
+//TXID for tax identification numbers) or a proprietary one
+type Scheme struct {
+	Code        string `xml:"Cd,omitempty" json:"code,omitempty"`
+	Proprietary string `xml:"Prtry,omitempty" json:"proprietary,omitempty"`
+}
+
+//partyName returns the name of p or "" when p is nil
+func partyName(p *Party) string {
+	if p == nil {
+		return ""
+	}
+	return p.Name
+}
@@ -0,0 +1,93 @@
+package sepadebit
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"testing"
+)
+
+//schemaOrder are the pain.008.001.02 children of the elements checked by
+//TestSchemaOrder, in the order the schema sequences require
+var schemaOrder = map[string][]string{
+	"PmtInf": {"PmtInfId", "PmtMtd", "BtchBookg", "NbOfTxs", "CtrlSum", "PmtTpInf",
+		"ReqdColltnDt", "Cdtr", "CdtrAcct", "CdtrAgt", "CdtrAgtAcct", "UltmtCdtr",
+		"ChrgBr", "ChrgsAcct", "ChrgsAcctAgt", "CdtrSchmeId", "DrctDbtTxInf"},
+	"DrctDbtTxInf": {"PmtId", "PmtTpInf", "InstdAmt", "ChrgBr", "DrctDbtTx", "UltmtCdtr",
+		"DbtrAgt", "DbtrAgtAcct", "Dbtr", "DbtrAcct", "UltmtDbtr", "InstrForCdtrAgt",
+		"Purp", "RgltryRptg", "Tax", "RltdRmtInf", "RmtInf"},
+	"UltmtCdtr": {"Nm", "PstlAdr", "Id", "CtryOfRes", "CtctDtls"},
+	"UltmtDbtr": {"Nm", "PstlAdr", "Id", "CtryOfRes", "CtctDtls"},
+}
+
+//childrenOrder returns the names of the children of every element named in
+//schemaOrder, in document order
+func childrenOrder(t *testing.T, data []byte) map[string][][]string {
+	found := map[string][][]string{}
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	var stack []string
+	var children [][]string
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return found
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			if len(children) > 0 {
+				children[len(children)-1] = append(children[len(children)-1], tok.Name.Local)
+			}
+			stack = append(stack, tok.Name.Local)
+			children = append(children, nil)
+		case xml.EndElement:
+			name := stack[len(stack)-1]
+			if _, ok := schemaOrder[name]; ok {
+				found[name] = append(found[name], children[len(children)-1])
+			}
+			stack = stack[:len(stack)-1]
+			children = children[:len(children)-1]
+		}
+	}
+}
+
+func TestSchemaOrder(t *testing.T) {
+	d := testDocument()
+	p := d.Payments[0]
+	p.Creditor.UltimateCreditor = &Party{Name: "FILIAL, S.L.", OrgID: &OtherID{ID: "B12345678"}}
+	tx := &p.Transactions[0]
+	tx.UltimateCreditor = &Party{Name: "OTRA FILIAL, S.L."}
+	tx.UltimateDebtor = &Party{Name: "HIJO DEL DEUDOR", PrvtID: &OtherID{ID: "12345678Z"}}
+	tx.InstructionForCreditorAgent = "INSTRUCCION"
+	tx.RemittanceInfo = []string{"CONCEPTO"}
+	if err := tx.SetPurpose("GDDS"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := d.WriteBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := childrenOrder(t, data)
+	for name, order := range schemaOrder {
+		if len(found[name]) == 0 {
+			t.Errorf("No %s element written", name)
+		}
+		for _, children := range found[name] {
+			next := 0
+			for _, child := range children {
+				i := next
+				for i < len(order) && order[i] != child {
+					i++
+				}
+				if i == len(order) {
+					t.Errorf("%s children out of schema order: %v", name, children)
+					break
+				}
+				//repeated elements, such as DrctDbtTxInf, stay at their position
+				next = i
+			}
+		}
+	}
+}
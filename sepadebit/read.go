@@ -0,0 +1,45 @@
+package sepadebit
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+//ReadDocument parses a pain.008 XML document. UTF-8 and ISO-8859-1 encodings are accepted
+func ReadDocument(r io.Reader) (*Document, error) {
+	d := &Document{opts: Options{}.withDefaults()}
+	dec := xml.NewDecoder(r)
+	dec.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		switch strings.ToLower(charset) {
+		case "iso-8859-1", "iso8859-1", "latin1":
+			return charmap.ISO8859_1.NewDecoder().Reader(input), nil
+		}
+		return nil, fmt.Errorf("Unsupported XML encoding %s", charset)
+	}
+	if err := dec.Decode(d); err != nil {
+		return nil, err
+	}
+	//encoding/xml does not read the xmlns:xsi attribute back
+	if d.XMLxsi == "" {
+		d.XMLxsi = "http://www.w3.org/2001/XMLSchema-instance"
+	}
+	return d, nil
+}
+
+func (d *Date) UnmarshalXML(dec *xml.Decoder, start xml.StartElement) error {
+	var s string
+	if err := dec.DecodeElement(&s, &start); err != nil {
+		return err
+	}
+	t, err := time.Parse("2006-01-02", strings.TrimSpace(s))
+	if err != nil {
+		return err
+	}
+	*d = Date(t)
+	return nil
+}
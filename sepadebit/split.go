@@ -0,0 +1,107 @@
+package sepadebit
+
+import (
+	"crypto/sha256"
+	"fmt"
+)
+
+//Limits sets the maximum size of every document produced by Split.
+//Zero values mean no limit.
+type Limits struct {
+	MaxTransactions int   // maximum number of transactions (NbOfTxs) per document
+	MaxCtrlSum      int64 // maximum CtrlSum per document, in cents
+}
+
+//Split splits the document in parts honoring limits. Payments (PmtInf) keep
+//their creditor, sequence type and collection date; a payment that does not
+//fit is divided across parts. Every part gets its own MsgID and PmtInfIds,
+//and NbOfTxs and CtrlSum are recomputed. A document without transactions,
+//or with an amount that is not positive, is an error.
+func (d *Document) Split(limits Limits) ([]*Document, error) {
+	var parts []*Document
+	var part *Document
+	var partPayment *Payment
+	var partNb int
+	var partSum int64
+	chunks := map[*Payment]int{}
+	opts := d.options()
+
+	newPart := func() {
+		part = &Document{
+			XMLNs:            d.XMLNs,
+			XMLxsi:           d.XMLxsi,
+			CreationDateTime: d.CreationDateTime,
+			InitiatingParty:  d.InitiatingParty,
+			opts:             opts,
+		}
+		part.MsgID = part.newMsgID()
+		parts = append(parts, part)
+		partPayment = nil
+		partNb = 0
+		partSum = 0
+	}
+
+	newPart()
+	for _, p := range d.Payments {
+		partPayment = nil
+		for _, t := range p.Transactions {
+			amount, err := ParseAmount(t.Amount.Amount)
+			if err != nil {
+				return nil, fmt.Errorf("Transaction %s: %s", t.ID, err)
+			}
+			if amount <= 0 {
+				return nil, fmt.Errorf("Transaction %s amount %s is not positive", t.ID, t.Amount.Amount)
+			}
+			if limits.MaxCtrlSum > 0 && amount > limits.MaxCtrlSum {
+				return nil, fmt.Errorf("Transaction %s amount %s exceeds the CtrlSum limit %s", t.ID, t.Amount.Amount, FormatAmount(limits.MaxCtrlSum))
+			}
+			full := limits.MaxTransactions > 0 && partNb >= limits.MaxTransactions
+			if limits.MaxCtrlSum > 0 && partSum+amount > limits.MaxCtrlSum {
+				full = true
+			}
+			if full {
+				newPart()
+			}
+			if partPayment == nil {
+				partPayment = p.copyHeader()
+				chunks[p]++
+				partPayment.ID = suffixID(p.ID, fmt.Sprintf("-%d", chunks[p]))
+				part.AddPayment(partPayment)
+			}
+			partPayment.Transactions = append(partPayment.Transactions, t)
+			partNb++
+			partSum += amount
+		}
+	}
+
+	if len(chunks) == 0 {
+		return nil, fmt.Errorf("Document has no transactions to split")
+	}
+	for _, part := range parts {
+		if err := part.UpdateTotals(); err != nil {
+			return nil, err
+		}
+	}
+	return parts, nil
+}
+
+//suffixID returns id with suffix, as a valid Max35Text. Longer identifiers
+//are cut and end with a hash of the whole id, so they stay unique
+func suffixID(id, suffix string) string {
+	if len(id)+len(suffix) <= Max35Text {
+		return id + suffix
+	}
+	hash := fmt.Sprintf("%x", sha256.Sum256([]byte(id)))[:8]
+	return id[:Max35Text-len(suffix)-len(hash)] + hash + suffix
+}
+
+//copyHeader returns a copy of the payment without transactions
+func (p *Payment) copyHeader() *Payment {
+	c := *p
+	if p.Creditor != nil {
+		creditor := *p.Creditor
+		c.Creditor = &creditor
+	}
+	c.Transactions = nil
+	return &c
+}
@@ -0,0 +1,89 @@
+package sepadebit
+
+import "testing"
+
+func testDocument() *Document {
+	d := NewDocument()
+	d.SetInitiatingParty("PRESENTADOR", "ES03000W9614457A")
+	amounts := [][]string{{"10.00", "20.00", "30.00"}, {"5.50"}}
+	for i, list := range amounts {
+		p := NewPayment()
+		p.ID = "rem20131220" + string(rune('1'+i))
+		p.Creditor = NewCreditor()
+		p.Creditor.ID = "ES08000E77846772"
+		for j, amount := range list {
+			p.Transactions = append(p.Transactions, Transaction{
+				ID:     p.ID + "-" + string(rune('a'+j)),
+				Amount: TAmount{Amount: amount, Currency: "EUR"},
+			})
+		}
+		d.AddPayment(p)
+	}
+	d.UpdateTotals()
+	return d
+}
+
+func TestSplitByTransactions(t *testing.T) {
+	d := testDocument()
+	parts, err := d.Split(Limits{MaxTransactions: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != 2 {
+		t.Fatalf("Expected 2 parts, got %d", len(parts))
+	}
+	if parts[0].TransacNb != 2 || parts[0].CtrlSum != "30.00" {
+		t.Errorf("Part 1 totals: %d %s", parts[0].TransacNb, parts[0].CtrlSum)
+	}
+	if len(parts[1].Payments) != 2 || parts[1].CtrlSum != "35.50" {
+		t.Errorf("Part 2: %d payments, CtrlSum %s", len(parts[1].Payments), parts[1].CtrlSum)
+	}
+	if parts[0].MsgID == parts[1].MsgID || parts[0].Payments[0].ID == parts[1].Payments[0].ID {
+		t.Error("Parts share identifiers")
+	}
+}
+
+func TestSplitByAmount(t *testing.T) {
+	d := testDocument()
+	parts, err := d.Split(Limits{MaxCtrlSum: 3000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != 3 {
+		t.Fatalf("Expected 3 parts, got %d", len(parts))
+	}
+	if _, err := d.Split(Limits{MaxCtrlSum: 1000}); err == nil {
+		t.Error("Expected error for transaction above the limit")
+	}
+}
+
+func TestSplitLongIDs(t *testing.T) {
+	d := testDocument()
+	d.Payments[0].ID = "PAGO-ACREEDOR-MUY-LARGO-20131220-RCUR"
+	parts, err := d.Split(Limits{MaxTransactions: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := map[string]bool{}
+	for _, part := range parts {
+		for _, p := range part.Payments {
+			if len(p.ID) > Max35Text || ids[p.ID] {
+				t.Errorf("Invalid or repeated PmtInfId %s", p.ID)
+			}
+			ids[p.ID] = true
+		}
+	}
+}
+
+func TestSplitInvalid(t *testing.T) {
+	if _, err := NewDocument().Split(Limits{MaxTransactions: 2}); err == nil {
+		t.Error("Expected error for a document without transactions")
+	}
+	for _, amount := range []string{"0.00", "-5.00"} {
+		d := testDocument()
+		d.Payments[1].Transactions[0].Amount.Amount = amount
+		if _, err := d.Split(Limits{MaxTransactions: 2}); err == nil {
+			t.Errorf("Expected error for amount %s", amount)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package sepadebit
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var decimalAmount = regexp.MustCompile(`^-?[0-9]+(\.[0-9]{1,2})?$`)
+
+//ParseAmount parses a SEPA decimal amount ("123.45") into cents. Only digits
+//with up to 2 decimals are accepted, without exponents nor thousands
+//separators
+func ParseAmount(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if !decimalAmount.MatchString(s) {
+		return 0, fmt.Errorf("Invalid amount %q, expected a decimal such as 123.45", s)
+	}
+	units, decimals := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		units, decimals = s[:i], s[i+1:]
+	}
+	cents, err := strconv.ParseInt(units+(decimals + "00")[:2], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid amount %q, out of range", s)
+	}
+	return cents, nil
+}
+
+//FormatAmount formats cents as a SEPA decimal amount ("123.45")
+func FormatAmount(cents int64) string {
+	sign := ""
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+//UpdateTotals recomputes NbOfTxs and CtrlSum of every payment and of the group header
+func (d *Document) UpdateTotals() error {
+	var docSum int64
+	var docNb int
+	for _, p := range d.Payments {
+		sum, err := p.updateTotals()
+		if err != nil {
+			return err
+		}
+		docNb += p.TransacNb
+		docSum += sum
+	}
+	d.TransacNb = docNb
+	d.CtrlSum = FormatAmount(docSum)
+	return nil
+}
+
+//UpdateTotals recomputes NbOfTxs and CtrlSum of the payment
+func (p *Payment) UpdateTotals() error {
+	_, err := p.updateTotals()
+	return err
+}
+
+func (p *Payment) updateTotals() (int64, error) {
+	var sum int64
+	for _, t := range p.Transactions {
+		amount, err := ParseAmount(t.Amount.Amount)
+		if err != nil {
+			return 0, fmt.Errorf("Transaction %s: %s", t.ID, err)
+		}
+		sum += amount
+	}
+	p.TransacNb = len(p.Transactions)
+	p.CtrlSum = FormatAmount(sum)
+	return sum, nil
+}
@@ -0,0 +1,16 @@
+package sepadebit
+
+import "testing"
+
+func TestParseAmount(t *testing.T) {
+	for s, want := range map[string]int64{"123.45": 12345, "0.5": 50, "7": 700, " 10.00 ": 1000, "-1.05": -105, "12345678901234.56": 1234567890123456} {
+		if cents, err := ParseAmount(s); err != nil || cents != want {
+			t.Errorf("ParseAmount(%q) = %d, %v, expected %d", s, cents, err, want)
+		}
+	}
+	for _, s := range []string{"", "NaN", "Inf", "1e3", "0x10", "1.234", "1,50", ".5", "+5", "99999999999999999999"} {
+		if _, err := ParseAmount(s); err == nil {
+			t.Errorf("Expected error for %q", s)
+		}
+	}
+}
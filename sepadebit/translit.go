@@ -0,0 +1,38 @@
+package sepadebit
+
+import "github.com/apsl/sepakit/translit"
+
+//Transliterate converts every free text field of the document (names,
+//addresses and remittance information) to the character sets allowed by t.
+//Altered fields are recorded on t.
+func (d *Document) Transliterate(t *translit.Transliterator) {
+	d.InitiatingParty.Name = t.Field("InitgPty/Nm", d.InitiatingParty.Name)
+	for _, p := range d.Payments {
+		path := "PmtInf[" + p.ID + "]"
+		if p.Creditor != nil {
+			p.Creditor.Name = t.Field(path+"/Cdtr/Nm", p.Creditor.Name)
+			if p.Creditor.UltimateCreditor != nil {
+				p.Creditor.UltimateCreditor.Name = t.Field(path+"/UltmtCdtr/Nm", p.Creditor.UltimateCreditor.Name)
+			}
+			if a := p.Creditor.PostalAddress; a != nil {
+				for _, f := range a.textFields() {
+					*f.value = t.Field(path+"/Cdtr/PstlAdr/"+f.element, *f.value)
+				}
+			}
+		}
+		for i := range p.Transactions {
+			tx := &p.Transactions[i]
+			txPath := path + "/DrctDbtTxInf[" + tx.ID + "]"
+			tx.Debtor.Name = t.Field(txPath+"/Dbtr/Nm", tx.Debtor.Name)
+			if tx.UltimateCreditor != nil {
+				tx.UltimateCreditor.Name = t.Field(txPath+"/UltmtCdtr/Nm", tx.UltimateCreditor.Name)
+			}
+			if tx.UltimateDebtor != nil {
+				tx.UltimateDebtor.Name = t.Field(txPath+"/UltmtDbtr/Nm", tx.UltimateDebtor.Name)
+			}
+			for j, line := range tx.RemittanceInfo {
+				tx.RemittanceInfo[j] = t.Field(txPath+"/RmtInf/Ustrd", line)
+			}
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/apsl/sepakit/server"
+)
+
+//runServe implements "sepakit serve": the HTTP conversion service. It stops
+//on SIGINT or SIGTERM, letting the requests in progress finish
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", ":8080", "listen address")
+	maxBody := fs.Int64("max-body", server.DefaultMaxBodySize, "maximum request size in bytes")
+	profile := fs.String("profile", "caixabank", "default bank profile, requests may select another one with ?profile=")
+	timeout := fs.Duration("shutdown-timeout", 30*time.Second, "time given to requests in progress on shutdown")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Serves the conversion HTTP API: POST /convert, /validate, /inspect and /split\nUsage: %s serve [options]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	parseArgs(fs, args)
+
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           server.New(server.Config{MaxBodySize: *maxBody, Profile: *profile}),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	errc := make(chan error, 1)
+	go func() {
+		log.Printf("Listening on %s\n", *addr)
+		errc <- srv.ListenAndServe()
+	}()
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	select {
+	case err := <-errc:
+		return err
+	case sig := <-stop:
+		log.Printf("Got %s, shutting down\n", sig)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	return srv.Shutdown(ctx)
+}
@@ -0,0 +1,499 @@
+//Package server implements the sepakit HTTP conversion service:
+//
+//	POST /convert   AEB 19, CSB 19, CSV or JSON remittance to pain.008 (or JSON)
+//	POST /validate  conversion diagnostics: errors, warnings and altered texts
+//	POST /inspect   payments, totals and dates of the input
+//	POST /split     pain.008 parts as a zip file (or JSON remittances)
+//
+//The input is the request body, or the "file" part of a multipart form whose
+//"mapping" part is the CSV/XLSX column mapping. Its format is taken from the
+//"from" parameter, or detected. Other parameters are profile, encoding,
+//charset, lengths and rf, as the command line flags, and max-txs and
+//max-amount for /split. Errors are returned as {"error": "..."}.
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/apsl/sepakit/convert"
+	"github.com/apsl/sepakit/remittance"
+	"github.com/apsl/sepakit/sepadebit"
+	"github.com/apsl/sepakit/tabular"
+	"github.com/apsl/sepakit/translit"
+)
+
+//DefaultMaxBodySize is the default request size limit
+const DefaultMaxBodySize = 10 << 20
+
+//Config configures the service
+type Config struct {
+	//MaxBodySize limits the request size, DefaultMaxBodySize if 0
+	MaxBodySize int64
+	//Profile is the bank profile used when the request names none
+	Profile string
+	//Options returns the clock and ID generator of every conversion. The
+	//defaults are used when nil
+	Options func() sepadebit.Options
+}
+
+//Server is the HTTP handler of the service
+type Server struct {
+	cfg Config
+	mux *http.ServeMux
+}
+
+//New returns the service handler
+func New(cfg Config) *Server {
+	if cfg.MaxBodySize <= 0 {
+		cfg.MaxBodySize = DefaultMaxBodySize
+	}
+	s := &Server{cfg: cfg, mux: http.NewServeMux()}
+	s.mux.HandleFunc("/convert", s.post(s.handleConvert))
+	s.mux.HandleFunc("/validate", s.post(s.handleValidate))
+	s.mux.HandleFunc("/inspect", s.post(s.handleInspect))
+	s.mux.HandleFunc("/split", s.post(s.handleSplit))
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+//httpError is an error with its HTTP status
+type httpError struct {
+	status int
+	err    error
+}
+
+func (e *httpError) Error() string {
+	return e.err.Error()
+}
+
+func errorf(status int, format string, args ...interface{}) error {
+	return &httpError{status: status, err: fmt.Errorf(format, args...)}
+}
+
+//post wraps a handler accepting only POST and writing its errors as JSON
+func (s *Server) post(h func(w http.ResponseWriter, r *http.Request) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeError(w, errorf(http.StatusMethodNotAllowed, "Method %s not allowed", r.Method))
+			return
+		}
+		if err := h(w, r); err != nil {
+			writeError(w, err)
+		}
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	if he, ok := err.(*httpError); ok {
+		status = he.status
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+//converter returns the request input and the Converter of its parameters,
+//with opts
+func (s *Server) converter(r *http.Request, opts ...convert.Option) (*convert.Converter, []byte, error) {
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, s.cfg.MaxBodySize+1))
+	if err != nil {
+		return nil, nil, err
+	}
+	if int64(len(body)) > s.cfg.MaxBodySize {
+		return nil, nil, errorf(http.StatusRequestEntityTooLarge, "Request larger than %d bytes", s.cfg.MaxBodySize)
+	}
+	input, mappingData, err := readInput(r.Header.Get("Content-Type"), body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	q := r.URL.Query()
+	profileName := q.Get("profile")
+	if profileName == "" {
+		profileName = s.cfg.Profile
+	}
+	profile, err := convert.ParseProfile(profileName)
+	if err != nil {
+		return nil, nil, err
+	}
+	sets, err := translit.SetsByName(q.Get("charset"))
+	if err != nil {
+		return nil, nil, err
+	}
+	rfSource, err := convert.ParseRFSource(q.Get("rf"))
+	if err != nil {
+		return nil, nil, err
+	}
+	mode := q.Get("lengths")
+	if mode == "" {
+		mode = "error"
+	}
+	policy := sepadebit.LengthPolicy{}
+	if policy.Mode, err = sepadebit.ParseLengthMode(mode); err != nil {
+		return nil, nil, err
+	}
+	from := q.Get("from")
+	if from == "" {
+		from = detect(input, r.Header.Get("Content-Type"))
+	}
+	if !contains(convert.Formats, from) {
+		return nil, nil, errorf(http.StatusUnsupportedMediaType, "Unknown input format %s", from)
+	}
+	convOpts := convert.Options{RFReference: rfSource}
+	if from == "csv" || from == "xlsx" {
+		if mappingData == nil {
+			return nil, nil, errorf(http.StatusBadRequest, "%s input needs a mapping part", from)
+		}
+		if convOpts.Mapping, err = tabular.LoadMapping(bytes.NewReader(mappingData)); err != nil {
+			return nil, nil, errorf(http.StatusUnprocessableEntity, "%s", err)
+		}
+	}
+
+	opts = append([]convert.Option{
+		convert.WithInputFormat(from),
+		convert.WithOptions(convOpts),
+		convert.WithProfile(profile),
+		convert.WithCharsets(sets...),
+		convert.WithLengths(policy),
+	}, opts...)
+	if s.cfg.Options != nil {
+		o := s.cfg.Options()
+		opts = append(opts, convert.WithClock(o.Clock), convert.WithIDGenerator(o.IDGenerator))
+	}
+	c, err := convert.NewConverter(opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return c, input, nil
+}
+
+//document converts the request input to a Document
+func (s *Server) document(r *http.Request) (*sepadebit.Document, error) {
+	c, input, err := s.converter(r)
+	if err != nil {
+		return nil, err
+	}
+	doc, _, err := c.Document(r.Context(), bytes.NewReader(input))
+	if err != nil {
+		return nil, errorf(http.StatusUnprocessableEntity, "%s", err)
+	}
+	return doc, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+//readInput returns the input and mapping of a body, either raw or a
+//multipart form with file and mapping parts
+func readInput(contentType string, body []byte) (input, mapping []byte, err error) {
+	mediaType, params, _ := mime.ParseMediaType(contentType)
+	if mediaType != "multipart/form-data" {
+		return body, nil, nil
+	}
+	mr := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		data, err := ioutil.ReadAll(part)
+		if err != nil {
+			return nil, nil, err
+		}
+		switch part.FormName() {
+		case "file":
+			input = data
+		case "mapping":
+			mapping = data
+		}
+	}
+	if input == nil {
+		return nil, nil, errorf(http.StatusBadRequest, "Missing file part")
+	}
+	return input, mapping, nil
+}
+
+//detect returns the input format from the content type or the first characters
+func detect(input []byte, contentType string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "text/csv":
+		return "csv"
+	case "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":
+		return "xlsx"
+	}
+	trimmed := bytes.TrimLeft(bytes.TrimPrefix(input, []byte("\xEF\xBB\xBF")), " \t\r\n")
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		return "json"
+	case bytes.HasPrefix(trimmed, []byte("<")):
+		return "xml"
+	case bytes.HasPrefix(trimmed, []byte("PK\x03\x04")):
+		return "xlsx"
+	case bytes.HasPrefix(trimmed, []byte("5180")):
+		return "csb19"
+	}
+	return "aeb19"
+}
+
+//negotiate returns the offered media type preferred by the Accept header,
+//the first offer when there is no header, or "" when none is acceptable
+func negotiate(r *http.Request, offers ...string) string {
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return offers[0]
+	}
+	best, bestQ := "", 0.0
+	for _, item := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(item))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		for _, offer := range offers {
+			if q > bestQ && matches(mediaType, offer) {
+				best, bestQ = offer, q
+			}
+		}
+	}
+	return best
+}
+
+func matches(mediaType, offer string) bool {
+	if mediaType == "*/*" || mediaType == offer {
+		return true
+	}
+	return strings.HasSuffix(mediaType, "/*") && strings.HasPrefix(offer, strings.TrimSuffix(mediaType, "*"))
+}
+
+func (s *Server) handleConvert(w http.ResponseWriter, r *http.Request) error {
+	accept := negotiate(r, "application/xml", "text/xml", "application/json")
+	if accept == "" {
+		return errorf(http.StatusNotAcceptable, "pain.008 is available as application/xml or application/json")
+	}
+	enc := sepadebit.Latin1
+	if name := r.URL.Query().Get("encoding"); name != "" {
+		var err error
+		if enc, err = sepadebit.ParseEncoding(name); err != nil {
+			return err
+		}
+	}
+	format := "xml"
+	if accept == "application/json" {
+		format = "json"
+	}
+	c, input, err := s.converter(r, convert.WithOutputEncoding(enc), convert.WithOutputFormat(format))
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if _, err := c.Convert(r.Context(), bytes.NewReader(input), &buf); err != nil {
+		return errorf(http.StatusUnprocessableEntity, "%s", err)
+	}
+	if format == "json" {
+		w.Header().Set("Content-Type", "application/json")
+	} else {
+		charset := "iso-8859-1"
+		if enc == sepadebit.UTF8 {
+			charset = "utf-8"
+		}
+		w.Header().Set("Content-Type", accept+"; charset="+charset)
+	}
+	w.Write(buf.Bytes())
+	return nil
+}
+
+//Diagnostics is the /validate response. The input is valid when it is
+//converted without errors nor warnings
+type Diagnostics struct {
+	Valid  bool     `json:"valid"`
+	Errors []string `json:"errors,omitempty"`
+	//Warnings are the non-fatal issues, such as dropped purpose codes
+	Warnings []string `json:"warnings,omitempty"`
+	//Transformations are the texts altered to fit the character sets or the
+	//lengths, with the names redacted
+	Transformations []convert.Transformation `json:"transformations,omitempty"`
+	Transactions    int                      `json:"transactions"`
+	CtrlSum         string                   `json:"ctrlSum,omitempty"`
+}
+
+func (s *Server) handleValidate(w http.ResponseWriter, r *http.Request) error {
+	c, input, err := s.converter(r)
+	if err != nil {
+		if he, ok := err.(*httpError); ok && he.status == http.StatusUnprocessableEntity {
+			writeJSON(w, he.status, Diagnostics{Errors: []string{err.Error()}})
+			return nil
+		}
+		return err
+	}
+	_, report, err := c.Document(r.Context(), bytes.NewReader(input))
+	d := Diagnostics{
+		Warnings:        report.Warnings,
+		Transformations: report.Transformations,
+		Transactions:    report.Transactions,
+		CtrlSum:         report.CtrlSum,
+	}
+	for _, v := range report.Validations {
+		if !v.Passed {
+			d.Errors = append(d.Errors, v.Error)
+		}
+	}
+	if err != nil && !contains(d.Errors, err.Error()) {
+		d.Errors = append(d.Errors, err.Error())
+	}
+	d.Valid = len(d.Errors) == 0 && len(d.Warnings) == 0
+	status := http.StatusOK
+	if !d.Valid {
+		status = http.StatusUnprocessableEntity
+	}
+	writeJSON(w, status, d)
+	return nil
+}
+
+//Summary is the /inspect response
+type Summary struct {
+	MessageID       string           `json:"messageId"`
+	CreationDate    string           `json:"creationDateTime"`
+	InitiatingParty string           `json:"initiatingParty"`
+	Transactions    int              `json:"transactions"`
+	CtrlSum         string           `json:"ctrlSum"`
+	Payments        []PaymentSummary `json:"payments"`
+}
+
+//PaymentSummary is a payment of the Summary
+type PaymentSummary struct {
+	ID              string `json:"id"`
+	Creditor        string `json:"creditor"`
+	CreditorID      string `json:"creditorId"`
+	CollectionDate  string `json:"collectionDate"`
+	SequenceType    string `json:"sequenceType"`
+	LocalInstrument string `json:"localInstrument"`
+	Transactions    int    `json:"transactions"`
+	CtrlSum         string `json:"ctrlSum"`
+}
+
+func (s *Server) handleInspect(w http.ResponseWriter, r *http.Request) error {
+	d, err := s.document(r)
+	if err != nil {
+		return err
+	}
+	sum := Summary{
+		MessageID:       d.MsgID,
+		CreationDate:    d.CreationDateTime,
+		InitiatingParty: d.InitiatingParty.Name,
+		Transactions:    d.TransacNb,
+		CtrlSum:         d.CtrlSum,
+	}
+	for _, p := range d.Payments {
+		ps := PaymentSummary{
+			ID:              p.ID,
+			CollectionDate:  p.RequestedCollectionDate,
+			SequenceType:    p.SequenceType,
+			LocalInstrument: p.LocalInstrument,
+			Transactions:    p.TransacNb,
+			CtrlSum:         p.CtrlSum,
+		}
+		if p.Creditor != nil {
+			ps.Creditor, ps.CreditorID = p.Creditor.Name, p.Creditor.ID
+		}
+		sum.Payments = append(sum.Payments, ps)
+	}
+	writeJSON(w, http.StatusOK, sum)
+	return nil
+}
+
+func (s *Server) handleSplit(w http.ResponseWriter, r *http.Request) error {
+	accept := negotiate(r, "application/zip", "application/json")
+	if accept == "" {
+		return errorf(http.StatusNotAcceptable, "parts are available as application/zip or application/json")
+	}
+	q := r.URL.Query()
+	limits := sepadebit.Limits{}
+	if v := q.Get("max-txs"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return errorf(http.StatusBadRequest, "Invalid max-txs %s", v)
+		}
+		limits.MaxTransactions = n
+	}
+	if v := q.Get("max-amount"); v != "" {
+		amount, err := sepadebit.ParseAmount(v)
+		if err != nil {
+			return err
+		}
+		limits.MaxCtrlSum = amount
+	}
+	doc, err := s.document(r)
+	if err != nil {
+		return err
+	}
+	parts, err := doc.Split(limits)
+	if err != nil {
+		return errorf(http.StatusUnprocessableEntity, "%s", err)
+	}
+	if accept == "application/json" {
+		var rems []*remittance.Remittance
+		for _, part := range parts {
+			rem, err := remittance.FromSEPA(part)
+			if err != nil {
+				return err
+			}
+			rems = append(rems, rem)
+		}
+		writeJSON(w, http.StatusOK, rems)
+		return nil
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for i, part := range parts {
+		f, err := zw.Create(fmt.Sprintf("part-%d.xml", i+1))
+		if err != nil {
+			return err
+		}
+		if err := part.WriteLatin1(f); err != nil {
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="split.zip"`)
+	w.Write(buf.Bytes())
+	return nil
+}
@@ -0,0 +1,161 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apsl/sepakit/sepadebit"
+)
+
+func testServer(t *testing.T, cfg Config) *httptest.Server {
+	cfg.Options = func() sepadebit.Options {
+		return sepadebit.Options{
+			Clock:       sepadebit.FixedClock(time.Date(2013, 12, 17, 17, 29, 52, 0, time.UTC)),
+			IDGenerator: sepadebit.NewSeededIDGenerator(42),
+		}
+	}
+	ts := httptest.NewServer(New(cfg))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func readFile(t *testing.T, path string) []byte {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+//do posts body to path and returns the response status and body
+func do(t *testing.T, ts *httptest.Server, path, contentType, accept string, body []byte) (int, http.Header, []byte) {
+	req, err := http.NewRequest(http.MethodPost, ts.URL+path, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, resp.Header, b
+}
+
+func TestConvert(t *testing.T) {
+	ts := testServer(t, Config{})
+	aeb := readFile(t, "../input-aeb1914.txt")
+	status, header, body := do(t, ts, "/convert", "text/plain", "", aeb)
+	if status != http.StatusOK || header.Get("Content-Type") != "application/xml; charset=iso-8859-1" {
+		t.Fatalf("Unexpected response %d %s: %s", status, header.Get("Content-Type"), body)
+	}
+	if golden := readFile(t, "../convert/testdata/input-aeb1914.golden.xml"); !bytes.Equal(body, golden) {
+		t.Errorf("Output differs from golden file:\n%s", body)
+	}
+
+	status, _, body = do(t, ts, "/convert", "", "application/xml;q=0.5, application/json", aeb)
+	var rem struct{ Creditors []interface{} }
+	if status != http.StatusOK || json.Unmarshal(body, &rem) != nil || len(rem.Creditors) != 1 {
+		t.Errorf("Unexpected JSON response %d: %s", status, body)
+	}
+	if status, _, _ = do(t, ts, "/convert", "", "text/html", aeb); status != http.StatusNotAcceptable {
+		t.Errorf("Expected 406, got %d", status)
+	}
+	if status, _, _ = do(t, ts, "/convert?profile=nobank", "", "", aeb); status != http.StatusBadRequest {
+		t.Errorf("Expected 400 for unknown profile, got %d", status)
+	}
+	if status, _, _ = do(t, ts, "/convert?from=json", "", "", aeb); status != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422 for invalid input, got %d", status)
+	}
+	resp, err := http.Get(ts.URL + "/convert")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405, got %d", resp.StatusCode)
+	}
+}
+
+func TestBodyLimit(t *testing.T) {
+	ts := testServer(t, Config{MaxBodySize: 100})
+	status, _, body := do(t, ts, "/convert", "", "", readFile(t, "../input-aeb1914.txt"))
+	if status != http.StatusRequestEntityTooLarge || !strings.Contains(string(body), "error") {
+		t.Errorf("Expected 413, got %d: %s", status, body)
+	}
+}
+
+func TestValidateAndInspect(t *testing.T) {
+	ts := testServer(t, Config{})
+	aeb := readFile(t, "../input-aeb1914.txt")
+	status, _, body := do(t, ts, "/validate", "", "", aeb)
+	var d Diagnostics
+	if err := json.Unmarshal(body, &d); err != nil || status != http.StatusOK || !d.Valid || d.Transactions != 1 {
+		t.Errorf("Unexpected diagnostics %d: %s", status, body)
+	}
+	status, _, body = do(t, ts, "/validate?lengths=error", "", "", bytes.Replace(aeb, []byte("NOMBRE DEL ACREEDOR, S.L.  "), []byte("NOMBRE DEL ACREEDOR, S.L.&&"), 1))
+	d = Diagnostics{}
+	if err := json.Unmarshal(body, &d); err != nil || status != http.StatusOK || len(d.Transformations) == 0 || d.Transformations[0].Cause != "charset" {
+		t.Errorf("Expected transformations %d: %s", status, body)
+	}
+	status, _, body = do(t, ts, "/validate", "", "", readFile(t, "../convert/testdata/category-purpose.txt"))
+	d = Diagnostics{}
+	if err := json.Unmarshal(body, &d); err != nil || status != http.StatusUnprocessableEntity || d.Valid || len(d.Warnings) != 1 || !strings.Contains(d.Warnings[0], "XXXX") {
+		t.Errorf("Expected the dropped purpose warning %d: %s", status, body)
+	}
+
+	status, _, body = do(t, ts, "/inspect", "", "", aeb)
+	var s Summary
+	if err := json.Unmarshal(body, &s); err != nil || status != http.StatusOK || len(s.Payments) != 1 || s.Payments[0].CollectionDate != "2013-12-20" {
+		t.Errorf("Unexpected summary %d: %s", status, body)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	ts := testServer(t, Config{})
+	status, header, body := do(t, ts, "/split?max-txs=1", "", "", readFile(t, "../input-csb19.txt"))
+	if status != http.StatusOK || header.Get("Content-Type") != "application/zip" {
+		t.Fatalf("Unexpected response %d: %s", status, body)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zr.File) != 3 || zr.File[2].Name != "part-3.xml" {
+		t.Errorf("Expected 3 parts, got %d", len(zr.File))
+	}
+}
+
+func TestCSVMultipart(t *testing.T) {
+	ts := testServer(t, Config{})
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	mw.WriteField("mapping", `{"collectionDate": "2024-01-10",
+		"creditor": {"name": "EMPRESA", "id": "ES18000B07123456", "iban": "ES9121000418450200051332"},
+		"columns": {"iban": "iban", "amount": "amount", "mandateId": "mandate", "mandateDate": "signed", "name": "name"}}`)
+	fw, _ := mw.CreateFormFile("file", "debits.csv")
+	fw.Write([]byte("iban,amount,mandate,signed,name\nES7620770024003102575766,10.50,M-1,2012-03-15,JUAN\n"))
+	mw.Close()
+	status, _, body := do(t, ts, "/inspect?from=csv", mw.FormDataContentType(), "", buf.Bytes())
+	var s Summary
+	if err := json.Unmarshal(body, &s); err != nil || status != http.StatusOK || s.CtrlSum != "10.50" {
+		t.Errorf("Unexpected summary %d: %s", status, body)
+	}
+}
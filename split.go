@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/apsl/sepakit/convert"
+	"github.com/apsl/sepakit/sepadebit"
+)
+
+//runSplit implements "sepakit split": converts an AEB 19.14 file and writes
+//it as several SEPA XML files honoring transaction and amount limits
+func runSplit(args []string) error {
+	fs := flag.NewFlagSet("split", flag.ExitOnError)
+	maxTxs := fs.Int("max-txs", 0, "maximum number of transactions per file (0 = unlimited)")
+	maxAmount := fs.String("max-amount", "", "maximum CtrlSum per file, e.g. 50000.00")
+	prefix := fs.String("o", "", "output files prefix, files are written as PREFIX-N.xml (defaults to input name)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Splits an AEB 19.14 TXT file into several SEPA XML files\nUsage: %s split [options] [INFILE]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	paths := parseArgs(fs, args)
+
+	inpath := "-"
+	if len(paths) > 0 {
+		inpath = paths[0]
+	}
+	limits := sepadebit.Limits{MaxTransactions: *maxTxs}
+	if *maxAmount != "" {
+		amount, err := sepadebit.ParseAmount(*maxAmount)
+		if err != nil {
+			return err
+		}
+		limits.MaxCtrlSum = amount
+	}
+	if *prefix == "" {
+		*prefix = "sepakit"
+		if inpath != "-" {
+			*prefix = strings.TrimSuffix(inpath, filepath.Ext(inpath))
+		}
+	}
+	*prefix = strings.TrimSuffix(*prefix, ".xml")
+
+	fin, err := openInput(inpath)
+	if err != nil {
+		return err
+	}
+	defer fin.Close()
+	doc, err := convert.Latin1DebitTxtToXMLDoc(fin)
+	if err != nil {
+		return err
+	}
+	parts, err := doc.Split(limits)
+	if err != nil {
+		return err
+	}
+	for i, part := range parts {
+		outpath := fmt.Sprintf("%s-%d.xml", *prefix, i+1)
+		if err := writeXMLFile(outpath, part); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "%s: %d transactions, %s\n", outpath, part.TransacNb, part.CtrlSum)
+	}
+	return nil
+}
+
+//writeXMLFile writes doc to path in ISO-8859-1
+func writeXMLFile(path string, doc *sepadebit.Document) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("Cannot open file %s for writing: %s", path, err)
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	if err := doc.WriteLatin1(w); err != nil {
+		return err
+	}
+	return w.Flush()
+}
@@ -0,0 +1,237 @@
+package tabular
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+//Mapping tells how the columns of a spreadsheet map to debits. As a YAML
+//file:
+//
+//	delimiter: ";"
+//	decimal: ","
+//	dateFormat: DD/MM/YYYY
+//	collectionDate: 2024-01-10
+//	creditor:
+//	  name: EMPRESA, S.L.
+//	  id: ES18000B07123456
+//	  iban: ES9121000418450200051332
+//	  bic: CAIXESBBXXX
+//	columns:
+//	  iban: IBAN
+//	  amount: Importe
+//	  mandateId: Mandato
+//	  mandateDate: Fecha firma
+//	  name: Titular
+//	  concept: Concepto
+//
+//Columns are referenced by header name, letter (A, B...) or number (1, 2...)
+type Mapping struct {
+	//Delimiter of CSV fields, defaults to ","
+	Delimiter string `json:"delimiter"`
+	//Header tells whether the first row holds column names, defaults to true
+	Header *bool `json:"header"`
+	//Sheet is the XLSX sheet name, defaults to the first one
+	Sheet string `json:"sheet"`
+	//Decimal separator of amounts, "." (default) or ",". The other one is
+	//only accepted as thousands separator between groups of 3 digits:
+	//1.234,56. Elsewhere it is a row error
+	Decimal string `json:"decimal"`
+	//DateFormat of dates using DD, MM, YY and YYYY, defaults to YYYY-MM-DD
+	DateFormat string `json:"dateFormat"`
+	//CollectionDate of every debit, when there is no collection date column
+	CollectionDate string `json:"collectionDate"`
+	//SequenceType of every debit, when there is no column. Defaults to RCUR
+	SequenceType string   `json:"sequenceType"`
+	Creditor     Creditor `json:"creditor"`
+	Columns      Columns  `json:"columns"`
+}
+
+//Creditor is the creditor of every debit
+type Creditor struct {
+	Name string `json:"name"`
+	ID   string `json:"id"`
+	IBAN string `json:"iban"`
+	BIC  string `json:"bic"`
+}
+
+//Columns references the columns of each debit field. IBAN, Amount,
+//MandateID and MandateDate are required
+type Columns struct {
+	ID             string `json:"id"`
+	Name           string `json:"name"`
+	IBAN           string `json:"iban"`
+	BIC            string `json:"bic"`
+	Amount         string `json:"amount"`
+	MandateID      string `json:"mandateId"`
+	MandateDate    string `json:"mandateDate"`
+	CollectionDate string `json:"collectionDate"`
+	SequenceType   string `json:"sequenceType"`
+	Concept        string `json:"concept"`
+	Purpose        string `json:"purpose"`
+}
+
+//LoadMapping reads a Mapping, either JSON or YAML. Only the YAML needed by
+//mappings is supported: "key: value" pairs, nested by indentation, and
+//# comments
+func LoadMapping(r io.Reader) (*Mapping, error) {
+	br := bufio.NewReader(r)
+	var data []byte
+	first, err := br.Peek(1)
+	if err == nil && first[0] == '{' {
+		if data, err = ioutil.ReadAll(br); err != nil {
+			return nil, err
+		}
+	} else {
+		values, err := parseYAML(br)
+		if err != nil {
+			return nil, err
+		}
+		if data, err = json.Marshal(values); err != nil {
+			return nil, err
+		}
+	}
+	m := &Mapping{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("Invalid mapping: %s", err)
+	}
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *Mapping) validate() error {
+	c := m.Columns
+	for field, column := range map[string]string{"iban": c.IBAN, "amount": c.Amount, "mandateId": c.MandateID, "mandateDate": c.MandateDate} {
+		if column == "" {
+			return fmt.Errorf("Mapping: missing %s column", field)
+		}
+	}
+	if c.CollectionDate == "" && m.CollectionDate == "" {
+		return fmt.Errorf("Mapping: missing collectionDate column or value")
+	}
+	if m.Creditor.Name == "" || m.Creditor.ID == "" || m.Creditor.IBAN == "" {
+		return fmt.Errorf("Mapping: creditor name, id and iban are required")
+	}
+	switch m.Decimal {
+	case "", ".", ",":
+	default:
+		return fmt.Errorf("Mapping: decimal separator must be . or ,")
+	}
+	if len([]rune(m.Delimiter)) > 1 {
+		return fmt.Errorf("Mapping: delimiter must be a single character")
+	}
+	return nil
+}
+
+func (m *Mapping) header() bool {
+	return m.Header == nil || *m.Header
+}
+
+//yamlLine is a "key: value" line and its indentation
+type yamlLine struct {
+	n      int
+	indent int
+	key    string
+	value  string
+}
+
+//parseYAML reads nested "key: value" maps
+func parseYAML(r io.Reader) (map[string]interface{}, error) {
+	var lines []yamlLine
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		text := strings.TrimRight(scanner.Text(), " \t\r")
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || trimmed == "---" {
+			continue
+		}
+		i := strings.Index(trimmed, ":")
+		if i <= 0 {
+			return nil, fmt.Errorf("Mapping line %d: expected key: value", n)
+		}
+		lines = append(lines, yamlLine{
+			n:      n,
+			indent: len(text) - len(trimmed),
+			key:    strings.TrimSpace(trimmed[:i]),
+			value:  strings.TrimSpace(trimmed[i+1:]),
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	values, rest, err := yamlMap(lines, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("Mapping line %d: bad indentation", rest[0].n)
+	}
+	return values, nil
+}
+
+//yamlMap reads the lines with indent, returning the lines left
+func yamlMap(lines []yamlLine, indent int) (map[string]interface{}, []yamlLine, error) {
+	values := map[string]interface{}{}
+	for len(lines) > 0 && lines[0].indent == indent {
+		l := lines[0]
+		lines = lines[1:]
+		if l.value != "" {
+			v, err := yamlScalar(l)
+			if err != nil {
+				return nil, nil, err
+			}
+			values[l.key] = v
+			continue
+		}
+		if len(lines) == 0 || lines[0].indent <= indent {
+			values[l.key] = nil
+			continue
+		}
+		nested, rest, err := yamlMap(lines, lines[0].indent)
+		if err != nil {
+			return nil, nil, err
+		}
+		values[l.key] = nested
+		lines = rest
+	}
+	if len(lines) > 0 && lines[0].indent > indent {
+		return nil, nil, fmt.Errorf("Mapping line %d: bad indentation", lines[0].n)
+	}
+	return values, lines, nil
+}
+
+//yamlScalar returns quoted strings unquoted, true and false as booleans and
+//anything else as a string, dropping trailing comments
+func yamlScalar(l yamlLine) (interface{}, error) {
+	v := l.value
+	switch v[0] {
+	case '"':
+		s, err := strconv.Unquote(v)
+		if err != nil {
+			return nil, fmt.Errorf("Mapping line %d: %s", l.n, err)
+		}
+		return s, nil
+	case '\'':
+		if len(v) < 2 || v[len(v)-1] != '\'' {
+			return nil, fmt.Errorf("Mapping line %d: unterminated string", l.n)
+		}
+		return strings.Replace(v[1:len(v)-1], "''", "'", -1), nil
+	}
+	if i := strings.Index(v, " #"); i >= 0 {
+		v = strings.TrimSpace(v[:i])
+	}
+	switch v {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	return v, nil
+}
@@ -0,0 +1,217 @@
+package tabular
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+//ReadCSV reads the rows of a UTF-8 CSV file. A BOM is skipped
+func ReadCSV(r io.Reader, delimiter rune) ([][]string, error) {
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(r); err != nil {
+		return nil, err
+	}
+	cr := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(buf.Bytes(), []byte("\xEF\xBB\xBF"))))
+	if delimiter != 0 {
+		cr.Comma = delimiter
+	}
+	cr.FieldsPerRecord = -1
+	return cr.ReadAll()
+}
+
+//isXLSX reports whether data is a zip file, as XLSX files are
+func isXLSX(data []byte) bool {
+	return bytes.HasPrefix(data, []byte("PK\x03\x04"))
+}
+
+type xlsxWorkbook struct {
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+		RID  string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+//xlsxText is a shared string or inline string, either plain or rich text
+type xlsxText struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxText) String() string {
+	s := t.T
+	for _, r := range t.Runs {
+		s += r.T
+	}
+	return s
+}
+
+type xlsxSharedStrings struct {
+	Items []xlsxText `xml:"si"`
+}
+
+type xlsxSheet struct {
+	Rows []struct {
+		Cells []struct {
+			Ref    string   `xml:"r,attr"`
+			Type   string   `xml:"t,attr"`
+			Value  string   `xml:"v"`
+			Inline xlsxText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+//ReadXLSX reads the rows of a sheet of an XLSX file, the first one if sheet
+//is empty. Numbers are returned with decimal as decimal separator, and dates
+//as the Excel serial numbers they are stored as
+func ReadXLSX(data []byte, sheet string, decimal string) ([][]string, error) {
+	rows, _, err := readXLSX(data, sheet, decimal)
+	return rows, err
+}
+
+//cell is the 0 based position of a spreadsheet cell
+type cell struct {
+	row, col int
+}
+
+//readXLSX reads the rows of a sheet as ReadXLSX, and the numeric cells,
+//which may hold dates as serial numbers
+func readXLSX(data []byte, sheet string, decimal string) ([][]string, map[cell]bool, error) {
+	if decimal == "" {
+		decimal = "."
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("Invalid XLSX file: %s", err)
+	}
+	files := map[string]*zip.File{}
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+	decode := func(name string, v interface{}) error {
+		f, ok := files[name]
+		if !ok {
+			return fmt.Errorf("Invalid XLSX file: missing %s", name)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		return xml.NewDecoder(rc).Decode(v)
+	}
+
+	var wb xlsxWorkbook
+	if err := decode("xl/workbook.xml", &wb); err != nil {
+		return nil, nil, err
+	}
+	var rels xlsxRelationships
+	if err := decode("xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return nil, nil, err
+	}
+	rid := ""
+	for _, s := range wb.Sheets {
+		if sheet == "" || s.Name == sheet {
+			rid = s.RID
+			break
+		}
+	}
+	if rid == "" {
+		return nil, nil, fmt.Errorf("XLSX sheet %q not found", sheet)
+	}
+	sheetPath := ""
+	for _, r := range rels.Relationships {
+		if r.ID == rid {
+			sheetPath = r.Target
+			if strings.HasPrefix(sheetPath, "/") {
+				sheetPath = sheetPath[1:]
+			} else {
+				sheetPath = path.Join("xl", sheetPath)
+			}
+		}
+	}
+	var shared xlsxSharedStrings
+	if _, ok := files["xl/sharedStrings.xml"]; ok {
+		if err := decode("xl/sharedStrings.xml", &shared); err != nil {
+			return nil, nil, err
+		}
+	}
+	var ws xlsxSheet
+	if err := decode(sheetPath, &ws); err != nil {
+		return nil, nil, err
+	}
+
+	var rows [][]string
+	numeric := map[cell]bool{}
+	for _, r := range ws.Rows {
+		var row []string
+		for i, c := range r.Cells {
+			col := i
+			if c.Ref != "" {
+				if col, err = columnIndex(strings.TrimRight(c.Ref, "0123456789")); err != nil {
+					return nil, nil, fmt.Errorf("Invalid XLSX cell %s", c.Ref)
+				}
+			}
+			for len(row) <= col {
+				row = append(row, "")
+			}
+			switch c.Type {
+			case "s":
+				n, err := strconv.Atoi(c.Value)
+				if err != nil || n < 0 || n >= len(shared.Items) {
+					return nil, nil, fmt.Errorf("Invalid XLSX shared string %s on cell %s", c.Value, c.Ref)
+				}
+				row[col] = shared.Items[n].String()
+			case "inlineStr":
+				row[col] = c.Inline.String()
+			case "str", "b", "e":
+				row[col] = c.Value
+			default:
+				row[col] = strings.Replace(c.Value, ".", decimal, 1)
+				numeric[cell{len(rows), col}] = true
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, numeric, nil
+}
+
+//maxColumns is the number of columns of a sheet, A to XFD
+const maxColumns = 16384
+
+//columnIndex returns the 0 based index of a column letter (A, B... AA).
+//Columns beyond XFD are an error, rather than a row of that many cells
+func columnIndex(letters string) (int, error) {
+	if letters == "" {
+		return 0, fmt.Errorf("Empty column")
+	}
+	if len(letters) > 3 {
+		return 0, fmt.Errorf("Invalid column %s", letters)
+	}
+	n := 0
+	for _, r := range strings.ToUpper(letters) {
+		if r < 'A' || r > 'Z' {
+			return 0, fmt.Errorf("Invalid column %s", letters)
+		}
+		n = n*26 + int(r-'A'+1)
+	}
+	if n > maxColumns {
+		return 0, fmt.Errorf("Invalid column %s", letters)
+	}
+	return n - 1, nil
+}
@@ -0,0 +1,279 @@
+//Package tabular imports direct debits from CSV and XLSX spreadsheets,
+//mapping their columns to debit fields with a Mapping
+package tabular
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apsl/sepakit/ccc"
+	"github.com/apsl/sepakit/sepadebit"
+)
+
+//Debit is a debit read from a spreadsheet row
+type Debit struct {
+	//Row is the 1 based row number, for error messages
+	Row            int
+	ID             string
+	Name           string
+	IBAN           string
+	BIC            string
+	Amount         int64 // cents
+	MandateID      string
+	MandateDate    time.Time
+	CollectionDate time.Time
+	SequenceType   string
+	Concept        string
+	Purpose        string
+}
+
+//Document holds the debits of a spreadsheet
+type Document struct {
+	Creditor Creditor
+	Debits   []*Debit
+}
+
+//RowError lists the invalid rows of a spreadsheet
+type RowError []string
+
+func (e RowError) Error() string {
+	return fmt.Sprintf("%d invalid rows:\n%s", len(e), strings.Join(e, "\n"))
+}
+
+//Parse reads a CSV or XLSX spreadsheet, detected by its contents, with the
+//columns mapped by m. Every invalid row is reported in a RowError
+func Parse(r io.Reader, m *Mapping) (*Document, error) {
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(r); err != nil {
+		return nil, err
+	}
+	var rows [][]string
+	var numeric map[cell]bool
+	var err error
+	if isXLSX(buf.Bytes()) {
+		rows, numeric, err = readXLSX(buf.Bytes(), m.Sheet, m.Decimal)
+	} else {
+		var delimiter rune
+		if m.Delimiter != "" {
+			delimiter = []rune(m.Delimiter)[0]
+		}
+		rows, err = ReadCSV(&buf, delimiter)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseRows(rows, numeric, m)
+}
+
+//parseRows reads the debits of rows. Dates are also accepted as Excel
+//serial numbers in the numeric cells of XLSX files
+func parseRows(rows [][]string, numeric map[cell]bool, m *Mapping) (*Document, error) {
+	var header []string
+	first := 1
+	if m.header() && len(rows) > 0 {
+		header = rows[0]
+		rows = rows[1:]
+		first = 2
+	}
+	columns := map[string]int{}
+	c := m.Columns
+	for _, ref := range []string{c.ID, c.Name, c.IBAN, c.BIC, c.Amount, c.MandateID, c.MandateDate, c.CollectionDate, c.SequenceType, c.Concept, c.Purpose} {
+		if ref == "" {
+			continue
+		}
+		i, err := column(header, ref)
+		if err != nil {
+			return nil, err
+		}
+		columns[ref] = i
+	}
+	get := func(row []string, ref string) string {
+		i, ok := columns[ref]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+	layout := dateLayout(m.DateFormat)
+	//getDate parses the date of row n
+	getDate := func(n int, row []string, ref string) (time.Time, error) {
+		i, ok := columns[ref]
+		return parseDate(get(row, ref), layout, ok && numeric[cell{first - 1 + n, i}])
+	}
+	var defaultDate time.Time
+	if m.CollectionDate != "" {
+		d, err := parseDate(m.CollectionDate, layout, false)
+		if err != nil {
+			return nil, fmt.Errorf("Mapping collectionDate: %s", err)
+		}
+		defaultDate = d
+	}
+
+	doc := &Document{Creditor: m.Creditor}
+	var rowErrors RowError
+	for n, row := range rows {
+		if isEmpty(row) {
+			continue
+		}
+		d := &Debit{
+			Row:          first + n,
+			ID:           get(row, c.ID),
+			Name:         get(row, c.Name),
+			IBAN:         strings.ToUpper(strings.Replace(get(row, c.IBAN), " ", "", -1)),
+			BIC:          get(row, c.BIC),
+			MandateID:    get(row, c.MandateID),
+			SequenceType: get(row, c.SequenceType),
+			Concept:      get(row, c.Concept),
+			Purpose:      get(row, c.Purpose),
+		}
+		var errs []string
+		fail := func(format string, args ...interface{}) {
+			errs = append(errs, fmt.Sprintf(format, args...))
+		}
+		var err error
+		if d.Amount, err = parseAmount(get(row, c.Amount), m.Decimal); err != nil {
+			fail("%s", err)
+		} else if d.Amount <= 0 {
+			fail("amount must be positive")
+		}
+		if d.MandateDate, err = getDate(n, row, c.MandateDate); err != nil {
+			fail("mandate date: %s", err)
+		}
+		d.CollectionDate = defaultDate
+		if c.CollectionDate != "" {
+			if d.CollectionDate, err = getDate(n, row, c.CollectionDate); err != nil {
+				fail("collection date: %s", err)
+			}
+		}
+		if d.IBAN == "" {
+			fail("missing IBAN")
+		} else if err := ccc.ValidateIBAN(d.IBAN); err != nil {
+			fail("%s", err)
+		}
+		if d.MandateID == "" {
+			fail("missing mandate ID")
+		}
+		if d.SequenceType == "" {
+			d.SequenceType = m.SequenceType
+		}
+		if d.SequenceType == "" {
+			d.SequenceType = "RCUR"
+		}
+		switch d.SequenceType {
+		case "FRST", "RCUR", "FNAL", "OOFF":
+		default:
+			fail("invalid sequence type %s", d.SequenceType)
+		}
+		if len(errs) > 0 {
+			rowErrors = append(rowErrors, fmt.Sprintf("row %d: %s", d.Row, strings.Join(errs, ", ")))
+			continue
+		}
+		doc.Debits = append(doc.Debits, d)
+	}
+	if len(rowErrors) > 0 {
+		return nil, rowErrors
+	}
+	if len(doc.Debits) == 0 {
+		return nil, fmt.Errorf("No debits found")
+	}
+	return doc, nil
+}
+
+//column returns the index of a column referenced by header name, letter or number
+func column(header []string, ref string) (int, error) {
+	for i, name := range header {
+		if strings.EqualFold(strings.TrimSpace(name), ref) {
+			return i, nil
+		}
+	}
+	if n, err := strconv.Atoi(ref); err == nil && n > 0 {
+		return n - 1, nil
+	}
+	if i, err := columnIndex(ref); err == nil && len(ref) <= 3 {
+		return i, nil
+	}
+	return 0, fmt.Errorf("Column %s not found", ref)
+}
+
+func isEmpty(row []string) bool {
+	for _, s := range row {
+		if strings.TrimSpace(s) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+//parseAmount parses an amount in cents with the decimal separator decimal.
+//The other separator is only accepted as thousands separator, between groups
+//of 3 digits before the decimal separator, so that "12,50" is not read as
+//1250 euros with the decimal point
+func parseAmount(s, decimal string) (int64, error) {
+	s = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(s), "€"))
+	s = strings.Replace(s, " ", "", -1)
+	if s == "" {
+		return 0, fmt.Errorf("missing amount")
+	}
+	thousands := ","
+	if decimal == "," {
+		thousands = "."
+	} else {
+		decimal = "."
+	}
+	units, decimals := s, ""
+	if i := strings.Index(s, decimal); i >= 0 {
+		units, decimals = s[:i], "."+s[i+1:]
+	}
+	if strings.Contains(units, thousands) {
+		groups := strings.Split(strings.TrimPrefix(units, "-"), thousands)
+		if !thousandGroups.MatchString(groups[0]) {
+			return 0, fmt.Errorf("Invalid amount %q, misplaced thousands separator %s", s, thousands)
+		}
+		for _, g := range groups[1:] {
+			if len(g) != 3 || !thousandGroups.MatchString(g) {
+				return 0, fmt.Errorf("Invalid amount %q, misplaced thousands separator %s", s, thousands)
+			}
+		}
+		units = strings.Replace(units, thousands, "", -1)
+	}
+	return sepadebit.ParseAmount(units + decimals)
+}
+
+//thousandGroups matches a group of digits between thousands separators
+var thousandGroups = regexp.MustCompile(`^[0-9]{1,3}$`)
+
+//dateLayout converts a DD/MM/YYYY format to a time layout
+func dateLayout(format string) string {
+	if format == "" {
+		return "2006-01-02"
+	}
+	return strings.NewReplacer("YYYY", "2006", "YY", "06", "MM", "01", "DD", "02").Replace(format)
+}
+
+//excelEpoch is the day 0 of Excel serial dates
+var excelEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+//parseDate parses s with layout, or as an Excel serial date when serial is
+//set, for the numeric cells of XLSX files
+func parseDate(s, layout string, serial bool) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, fmt.Errorf("missing date")
+	}
+	t, err := time.Parse(layout, s)
+	if err == nil {
+		return t, nil
+	}
+	if !serial {
+		return time.Time{}, fmt.Errorf("invalid date %s, expected %s", s, layout)
+	}
+	if days, serr := strconv.ParseFloat(strings.Replace(s, ",", ".", 1), 64); serr == nil && days > 0 && days < 100000 {
+		return excelEpoch.AddDate(0, 0, int(math.Floor(days))), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid date %s, expected %s", s, layout)
+}
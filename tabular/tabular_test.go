@@ -0,0 +1,196 @@
+package tabular
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const testMapping = `# debits of a small client
+delimiter: ";"
+decimal: ","
+dateFormat: DD/MM/YYYY
+collectionDate: 10/01/2024
+creditor:
+  name: EMPRESA, S.L.
+  id: ES18000B07123456
+  iban: ES9121000418450200051332
+columns:
+  iban: IBAN
+  amount: Importe   # euros
+  mandateId: Mandato
+  mandateDate: Fecha firma
+  name: C
+  concept: "Concepto"
+`
+
+const testCSV = "\xEF\xBB\xBFIBAN;Importe;Titular;Mandato;Fecha firma;Concepto\n" +
+	"ES76 2077 0024 0031 0257 5766;1.234,56;JUAN GARCIA;M-001;15/03/2012;CUOTA ENERO\n" +
+	";;;;;\n" +
+	"ES7620770024003102575766;60,5;MARIA LOPEZ;M-002;01/02/2013;\n"
+
+func loadTestMapping(t *testing.T) *Mapping {
+	m, err := LoadMapping(strings.NewReader(testMapping))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestLoadMapping(t *testing.T) {
+	m := loadTestMapping(t)
+	if m.Delimiter != ";" || m.Decimal != "," || m.Creditor.Name != "EMPRESA, S.L." || m.Columns.Amount != "Importe" || m.Columns.Concept != "Concepto" || !m.header() {
+		t.Errorf("Unexpected mapping %+v", m)
+	}
+	json, err := LoadMapping(strings.NewReader(`{"header": false, "collectionDate": "2024-01-10",
+		"creditor": {"name": "E", "id": "ES18000B07123456", "iban": "ES9121000418450200051332"},
+		"columns": {"iban": "A", "amount": "B", "mandateId": "C", "mandateDate": "D"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if json.header() || json.Columns.IBAN != "A" {
+		t.Errorf("Unexpected mapping %+v", json)
+	}
+	if _, err := LoadMapping(strings.NewReader("columns:\n  iban: IBAN\n")); err == nil {
+		t.Error("Expected error for missing columns")
+	}
+}
+
+func TestParseCSV(t *testing.T) {
+	doc, err := Parse(strings.NewReader(testCSV), loadTestMapping(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Debits) != 2 {
+		t.Fatalf("Expected 2 debits, got %d", len(doc.Debits))
+	}
+	d := doc.Debits[0]
+	if d.IBAN != "ES7620770024003102575766" || d.Amount != 123456 || d.Name != "JUAN GARCIA" || d.MandateDate.Format("2006-01-02") != "2012-03-15" || d.CollectionDate.Format("2006-01-02") != "2024-01-10" || d.SequenceType != "RCUR" {
+		t.Errorf("Unexpected debit %+v", d)
+	}
+	if d := doc.Debits[1]; d.Amount != 6050 || d.Row != 4 {
+		t.Errorf("Unexpected debit %+v", d)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	csv := testCSV + "ES7620770024003102575766;abc;X;M-003;01/02/2013;\n;10;Y;;2013-02-01;\n"
+	_, err := Parse(strings.NewReader(csv), loadTestMapping(t))
+	rowErrors, ok := err.(RowError)
+	if !ok || len(rowErrors) != 2 || !strings.HasPrefix(rowErrors[0], "row 5:") || !strings.Contains(rowErrors[1], "missing IBAN") {
+		t.Errorf("Unexpected error %v", err)
+	}
+}
+
+func TestParseStrict(t *testing.T) {
+	csv := testCSV + "ES7620770024003102575767;10;X;M-003;01/02/2013;\n" +
+		"ES7620770024003102575766;10;Y;M-004;40983;\n"
+	_, err := Parse(strings.NewReader(csv), loadTestMapping(t))
+	rowErrors, ok := err.(RowError)
+	if !ok || len(rowErrors) != 2 || !strings.Contains(rowErrors[0], "check digits") || !strings.Contains(rowErrors[1], "invalid date 40983") {
+		t.Errorf("Expected a wrong IBAN and a serial date in CSV rejected, got %v", err)
+	}
+	if strings.Contains(err.Error(), "ES7620770024003102575767") {
+		t.Errorf("Expected the IBAN masked in %s", err)
+	}
+}
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		s, decimal string
+		cents      int64
+	}{
+		{"12.50", ".", 1250},
+		{"12,50", ",", 1250},
+		{"1,234.56", ".", 123456},
+		{"1.234,56", ",", 123456},
+		{"1,234,567", ".", 123456700},
+		{"12 €", "", 1200},
+		{"60,5", ",", 6050},
+		{"12,50", ".", -1},
+		{"12,50", "", -1},
+		{"12.50", ",", -1},
+		{"1,2,3", ".", -1},
+		{"1.2.3", ",", -1},
+		{"1.234.56", ".", -1},
+		{"1,234,56", ",", -1},
+		{",500", ".", -1},
+		{"1234,567.00", ".", -1},
+		{"12.345,6.7", ",", -1},
+		{"", ".", -1},
+	}
+	for _, test := range tests {
+		cents, err := parseAmount(test.s, test.decimal)
+		if test.cents < 0 && err == nil {
+			t.Errorf("%q with decimal %q: expected an error, got %d", test.s, test.decimal, cents)
+		}
+		if test.cents >= 0 && (err != nil || cents != test.cents) {
+			t.Errorf("%q with decimal %q: expected %d, got %d (%v)", test.s, test.decimal, test.cents, cents, err)
+		}
+	}
+
+	csv := testCSV + "ES7620770024003102575766;12.50;X;M-003;01/02/2013;\n"
+	_, err := Parse(strings.NewReader(csv), loadTestMapping(t))
+	if rowErrors, ok := err.(RowError); !ok || len(rowErrors) != 1 || !strings.HasPrefix(rowErrors[0], "row 5:") {
+		t.Errorf("Expected a row error for 12.50 with decimal comma, got %v", err)
+	}
+}
+
+//testXLSX builds a minimal XLSX file with shared, inline and numeric cells,
+//with the old, new string pairs of replacements replaced in its sheet
+func testXLSX(t *testing.T, replacements ...string) []byte {
+	files := map[string]string{
+		"xl/workbook.xml": `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets><sheet name="Recibos" sheetId="1" r:id="rId1"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/></Relationships>`,
+		"xl/sharedStrings.xml": `<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+			`<si><t>IBAN</t></si><si><t>Importe</t></si><si><r><t>Man</t></r><r><t>dato</t></r></si><si><t>Fecha firma</t></si></sst>`,
+		"xl/worksheets/sheet1.xml": `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>` +
+			`<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c><c r="C1" t="s"><v>2</v></c><c r="E1" t="s"><v>3</v></c></row>` +
+			`<row r="2"><c r="A2" t="inlineStr"><is><t>ES7620770024003102575766</t></is></c><c r="B2"><v>1234.56</v></c>` +
+			`<c r="C2" t="str"><v>M-001</v></c><c r="E2"><v>40983</v></c></row>` +
+			`</sheetData></worksheet>`,
+	}
+	files["xl/worksheets/sheet1.xml"] = strings.NewReplacer(replacements...).Replace(files["xl/worksheets/sheet1.xml"])
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestParseXLSX(t *testing.T) {
+	m := loadTestMapping(t)
+	m.Columns.Concept = ""
+	doc, err := Parse(bytes.NewReader(testXLSX(t)), m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := doc.Debits[0]
+	if d.Amount != 123456 || d.MandateID != "M-001" || d.MandateDate.Format("2006-01-02") != "2012-03-15" {
+		t.Errorf("Unexpected debit %+v", d)
+	}
+
+	//a serial number in a text cell is not a date
+	data := testXLSX(t, `<c r="E2"><v>40983</v></c>`, `<c r="E2" t="str"><v>40983</v></c>`)
+	if _, err := Parse(bytes.NewReader(data), m); err == nil || !strings.Contains(err.Error(), "invalid date") {
+		t.Errorf("Expected an invalid date error, got %v", err)
+	}
+	//columns beyond XFD, which overflowed the column index
+	for _, ref := range []string{"ZZZZZZZZZZZZZZ2", "XFE2"} {
+		data := testXLSX(t, `r="B2"`, `r="`+ref+`"`)
+		if _, err := Parse(bytes.NewReader(data), m); err == nil || !strings.Contains(err.Error(), "Invalid XLSX cell") {
+			t.Errorf("Expected an invalid cell error for %s, got %v", ref, err)
+		}
+	}
+}
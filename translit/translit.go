@@ -0,0 +1,166 @@
+//Package translit cleans free text for SEPA messages. It implements the EPC
+//"SEPA basic Latin" character set, optional extended country sets and the
+//usual transliterations (ß -> ss, & -> +), reporting every altered field.
+package translit
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+//Set is a set of characters allowed as is
+type Set struct {
+	Name  string
+	runes map[rune]bool
+}
+
+//NewSet returns a Set with the given characters
+func NewSet(name, chars string) *Set {
+	s := &Set{Name: name, runes: map[rune]bool{}}
+	for _, r := range chars {
+		s.runes[r] = true
+	}
+	return s
+}
+
+//Contains reports whether r is in the set
+func (s *Set) Contains(r rune) bool {
+	return s.runes[r]
+}
+
+var (
+	//Basic is the EPC SEPA basic Latin character set
+	Basic = NewSet("basic", "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789/-?:().,'+ ")
+	//Spanish adds the Spanish letters to the basic set
+	Spanish = NewSet("es", "ÑñÇçÁÉÍÓÚÜáéíóúü")
+	//German adds umlauts and ß to the basic set
+	German = NewSet("de", "ÄÖÜäöüß")
+)
+
+//Sets are the extended sets by name, as accepted by SetsByName
+var Sets = map[string]*Set{
+	Spanish.Name: Spanish,
+	German.Name:  German,
+}
+
+//SetsByName returns the extended sets from a comma separated list of names ("es,de")
+func SetsByName(names string) ([]*Set, error) {
+	var sets []*Set
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		s, ok := Sets[name]
+		if !ok {
+			return nil, fmt.Errorf("Unknown character set %s", name)
+		}
+		sets = append(sets, s)
+	}
+	return sets, nil
+}
+
+//Mappings are the explicit transliterations for characters out of the allowed sets
+var Mappings = map[rune]string{
+	'ß': "ss", '&': "+", '€': "EUR", 'Ø': "O", 'ø': "o", 'Æ': "AE", 'æ': "ae",
+	'Œ': "OE", 'œ': "oe", 'Ð': "D", 'ð': "d", 'Þ': "TH", 'þ': "th", 'Ł': "L",
+	'ł': "l", 'ª': "a", 'º': "o", '_': "-", '"': "'", '`': "'", '´': "'",
+	'‘': "'", '’': "'", '“': "'", '”': "'", '«': "'", '»': "'", ';': ",",
+	'!': ".", '[': "(", ']': ")", '{': "(", '}': ")", '\t': " ", '\n': " ",
+	'\r': " ",
+}
+
+//Alteration records a field modified by the Transliterator
+type Alteration struct {
+	Field    string `json:"field"`
+	Original string `json:"original"`
+	Result   string `json:"result"`
+}
+
+//Transliterator converts text to the allowed sets. The alterations made by
+//Field are kept until Reset, so a Transliterator reused across documents
+//must be reset after each one
+type Transliterator struct {
+	sets []*Set
+	//Replacement is written for characters that can not be transliterated
+	Replacement string
+
+	mu          sync.Mutex
+	alterations []Alteration
+}
+
+//New returns a Transliterator allowing the basic set plus the extended sets
+func New(sets ...*Set) *Transliterator {
+	return &Transliterator{sets: append([]*Set{Basic}, sets...)}
+}
+
+func (t *Transliterator) allowed(r rune) bool {
+	for _, s := range t.sets {
+		if s.Contains(r) {
+			return true
+		}
+	}
+	return false
+}
+
+//String returns s converted to the allowed sets
+func (t *Transliterator) String(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if t.allowed(r) {
+			b.WriteRune(r)
+			continue
+		}
+		if m, ok := Mappings[r]; ok {
+			b.WriteString(m)
+			continue
+		}
+		b.WriteString(t.decompose(r))
+	}
+	return b.String()
+}
+
+//decompose removes diacritics from r (Ñ -> N), or returns the Replacement
+func (t *Transliterator) decompose(r rune) string {
+	var b strings.Builder
+	for _, d := range norm.NFD.String(string(r)) {
+		if unicode.Is(unicode.Mn, d) { // Mn: nonspacing marks
+			continue
+		}
+		if !t.allowed(d) {
+			return t.Replacement
+		}
+		b.WriteRune(d)
+	}
+	return b.String()
+}
+
+//Field returns value converted to the allowed sets, recording an Alteration
+//for field when it changes
+func (t *Transliterator) Field(field, value string) string {
+	result := t.String(value)
+	if result != value {
+		t.mu.Lock()
+		t.alterations = append(t.alterations, Alteration{Field: field, Original: value, Result: result})
+		t.mu.Unlock()
+	}
+	return result
+}
+
+//Alterations returns every field altered so far
+func (t *Transliterator) Alterations() []Alteration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Alteration(nil), t.alterations...)
+}
+
+//Reset forgets the recorded alterations
+func (t *Transliterator) Reset() {
+	t.mu.Lock()
+	t.alterations = nil
+	t.mu.Unlock()
+}
@@ -0,0 +1,35 @@
+package translit
+
+import "testing"
+
+func TestString(t *testing.T) {
+	cases := []struct {
+		sets     []*Set
+		in, want string
+	}{
+		{nil, "PEÑA & CÍA, S.L.", "PENA + CIA, S.L."},
+		{nil, "Straße Ørsted 5€", "Strasse Orsted 5EUR"},
+		{nil, "a@b#c", "abc"},
+		{[]*Set{Spanish}, "PEÑA & CÍA", "PEÑA + CÍA"},
+		{[]*Set{German}, "Müller Straße", "Müller Straße"},
+	}
+	for _, c := range cases {
+		if got := New(c.sets...).String(c.in); got != c.want {
+			t.Errorf("String(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
+
+func TestAlterations(t *testing.T) {
+	tl := New()
+	tl.Field("Nm", "PLAIN NAME")
+	tl.Field("Ustrd", "FRA. 1/2 ñ")
+	alterations := tl.Alterations()
+	if len(alterations) != 1 || alterations[0].Field != "Ustrd" || alterations[0].Result != "FRA. 1/2 n" {
+		t.Errorf("Unexpected alterations %+v", alterations)
+	}
+	tl.Reset()
+	if alterations := tl.Alterations(); len(alterations) != 0 {
+		t.Errorf("Expected no alterations after Reset, got %+v", alterations)
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/apsl/sepakit/convert"
+	"github.com/apsl/sepakit/ledger"
+	"github.com/apsl/sepakit/sepadebit"
+	"github.com/apsl/sepakit/translit"
+	"github.com/apsl/sepakit/watch"
+)
+
+//runWatch implements "sepakit watch": converts the files dropped in a folder
+//until SIGINT or SIGTERM
+func runWatch(args []string) error {
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	in := fs.String("in", "", "watched folder")
+	out := fs.String("out", "", "folder of the XML documents and their JSON reports")
+	archive := fs.String("archive", "", "folder of the converted inputs")
+	errDir := fs.String("error", "", "folder of the rejected inputs and their JSON reports")
+	statePath := fs.String("state", "", "state file of the processed files (default IN/"+watch.DefaultStateFile+")")
+	interval := fs.Duration("interval", watch.DefaultInterval, "time between polls")
+	marker := fs.Bool("marker", false, "process only the files with a FILE"+watch.MarkerSuffix+" marker, instead of the files of stable size")
+	profileName := fs.String("profile", "caixabank", "bank profile setting the creditor agent BIC and the charge bearer")
+	encoding := fs.String("encoding", "iso-8859-1", "output encoding: iso-8859-1 or utf-8")
+	charsets := fs.String("charset", "", "extended character sets allowed besides EPC basic Latin, comma separated (es, de)")
+	lengths := fs.String("lengths", "error", "policy for texts longer than allowed: error, truncate or wrap")
+	ledgerPath := fs.String("ledger", defaultLedgerPath(), "submission ledger refusing files and transactions already converted, none to disable")
+	rf := fs.String("rf", "none", "RF creditor references from the concepts that are RF references or generated from the transaction ids: none, concept or id")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Converts the AEB 19, CSB 19 and JSON files dropped in a folder to SEPA XML\nUsage: %s watch -in DIR -out DIR -archive DIR -error DIR [options]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	parseArgs(fs, args)
+
+	enc, err := sepadebit.ParseEncoding(*encoding)
+	if err != nil {
+		return err
+	}
+	sets, err := translit.SetsByName(*charsets)
+	if err != nil {
+		return err
+	}
+	policy := sepadebit.LengthPolicy{}
+	if policy.Mode, err = sepadebit.ParseLengthMode(*lengths); err != nil {
+		return err
+	}
+	rfSource, err := convert.ParseRFSource(*rf)
+	if err != nil {
+		return err
+	}
+	profile, err := convert.ParseProfile(*profileName)
+	if err != nil {
+		return err
+	}
+	var book *ledger.Ledger
+	if *ledgerPath != "none" && *ledgerPath != "" {
+		if book, err = ledger.Open(*ledgerPath); err != nil {
+			return err
+		}
+	}
+	conv, err := convert.NewConverter(
+		convert.WithOptions(convert.Options{RFReference: rfSource}),
+		convert.WithOutputEncoding(enc),
+		convert.WithProfile(profile),
+		convert.WithCharsets(sets...),
+		convert.WithLengths(policy),
+		convert.WithLedger(book, false),
+	)
+	if err != nil {
+		return err
+	}
+	w, err := watch.New(watch.Config{
+		In:            *in,
+		Out:           *out,
+		Archive:       *archive,
+		Error:         *errDir,
+		State:         *statePath,
+		Interval:      *interval,
+		RequireMarker: *marker,
+		Converter:     conv,
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-stop
+		log.Printf("Got %s, stopping after the current poll\n", sig)
+		cancel()
+	}()
+	log.Printf("Watching %s\n", *in)
+	return w.Run(ctx)
+}
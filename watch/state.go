@@ -0,0 +1,52 @@
+package watch
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+//converting is the status of a file being converted, recorded before its
+//output is written
+const converting = "converting"
+
+//state is the record of the processed files kept between runs
+type state struct {
+	//Files holds the processed files by SHA-256 hash
+	Files map[string]*entry `json:"files"`
+}
+
+//entry is a processed file of the state
+type entry struct {
+	Name      string    `json:"name"`
+	Status    string    `json:"status"`
+	Output    string    `json:"output,omitempty"`
+	Processed time.Time `json:"processed"`
+	//MovedTo is empty until the input is moved out of the input folder
+	MovedTo string `json:"movedTo,omitempty"`
+}
+
+//loadState reads the state file, an empty state when it does not exist
+func loadState(path string) (*state, error) {
+	st := &state{Files: map[string]*entry{}}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, st); err != nil {
+		return nil, err
+	}
+	if st.Files == nil {
+		st.Files = map[string]*entry{}
+	}
+	return st, nil
+}
+
+//save writes the state file atomically
+func (st *state) save(path string) error {
+	return writeJSONFile(path, st)
+}
@@ -0,0 +1,433 @@
+//Package watch implements the watched folder processor: files dropped in an
+//input folder are converted to pain.008 once complete, and then moved to an
+//archive folder, or to an error folder when they cannot be converted.
+//
+//A file is complete when a marker file with the same name plus ".done" is
+//present, or when its size and modification time did not change between two
+//polls. Every processed file is recorded by its SHA-256 hash in a state
+//file, so that a file converted before, even under other name or by a
+//previous run, is never converted again. The file is recorded before its
+//conversion is written, so that a run interrupted while converting it does
+//not convert it twice. The ledger of the Converter also refuses the files
+//and transactions converted by other tools.
+package watch
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/apsl/sepakit/convert"
+	"github.com/apsl/sepakit/ledger"
+)
+
+//DefaultInterval is the default time between polls
+const DefaultInterval = 5 * time.Second
+
+//MarkerSuffix is the suffix of the marker files flagging complete inputs
+const MarkerSuffix = ".done"
+
+//DefaultStateFile is the name of the state file in the input folder
+const DefaultStateFile = ".sepakit-watch.json"
+
+//Report statuses
+const (
+	Converted = "converted"
+	Failed    = "failed"
+	Duplicate = "duplicate"
+)
+
+//Config configures a Watcher
+type Config struct {
+	//In is the watched folder, Out receives the XML documents and their
+	//reports, Archive the converted inputs and Error the rejected inputs
+	//with their reports
+	In, Out, Archive, Error string
+	//State is the state file, DefaultStateFile in the input folder if empty
+	State string
+	//Interval is the time between polls, DefaultInterval if 0
+	Interval time.Duration
+	//RequireMarker processes only the files with a marker file, for
+	//producers that may pause while writing
+	RequireMarker bool
+	//Converter converts the files, refusing as duplicates those found in
+	//its ledger. A Converter with the defaults is used when nil
+	Converter *convert.Converter
+}
+
+//Report is the outcome of processing an input file, written as JSON next to
+//the XML document, or next to the input in the error folder
+type Report struct {
+	Input        string   `json:"input"`
+	SHA256       string   `json:"sha256"`
+	Status       string   `json:"status"`
+	Error        string   `json:"error,omitempty"`
+	Output       string   `json:"output,omitempty"`
+	MovedTo      string   `json:"movedTo,omitempty"`
+	MessageID    string   `json:"messageId,omitempty"`
+	Transactions int      `json:"transactions,omitempty"`
+	CtrlSum      string   `json:"ctrlSum,omitempty"`
+	Warnings     []string `json:"warnings,omitempty"`
+	//Transformations are the texts altered by the conversion, with the
+	//names redacted
+	Transformations []convert.Transformation `json:"transformations,omitempty"`
+	Started         time.Time                `json:"started"`
+	Finished        time.Time                `json:"finished"`
+}
+
+//Watcher polls the input folder. Failed files are not recorded as
+//processed: they are converted again when dropped again
+type Watcher struct {
+	cfg   Config
+	state *state
+	//seen holds the size and modification time of the files not yet ready
+	//at the previous poll
+	seen map[string]fileStat
+}
+
+type fileStat struct {
+	size int64
+	mod  time.Time
+}
+
+//New returns a Watcher for cfg, creating the output folders and loading the
+//state file of previous runs
+func New(cfg Config) (*Watcher, error) {
+	if cfg.In == "" || cfg.Out == "" || cfg.Archive == "" || cfg.Error == "" {
+		return nil, fmt.Errorf("Input, output, archive and error folders are required")
+	}
+	if fi, err := os.Stat(cfg.In); err != nil || !fi.IsDir() {
+		return nil, fmt.Errorf("%s: not a folder", cfg.In)
+	}
+	for _, dir := range []string{cfg.Out, cfg.Archive, cfg.Error} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.State == "" {
+		cfg.State = filepath.Join(cfg.In, DefaultStateFile)
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultInterval
+	}
+	if cfg.Converter == nil {
+		var err error
+		if cfg.Converter, err = convert.NewConverter(); err != nil {
+			return nil, err
+		}
+	}
+	st, err := loadState(cfg.State)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", cfg.State, err)
+	}
+	return &Watcher{cfg: cfg, state: st, seen: map[string]fileStat{}}, nil
+}
+
+//Run polls the input folder every interval until ctx is done, logging the
+//processed files
+func (w *Watcher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(w.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		reports, err := w.Poll()
+		if err != nil {
+			return err
+		}
+		for _, r := range reports {
+			if r.Error != "" {
+				log.Printf("%s: %s: %s\n", r.Input, r.Status, r.Error)
+			} else {
+				log.Printf("%s: %s %s\n", r.Input, r.Status, r.Output)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+//Poll processes the complete files of the input folder. Conversion errors
+//and unreadable files are reported in the returned reports, the error is
+//only returned when the folders or the state file cannot be used
+func (w *Watcher) Poll() ([]*Report, error) {
+	names, err := w.ready()
+	if err != nil {
+		return nil, err
+	}
+	var reports []*Report
+	for _, name := range names {
+		r, err := w.process(name)
+		if err != nil {
+			return reports, err
+		}
+		if r != nil {
+			reports = append(reports, r)
+		}
+	}
+	return reports, nil
+}
+
+//ready returns the names of the complete input files, in name order
+func (w *Watcher) ready() ([]string, error) {
+	infos, err := ioutil.ReadDir(w.cfg.In)
+	if err != nil {
+		return nil, err
+	}
+	markers := map[string]bool{}
+	for _, fi := range infos {
+		if strings.HasSuffix(fi.Name(), MarkerSuffix) {
+			markers[strings.TrimSuffix(fi.Name(), MarkerSuffix)] = true
+		}
+	}
+	state, _ := filepath.Abs(w.cfg.State)
+	seen := map[string]fileStat{}
+	var names []string
+	for _, fi := range infos {
+		name := fi.Name()
+		if !fi.Mode().IsRegular() || strings.HasPrefix(name, ".") || strings.HasSuffix(name, MarkerSuffix) {
+			continue
+		}
+		if path, _ := filepath.Abs(filepath.Join(w.cfg.In, name)); path == state {
+			continue
+		}
+		if markers[name] {
+			names = append(names, name)
+			continue
+		}
+		if w.cfg.RequireMarker {
+			continue
+		}
+		st := fileStat{size: fi.Size(), mod: fi.ModTime()}
+		if prev, ok := w.seen[name]; ok && prev.size == st.size && prev.mod.Equal(st.mod) && st.size > 0 {
+			names = append(names, name)
+			continue
+		}
+		seen[name] = st
+	}
+	w.seen = seen
+	sort.Strings(names)
+	return names, nil
+}
+
+//process converts an input file and moves it away from the input folder.
+//It returns a nil report for a file whose processing by a previous run was
+//interrupted before moving it, which is only moved now. A file that cannot
+//be read is reported as failed and left in the input folder
+func (w *Watcher) process(name string) (*Report, error) {
+	path := filepath.Join(w.cfg.In, name)
+	r := &Report{Input: name, Started: time.Now()}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		r.Status, r.Error, r.Finished = Failed, err.Error(), time.Now()
+		return r, nil
+	}
+	sum := sha256.Sum256(data)
+	r.SHA256 = hex.EncodeToString(sum[:])
+
+	e := w.state.Files[r.SHA256]
+	if e != nil && e.Status == converting {
+		//interrupted while converting: done if its output was written
+		if _, err := os.Stat(e.Output); err == nil && e.Name == name && e.MovedTo == "" {
+			e.Status = Converted
+		} else {
+			e = nil
+		}
+	}
+	switch {
+	case e != nil && e.Name == name && e.MovedTo == "":
+		dir := w.cfg.Error
+		if e.Status == Converted {
+			dir = w.cfg.Archive
+		}
+		return nil, w.finish(r.SHA256, name, dir)
+	case e != nil && e.Status == Converted:
+		r.Status = Duplicate
+		r.Error = fmt.Sprintf("Already converted from %s on %s", e.Name, e.Processed.Format(time.RFC3339))
+		r.Output = e.Output
+	default:
+		if err := w.convert(r, data); err != nil {
+			return nil, err
+		}
+	}
+
+	dest, reportDir := w.cfg.Archive, w.cfg.Out
+	if r.Status != Converted {
+		dest, reportDir = w.cfg.Error, w.cfg.Error
+	}
+	r.MovedTo = uniquePath(dest, stem(name), filepath.Ext(name))
+	r.Finished = time.Now()
+	reportPath := uniquePath(reportDir, stem(name)+".report", ".json")
+	if err := writeJSONFile(reportPath, r); err != nil {
+		return nil, err
+	}
+	if r.Status != Duplicate {
+		w.state.Files[r.SHA256] = &entry{Name: name, Status: r.Status, Output: r.Output, Processed: r.Finished}
+	} else if e := w.state.Files[r.SHA256]; e != nil && e.Status == converting {
+		//refused by the ledger
+		delete(w.state.Files, r.SHA256)
+	}
+	if err := w.state.save(w.cfg.State); err != nil {
+		return nil, err
+	}
+	if err := w.move(name, r.MovedTo); err != nil {
+		return nil, err
+	}
+	if r.Status != Duplicate {
+		w.state.Files[r.SHA256].MovedTo = r.MovedTo
+		if err := w.state.save(w.cfg.State); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+//convert converts data into the output folder, filling r. The input is
+//recorded in the state as being converted before its output is written
+func (w *Watcher) convert(r *Report, data []byte) error {
+	ext := ".xml"
+	if w.cfg.Converter.OutputFormat() == "json" {
+		ext = ".json"
+	}
+	out := uniquePath(w.cfg.Out, stem(r.Input), ext)
+	w.state.Files[r.SHA256] = &entry{Name: r.Input, Status: converting, Output: out, Processed: r.Started}
+	if err := w.state.save(w.cfg.State); err != nil {
+		return err
+	}
+	r.Status = Failed
+	f, err := convert.CreateFile(out)
+	if err != nil {
+		r.Error = err.Error()
+		return nil
+	}
+	defer f.Close()
+	rep, err := w.cfg.Converter.Convert(context.Background(), bytes.NewReader(data), f)
+	r.Warnings = rep.Warnings
+	r.Transformations = rep.Transformations
+	if err != nil {
+		if _, ok := err.(ledger.DuplicateError); ok {
+			r.Status = Duplicate
+		}
+		r.Error = err.Error()
+		return nil
+	}
+	r.Status = Converted
+	r.Output = out
+	r.MessageID = rep.MessageID
+	r.Transactions = rep.Transactions
+	r.CtrlSum = rep.CtrlSum
+	return nil
+}
+
+//finish moves an input whose processing was interrupted to dir
+func (w *Watcher) finish(hash, name, dir string) error {
+	movedTo := uniquePath(dir, stem(name), filepath.Ext(name))
+	if err := w.move(name, movedTo); err != nil {
+		return err
+	}
+	w.state.Files[hash].MovedTo = movedTo
+	return w.state.save(w.cfg.State)
+}
+
+//move moves an input from the input folder to dst, removing its marker file
+func (w *Watcher) move(name, dst string) error {
+	src := filepath.Join(w.cfg.In, name)
+	if err := moveFile(src, dst); err != nil {
+		return err
+	}
+	delete(w.seen, name)
+	if err := os.Remove(src + MarkerSuffix); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+//moveFile renames src to dst, copying it when they are in different file
+//systems
+func moveFile(src, dst string) error {
+	err := os.Rename(src, dst)
+	if err == nil {
+		return nil
+	}
+	in, oerr := os.Open(src)
+	if oerr != nil {
+		return err
+	}
+	defer in.Close()
+	out, cerr := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if cerr != nil {
+		return err
+	}
+	if _, cerr = io.Copy(out, in); cerr == nil {
+		cerr = out.Close()
+	} else {
+		out.Close()
+	}
+	if cerr != nil {
+		os.Remove(dst)
+		return cerr
+	}
+	in.Close()
+	return os.Remove(src)
+}
+
+//stem returns name without its extension
+func stem(name string) string {
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+//uniquePath returns dir/stem+ext, numbering it as stem-1+ext, stem-2+ext...
+//when the file exists
+func uniquePath(dir, stem, ext string) string {
+	path := filepath.Join(dir, stem+ext)
+	for i := 1; ; i++ {
+		if _, err := os.Lstat(path); os.IsNotExist(err) {
+			return path
+		}
+		path = filepath.Join(dir, fmt.Sprintf("%s-%d%s", stem, i, ext))
+	}
+}
+
+//writeFile writes data to path through a temporary file, so that readers of
+//the folder never see a partial file
+func writeFile(path string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Close()
+	} else {
+		tmp.Close()
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+func writeJSONFile(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(path, append(data, '\n'))
+}
@@ -0,0 +1,268 @@
+package watch
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apsl/sepakit/convert"
+	"github.com/apsl/sepakit/ledger"
+)
+
+func testConfig(t *testing.T) Config {
+	dir := t.TempDir()
+	cfg := Config{
+		In:      filepath.Join(dir, "in"),
+		Out:     filepath.Join(dir, "out"),
+		Archive: filepath.Join(dir, "archive"),
+		Error:   filepath.Join(dir, "error"),
+	}
+	if err := os.Mkdir(cfg.In, 0755); err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func drop(t *testing.T, dir, name string, data []byte) {
+	if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func poll(t *testing.T, w *Watcher) []*Report {
+	reports, err := w.Poll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reports
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func TestWatchStableFile(t *testing.T) {
+	cfg := testConfig(t)
+	input, err := ioutil.ReadFile("../input-aeb1914.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	drop(t, cfg.In, "remesa.txt", input)
+	if reports := poll(t, w); len(reports) != 0 {
+		t.Fatalf("File processed before it is stable: %+v", reports)
+	}
+	reports := poll(t, w)
+	if len(reports) != 1 || reports[0].Status != Converted {
+		t.Fatalf("Expected a converted file, got %+v", reports)
+	}
+	r := reports[0]
+	if r.Output != filepath.Join(cfg.Out, "remesa.xml") || r.Transactions != 1 {
+		t.Errorf("Unexpected report %+v", r)
+	}
+	for _, path := range []string{r.Output, filepath.Join(cfg.Out, "remesa.report.json"), filepath.Join(cfg.Archive, "remesa.txt")} {
+		if !exists(path) {
+			t.Errorf("Missing %s", path)
+		}
+	}
+	if exists(filepath.Join(cfg.In, "remesa.txt")) {
+		t.Error("Input not moved")
+	}
+
+	//the same content under other name, after a restart, is not converted
+	w, err = New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	drop(t, cfg.In, "copia.txt", input)
+	drop(t, cfg.In, "copia.txt.done", nil)
+	reports = poll(t, w)
+	if len(reports) != 1 || reports[0].Status != Duplicate {
+		t.Fatalf("Expected a duplicate, got %+v", reports)
+	}
+	if !exists(filepath.Join(cfg.Error, "copia.txt")) || exists(filepath.Join(cfg.In, "copia.txt.done")) {
+		t.Error("Duplicate not moved to the error folder")
+	}
+	if exists(filepath.Join(cfg.Out, "copia.xml")) {
+		t.Error("Duplicate converted")
+	}
+}
+
+func TestWatchMarkerAndErrors(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.RequireMarker = true
+	w, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	drop(t, cfg.In, "roto.txt", []byte("0180000000000000000000\n"))
+	poll(t, w)
+	if reports := poll(t, w); len(reports) != 0 {
+		t.Fatalf("File processed without marker: %+v", reports)
+	}
+	drop(t, cfg.In, "roto.txt.done", nil)
+	reports := poll(t, w)
+	if len(reports) != 1 || reports[0].Status != Failed || reports[0].Error == "" {
+		t.Fatalf("Expected a failed file, got %+v", reports)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(cfg.Error, "roto.report.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r Report
+	if err := json.Unmarshal(data, &r); err != nil || r.Status != Failed || r.MovedTo != filepath.Join(cfg.Error, "roto.txt") {
+		t.Errorf("Unexpected report %s (%v)", data, err)
+	}
+}
+
+func TestWatchInterruptedRun(t *testing.T) {
+	cfg := testConfig(t)
+	input, err := ioutil.ReadFile("../input-aeb1914.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	drop(t, cfg.In, "remesa.txt", input)
+	drop(t, cfg.In, "remesa.txt.done", nil)
+	poll(t, w)
+
+	//put the input back as if the run stopped before moving it
+	if err := os.Rename(filepath.Join(cfg.Archive, "remesa.txt"), filepath.Join(cfg.In, "remesa.txt")); err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range w.state.Files {
+		e.MovedTo = ""
+	}
+	if err := w.state.save(w.cfg.State); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err = New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	drop(t, cfg.In, "remesa.txt.done", nil)
+	if reports := poll(t, w); len(reports) != 0 {
+		t.Fatalf("Interrupted file processed again: %+v", reports)
+	}
+	if !exists(filepath.Join(cfg.Archive, "remesa.txt")) || exists(filepath.Join(cfg.Out, "remesa-1.xml")) {
+		t.Error("Interrupted file not archived without conversion")
+	}
+}
+
+func TestWatchInterruptedConversion(t *testing.T) {
+	cfg := testConfig(t)
+	input, err := ioutil.ReadFile("../input-aeb1914.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	drop(t, cfg.In, "remesa.txt", input)
+	drop(t, cfg.In, "remesa.txt.done", nil)
+	reports := poll(t, w)
+	if len(reports) != 1 || reports[0].Status != Converted {
+		t.Fatalf("Expected a converted file, got %+v", reports)
+	}
+
+	//stopped after writing the output: archived without converting it again
+	if err := os.Rename(filepath.Join(cfg.Archive, "remesa.txt"), filepath.Join(cfg.In, "remesa.txt")); err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range w.state.Files {
+		e.Status, e.MovedTo = converting, ""
+	}
+	if err := w.state.save(w.cfg.State); err != nil {
+		t.Fatal(err)
+	}
+	if w, err = New(cfg); err != nil {
+		t.Fatal(err)
+	}
+	drop(t, cfg.In, "remesa.txt.done", nil)
+	if reports := poll(t, w); len(reports) != 0 || exists(filepath.Join(cfg.Out, "remesa-1.xml")) {
+		t.Fatalf("Converted file converted again: %+v", reports)
+	}
+
+	//stopped before writing the output: converted again
+	if err := os.Rename(filepath.Join(cfg.Archive, "remesa.txt"), filepath.Join(cfg.In, "remesa.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(cfg.Out, "remesa.xml")); err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range w.state.Files {
+		e.Status, e.MovedTo = converting, ""
+	}
+	if err := w.state.save(w.cfg.State); err != nil {
+		t.Fatal(err)
+	}
+	if w, err = New(cfg); err != nil {
+		t.Fatal(err)
+	}
+	drop(t, cfg.In, "remesa.txt.done", nil)
+	if reports := poll(t, w); len(reports) != 1 || reports[0].Status != Converted || reports[0].Output != filepath.Join(cfg.Out, "remesa.xml") {
+		t.Fatalf("Expected the file converted again, got %+v", reports)
+	}
+}
+
+func TestWatchLedger(t *testing.T) {
+	cfg := testConfig(t)
+	input, err := ioutil.ReadFile("../input-aeb1914.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	book, err := ledger.Open(filepath.Join(t.TempDir(), "ledger.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Converter, err = convert.NewConverter(convert.WithLedger(book, false)); err != nil {
+		t.Fatal(err)
+	}
+	w, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	drop(t, cfg.In, "remesa.txt", input)
+	drop(t, cfg.In, "remesa.txt.done", nil)
+	if reports := poll(t, w); len(reports) != 1 || reports[0].Status != Converted {
+		t.Fatalf("Expected a converted file, got %+v", reports)
+	}
+
+	//the debits are in the ledger, even with a new state file
+	cfg.State = filepath.Join(t.TempDir(), "state.json")
+	if w, err = New(cfg); err != nil {
+		t.Fatal(err)
+	}
+	drop(t, cfg.In, "otra.txt", input)
+	drop(t, cfg.In, "otra.txt.done", nil)
+	reports := poll(t, w)
+	if len(reports) != 1 || reports[0].Status != Duplicate || reports[0].Error == "" {
+		t.Fatalf("Expected a duplicate, got %+v", reports)
+	}
+	if exists(filepath.Join(cfg.Out, "otra.xml")) || !exists(filepath.Join(cfg.Error, "otra.txt")) || len(w.state.Files) != 0 {
+		t.Error("Duplicate converted or recorded")
+	}
+}
+
+func TestWatchUnreadableFile(t *testing.T) {
+	cfg := testConfig(t)
+	w, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := w.process("borrado.txt")
+	if err != nil || r == nil || r.Status != Failed || r.Error == "" {
+		t.Errorf("Expected a failed report, got %+v (%v)", r, err)
+	}
+}